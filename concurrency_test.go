@@ -4,12 +4,15 @@ import (
 	"context"
 	"fmt"
 	"html/template"
+	"io/fs"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/donseba/go-partial/connector"
 )
@@ -194,6 +197,53 @@ func TestConcurrentPartialRendersDoNotBleedRequestData(t *testing.T) {
 	}
 }
 
+func TestConcurrentSiblingRendersShareInheritedFuncs(t *testing.T) {
+	fsys := &inMemoryFS{
+		Files: map[string]string{
+			"sibling.gohtml": `{{ label }} {{ shared }}`,
+		},
+	}
+	parent := NewID("parent").
+		SetFileSystem(fsys).
+		SetFunc(template.FuncMap{
+			"label":  func() string { return "parent" },
+			"shared": func() string { return "shared" },
+		})
+	first := NewID("first", "sibling.gohtml").
+		SetFunc(template.FuncMap{
+			"label": func() string { return "first" },
+		})
+	second := NewID("second", "sibling.gohtml")
+	parent.With(first).With(second)
+
+	const renders = 50
+	var wg sync.WaitGroup
+	errs := make(chan string, renders)
+	for i := range renders {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sibling, want := first, "first shared"
+			if i%2 == 1 {
+				sibling, want = second, "parent shared"
+			}
+			out, err := Render(context.Background(), sibling)
+			if err != nil {
+				errs <- err.Error()
+				return
+			}
+			if got := string(out); got != want {
+				errs <- sibling.PartialID() + " rendered " + got + ", want " + want
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
 func TestConcurrentShellRendersDoNotBleedRequestData(t *testing.T) {
 	fsys := &inMemoryFS{
 		Files: map[string]string{
@@ -541,3 +591,89 @@ func TestConcurrentBlueprintCacheShellOOBAndTargetStress(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// flakyFS fails every open until the swap ends, like files that are briefly
+// missing while a deploy replaces them.
+type flakyFS struct {
+	fs.FS
+	swapEnds time.Time
+}
+
+func (f *flakyFS) Open(name string) (fs.File, error) {
+	if time.Now().Before(f.swapEnds) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return f.FS.Open(name)
+}
+
+func TestConcurrentFirstRenderRetriesTransientFSError(t *testing.T) {
+	// The swap ends before the retry delay has passed, so the first renders
+	// fail once and then succeed on the retry.
+	fsys := &flakyFS{
+		FS:       &inMemoryFS{Files: map[string]string{"page.gohtml": `<main>{{ . }}</main>`}},
+		swapEnds: time.Now().Add(templateParseRetryDelay / 2),
+	}
+
+	page := NewID("page", "page.gohtml").
+		SetFileSystem(fsys).
+		UseTemplateCache(true)
+
+	const renders = 32
+	var wg sync.WaitGroup
+	errs := make(chan string, renders)
+	for i := range renders {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			value := strconv.Itoa(i)
+			out, err := RenderDot(context.Background(), page, value)
+			if err != nil {
+				errs <- err.Error()
+				return
+			}
+			if got, want := string(out), `<main>`+value+`</main>`; got != want {
+				errs <- "render " + value + " got " + got + " want " + want
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// openCountingFS counts the opens of name.
+type openCountingFS struct {
+	fs.FS
+	name  string
+	opens atomic.Int32
+}
+
+func (f *openCountingFS) Open(name string) (fs.File, error) {
+	if name == f.name {
+		f.opens.Add(1)
+	}
+	return f.FS.Open(name)
+}
+
+func TestMissingTemplateIsNotRetriedAfterFailedRetry(t *testing.T) {
+	fsys := &openCountingFS{FS: &inMemoryFS{}, name: "missing.gohtml"}
+	for _, useCache := range []bool{false, true} {
+		page := NewID("page", "missing.gohtml").
+			SetFileSystem(fsys).
+			UseTemplateCache(useCache)
+
+		var opens [2]int32
+		for i := range opens {
+			before := fsys.opens.Load()
+			if _, err := Render(context.Background(), page); err == nil {
+				t.Fatalf("Render(useCache=%v) error = nil, want the missing template", useCache)
+			}
+			opens[i] = fsys.opens.Load() - before
+		}
+		if opens[1] != opens[0]-1 {
+			t.Fatalf("useCache=%v opens per render = %v, want the second render to skip the retry", useCache, opens)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package partial
+
+import (
+	"fmt"
+	"slices"
+)
+
+type (
+	// PartialConfig declares a partial tree as data, for example decoded from
+	// JSON, so it can be built with FromConfig instead of chained builder calls.
+	PartialConfig struct {
+		// ID is the partial ID. It defaults to "root" for the top-level config
+		// and is required for children.
+		ID        string   `json:"id"`
+		Templates []string `json:"templates,omitempty"`
+		// OOB registers the partial with its parent as an out-of-band child.
+		OOB       bool             `json:"oob,omitempty"`
+		Children  []PartialConfig  `json:"children,omitempty"`
+		Selection *SelectionConfig `json:"selection,omitempty"`
+	}
+
+	// SelectionConfig declares the named partials of a selection. The built
+	// partials are handed to Apply, which registers them with the extension
+	// that renders the selection, such as selection.WithSelectMap.
+	SelectionConfig struct {
+		Default  string                   `json:"default"`
+		Partials map[string]PartialConfig `json:"partials"`
+		// Apply registers the built partials on the parent.
+		Apply func(p *Partial, defaultKey string, partials map[string]*Partial) *Partial `json:"-"`
+	}
+)
+
+// FromConfig builds the partial tree declared by cfg. It returns an error when
+// a child has no ID, when sibling IDs repeat, or when a selection has no Apply
+// function or names a default that is not one of its partials.
+func FromConfig(cfg PartialConfig) (*Partial, error) {
+	if cfg.ID == "" {
+		cfg.ID = "root"
+	}
+	return buildFromConfig(cfg)
+}
+
+func buildFromConfig(cfg PartialConfig) (*Partial, error) {
+	p := NewID(cfg.ID, slices.Clone(cfg.Templates)...)
+	seen := make(map[string]struct{}, len(cfg.Children))
+	for _, childCfg := range cfg.Children {
+		if childCfg.ID == "" {
+			return nil, fmt.Errorf("partial config: child of partial '%s' has no id", cfg.ID)
+		}
+		if _, ok := seen[childCfg.ID]; ok {
+			return nil, fmt.Errorf("partial config: duplicate child id '%s' in partial '%s'", childCfg.ID, cfg.ID)
+		}
+		seen[childCfg.ID] = struct{}{}
+
+		child, err := buildFromConfig(childCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		if childCfg.OOB {
+			p.WithOOB(child)
+		} else {
+			p.With(child)
+		}
+	}
+
+	if selection := cfg.Selection; selection != nil {
+		if selection.Apply == nil {
+			return nil, fmt.Errorf("partial config: selection of partial '%s' has no apply function", cfg.ID)
+		}
+		if _, ok := selection.Partials[selection.Default]; !ok && selection.Default != "" {
+			return nil, fmt.Errorf("partial config: selection default '%s' is not a partial of '%s'", selection.Default, cfg.ID)
+		}
+		partials := make(map[string]*Partial, len(selection.Partials))
+		for key, optionCfg := range selection.Partials {
+			if optionCfg.ID == "" {
+				optionCfg.ID = key
+			}
+			option, err := buildFromConfig(optionCfg)
+			if err != nil {
+				return nil, err
+			}
+			partials[key] = option
+		}
+		p = selection.Apply(p, selection.Default, partials)
+	}
+
+	return p, nil
+}
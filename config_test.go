@@ -0,0 +1,64 @@
+package partial
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/donseba/go-partial/connector"
+)
+
+func TestFromConfigBuildsTwoLevelTree(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("page.gohtml", `<main>{{ render "list" }}</main>`)
+	fsys.AddFile("list.gohtml", `<ul id="list">{{ render "item" }}</ul>`)
+	fsys.AddFile("item.gohtml", `<li>item</li>`)
+	fsys.AddFile("footer.gohtml", `<footer id="footer">footer</footer>`)
+
+	var cfg PartialConfig
+	if err := json.Unmarshal([]byte(`{
+		"id": "page",
+		"templates": ["page.gohtml"],
+		"children": [
+			{"id": "list", "templates": ["list.gohtml"], "children": [
+				{"id": "item", "templates": ["item.gohtml"]}
+			]},
+			{"id": "footer", "templates": ["footer.gohtml"], "oob": true}
+		]
+	}`), &cfg); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	page, err := FromConfig(cfg)
+	if err != nil {
+		t.Fatalf("FromConfig() error = %v", err)
+	}
+	page.SetFileSystem(fsys).SetConnector(connector.NewHTMX(nil))
+
+	out, err := Render(context.Background(), page)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if got, want := string(out), `<main><ul id="list"><li>item</li></ul></main>`; got != want {
+		t.Fatalf("Render() = %q, want %q", got, want)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+	req.Header.Set(connector.HTMXHeaderTarget.String(), "list")
+	out, err = RenderWithRequest(context.Background(), req, page)
+	if err != nil {
+		t.Fatalf("RenderWithRequest() error = %v", err)
+	}
+	if got, want := string(out), `<ul id="list"><li>item</li></ul><footer hx-swap-oob="true" id="footer">footer</footer>`; got != want {
+		t.Fatalf("RenderWithRequest() = %q, want %q", got, want)
+	}
+
+	_, err = FromConfig(PartialConfig{Children: []PartialConfig{{ID: "a"}, {ID: "a"}}})
+	if err == nil || !strings.Contains(err.Error(), "duplicate child id 'a'") {
+		t.Fatalf("FromConfig(duplicate) error = %v", err)
+	}
+}
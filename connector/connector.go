@@ -18,6 +18,32 @@ type (
 		ResponseHeaders(response Response) map[string]string
 	}
 
+	// Named is implemented by connectors that expose a stable name, used to
+	// select connector-specific templates.
+	Named interface {
+		Name() string
+	}
+
+	// Detector is implemented by connectors that recognize requests sent by
+	// their client library. Detect uses it to pick a connector per request.
+	Detector interface {
+		Detect(r *http.Request) bool
+	}
+
+	// OOBMarker is implemented by connectors that mark out-of-band fragments
+	// with an attribute on their root element. The attribute is injected when
+	// an OOB template does not set it itself.
+	OOBMarker interface {
+		OOBAttr() (name string, value string)
+	}
+
+	// RequestHeaderer is implemented by connectors that recognize partial
+	// requests by headers other than their target, select, and action
+	// headers, such as HX-Request.
+	RequestHeaderer interface {
+		RequestHeaders() []string
+	}
+
 	Config struct {
 		UseURLQuery bool
 	}
@@ -55,6 +81,11 @@ const (
 	InteractionRefresh  InteractionKind = "refresh"
 	InteractionOn       InteractionKind = "on"
 
+	NamePartial = "partial"
+	NameHTMX    = "htmx"
+	NameTurbo   = "turbo"
+	NameUnpoly  = "unpoly"
+
 	HeaderTarget HeaderKey = "X-Target"
 	HeaderSelect HeaderKey = "X-Select"
 	HeaderAction HeaderKey = "X-Action"
@@ -76,6 +107,48 @@ func (h HeaderKey) String() string {
 	return string(h)
 }
 
+// NameOf returns the connector name, or an empty string when c does not
+// implement Named.
+func NameOf(c Connector) string {
+	if named, ok := c.(Named); ok {
+		return named.Name()
+	}
+	return ""
+}
+
+// VaryHeaders returns the request headers that decide how c renders a
+// response: its target, select, and action headers and, when c implements
+// RequestHeaderer, its request headers. Cacheable responses must vary on them.
+func VaryHeaders(c Connector) []string {
+	if c == nil {
+		return nil
+	}
+	var headers []string
+	if requestHeaderer, ok := c.(RequestHeaderer); ok {
+		headers = append(headers, requestHeaderer.RequestHeaders()...)
+	}
+	return append(headers, c.GetTargetHeader(), c.GetSelectHeader(), c.GetActionHeader())
+}
+
+// Detect returns the first connector that recognizes r, or nil when none
+// does. Connectors that do not implement Detector are skipped.
+func Detect(r *http.Request, connectors ...Connector) Connector {
+	if r == nil {
+		return nil
+	}
+	for _, c := range connectors {
+		if detector, ok := c.(Detector); ok && detector.Detect(r) {
+			return c
+		}
+	}
+	return nil
+}
+
+// Detect reports whether r carries the connector's target header.
+func (x *base) Detect(r *http.Request) bool {
+	return r != nil && r.Header.Get(x.targetHeader) != ""
+}
+
 func (x *base) RenderPartial(r *http.Request) bool {
 	if r == nil {
 		return false
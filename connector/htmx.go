@@ -40,6 +40,7 @@ const (
 	HTMXAttrTrigger    = "hx-trigger"
 	HTMXAttrTarget     = "hx-target"
 	HTMXAttrSwap       = "hx-swap"
+	HTMXAttrSwapOOB    = "hx-swap-oob"
 	HTMXAttrSSEConnect = "sse-connect"
 	HTMXAttrSSESwap    = "sse-swap"
 )
@@ -58,6 +59,26 @@ func NewHTMX(c *Config) Connector {
 	}
 }
 
+func (h *HTMX) Name() string {
+	return NameHTMX
+}
+
+func (h *HTMX) OOBAttr() (string, string) {
+	return HTMXAttrSwapOOB, "true"
+}
+
+// RequestHeaders returns the htmx headers that mark partial, boosted, and
+// history restore requests.
+func (h *HTMX) RequestHeaders() []string {
+	return []string{h.requestHeader, h.boostedHeader, h.historyRestoreRequestHeader}
+}
+
+// Detect reports whether r was sent by htmx, including boosted and history
+// restore requests.
+func (h *HTMX) Detect(r *http.Request) bool {
+	return r != nil && (r.Header.Get(h.requestHeader) == "true" || r.Header.Get(h.boostedHeader) == "true")
+}
+
 func (h *HTMX) RenderPartial(r *http.Request) bool {
 	if r == nil {
 		return false
@@ -69,6 +90,12 @@ func (h *HTMX) RenderPartial(r *http.Request) bool {
 	return (hxRequest == "true" || hxBoosted == "true") && hxHistoryRestoreRequest != "true"
 }
 
+// GetTargetValue returns the requested target ID. A leading "#" is removed so
+// selector-style values such as "#content" match the partial ID "content".
+func (h *HTMX) GetTargetValue(r *http.Request) string {
+	return strings.TrimPrefix(h.base.GetTargetValue(r), "#")
+}
+
 func (h *HTMX) ResponseHeaders(response Response) map[string]string {
 	headers := make(map[string]string)
 	setResponseHeader(headers, HTMXHeaderLocation, response.Location)
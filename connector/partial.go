@@ -14,3 +14,7 @@ func NewPartial(c *Config) Connector {
 		},
 	}
 }
+
+func (x *Partial) Name() string {
+	return NamePartial
+}
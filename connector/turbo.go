@@ -25,6 +25,10 @@ func NewTurbo(c *Config) Connector {
 	}
 }
 
+func (t *Turbo) Name() string {
+	return NameTurbo
+}
+
 func (t *Turbo) InteractionAttrs(interaction Interaction) map[string]string {
 	attrs := map[string]string{}
 	switch interaction.Kind {
@@ -27,6 +27,10 @@ func NewUnpoly(c *Config) Connector {
 	}
 }
 
+func (u *Unpoly) Name() string {
+	return NameUnpoly
+}
+
 func (u *Unpoly) RenderPartial(r *http.Request) bool {
 	if r == nil {
 		return false
@@ -0,0 +1,128 @@
+package partial
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrPartialNotInitialized is returned when a nil partial is rendered.
+	ErrPartialNotInitialized = errors.New("partial is not initialized")
+	// ErrNoTemplates is returned when a partial without template paths renders.
+	ErrNoTemplates = errors.New("no templates provided for rendering")
+	// ErrOutputTooLarge is returned when template output exceeds the limit set
+	// with SetMaxOutputBytes.
+	ErrOutputTooLarge = errors.New("render output exceeds the maximum size")
+	// ErrNoContent can be returned by actions and render stages that have
+	// nothing to render, such as a heartbeat. Write answers it with status 204
+	// and an empty body instead of treating it as a failure.
+	ErrNoContent = errors.New("no content")
+	// ErrInvalidGroup is returned when rendering a group created by NewGroup
+	// whose members cannot each be addressed by their own ID.
+	ErrInvalidGroup = errors.New("invalid partial group")
+)
+
+// TargetNotFoundError reports a requested partial ID that is not part of the
+// rendered tree.
+type TargetNotFoundError struct {
+	ID     string
+	Parent string
+}
+
+func (e *TargetNotFoundError) Error() string {
+	return fmt.Sprintf("requested partial %s not found in parent %s", e.ID, e.Parent)
+}
+
+// MissingHeaderError reports a request that lacks a header required by the
+// rendered partial with RequireHeader. Value is empty when any value is
+// accepted.
+type MissingHeaderError struct {
+	ID    string
+	Name  string
+	Value string
+}
+
+func (e *MissingHeaderError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("partial %s requires request header %s", e.ID, e.Name)
+	}
+	return fmt.Sprintf("partial %s requires request header %s: %s", e.ID, e.Name, e.Value)
+}
+
+// StatusCode returns http.StatusBadRequest: the request, not the server, is at
+// fault.
+func (e *MissingHeaderError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// ErrorStatus returns the HTTP status for a failed render: the status of the
+// first error in err's chain with a StatusCode method, such as
+// *MissingHeaderError, or http.StatusInternalServerError otherwise.
+func ErrorStatus(err error) int {
+	var status interface{ StatusCode() int }
+	if errors.As(err, &status) {
+		return status.StatusCode()
+	}
+	return http.StatusInternalServerError
+}
+
+// PanicError reports a panic recovered while rendering a partial configured
+// with SetRecoverPanics. Stack holds the stack trace of the panicking
+// goroutine.
+type PanicError struct {
+	ID    string
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic rendering partial %s: %v", e.ID, e.Value)
+}
+
+// TemplateParseError reports a failure to parse the templates of a partial.
+// Path is the partial's primary template; Err carries the parser detail.
+type TemplateParseError struct {
+	Path string
+	Err  error
+}
+
+func (e *TemplateParseError) Error() string {
+	return fmt.Sprintf("error parsing templates: %v", e.Err)
+}
+
+func (e *TemplateParseError) Unwrap() error {
+	return e.Err
+}
+
+// TemplateExecuteError reports a failure while executing the templates of a
+// partial. ID is the partial and Template its primary template; Err carries
+// the execution detail, such as a *FuncError from a wrapped function.
+type TemplateExecuteError struct {
+	ID       string
+	Template string
+	Err      error
+}
+
+func (e *TemplateExecuteError) Error() string {
+	return fmt.Sprintf("error executing template '%s' in partial %s: %v", e.Template, e.ID, e.Err)
+}
+
+func (e *TemplateExecuteError) Unwrap() error {
+	return e.Err
+}
+
+// FuncError reports an error returned by a template function wrapped with
+// WrapFuncErrors. Name is the function name the template called.
+type FuncError struct {
+	Name string
+	Err  error
+}
+
+func (e *FuncError) Error() string {
+	return fmt.Sprintf("template function %s: %v", e.Name, e.Err)
+}
+
+func (e *FuncError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,70 @@
+package partial
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/donseba/go-partial/connector"
+	"github.com/donseba/go-partial/exp/templatehelpers"
+)
+
+func TestRenderErrorsSupportErrorsIsAndAs(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("content.gohtml", `content`)
+	fsys.AddFile("broken.gohtml", `{{ if .Missing }}broken`)
+
+	if _, err := Render(context.Background(), nil); !errors.Is(err, ErrPartialNotInitialized) {
+		t.Fatalf("Render(nil) error = %v, want ErrPartialNotInitialized", err)
+	}
+	if _, err := Render(context.Background(), NewID("empty").SetFileSystem(fsys)); !errors.Is(err, ErrNoTemplates) {
+		t.Fatalf("Render(empty) error = %v, want ErrNoTemplates", err)
+	}
+
+	shell := NewID("shell", "shell.gohtml").SetFileSystem(fsys)
+	shell.SetContent(NewID("content", "content.gohtml"))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(connector.HeaderTarget.String(), "sidebar")
+	_, err := RenderWithRequest(context.Background(), req, shell)
+	var targetErr *TargetNotFoundError
+	if !errors.As(err, &targetErr) {
+		t.Fatalf("RenderWithRequest() error = %v, want TargetNotFoundError", err)
+	}
+	if targetErr.ID != "sidebar" || targetErr.Parent != "shell" {
+		t.Fatalf("TargetNotFoundError = %+v, want sidebar in shell", targetErr)
+	}
+
+	_, err = Render(context.Background(), NewID("broken", "broken.gohtml").SetFileSystem(fsys))
+	var parseErr *TemplateParseError
+	if !errors.As(err, &parseErr) || parseErr.Path != "broken.gohtml" {
+		t.Fatalf("Render(broken) error = %v, want TemplateParseError for broken.gohtml", err)
+	}
+}
+
+func TestWrapFuncErrorsLocatesFailingCall(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("event.gohtml", `<time>{{ parseDate "2006-01-02" .Date }}</time>`)
+
+	event := NewID("event", "event.gohtml").
+		SetFileSystem(fsys).
+		SetFunc(WrapFuncErrors(templatehelpers.TimeFuncMap())).
+		SetDot(map[string]string{"Date": "16/10/2026"})
+
+	_, err := Render(context.Background(), event)
+	var execErr *TemplateExecuteError
+	if !errors.As(err, &execErr) || execErr.ID != "event" || execErr.Template != "event.gohtml" {
+		t.Fatalf("Render() error = %v, want TemplateExecuteError for event.gohtml", err)
+	}
+	var funcErr *FuncError
+	if !errors.As(err, &funcErr) || funcErr.Name != "parseDate" {
+		t.Fatalf("Render() error = %v, want FuncError for parseDate", err)
+	}
+	var parseErr *time.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Render() error = %v, want the underlying time.ParseError", err)
+	}
+}
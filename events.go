@@ -2,6 +2,7 @@ package partial
 
 import (
 	"context"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -65,6 +66,12 @@ type (
 		event Event
 	}
 
+	// RenderedPartials records the IDs of partials that finished rendering.
+	RenderedPartials struct {
+		mu  sync.Mutex
+		ids []string
+	}
+
 	eventSinkContextKey struct{}
 )
 
@@ -113,6 +120,9 @@ const (
 	EventTargetMissing = "target.missing"
 	// EventContractInvalid is emitted when contract data or helper arguments are invalid.
 	EventContractInvalid = "contract.invalid"
+	// EventSwapTargetInvalid is emitted when SetValidateSwapTargets finds a
+	// target or OOB fragment whose root element lacks the expected id.
+	EventSwapTargetInvalid = "swap.target_invalid"
 )
 
 // Emit sends event to the wrapped function.
@@ -161,6 +171,35 @@ func EventSinkFromContext(ctx context.Context) EventSink {
 	return sink
 }
 
+// TrackRenderedPartials attaches a request-scoped RenderedPartials collector to
+// ctx. Pass the returned context to Render, RenderWithRequest, or Write and read
+// the collector afterwards, for example from middleware.
+func TrackRenderedPartials(ctx context.Context) (context.Context, *RenderedPartials) {
+	tracker := &RenderedPartials{}
+	return WithEventSink(ctx, tracker), tracker
+}
+
+// Emit records partial renders that finished successfully.
+func (t *RenderedPartials) Emit(ctx *RenderContext, event Event) {
+	if t == nil || ctx == nil || ctx.Kind != RenderKindPartial || event.Kind != EventRenderFinish {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ids = append(t.ids, event.PartialID)
+}
+
+// IDs returns the rendered partial IDs in the order their renders finished.
+// Nested children finish before the partial that includes them.
+func (t *RenderedPartials) IDs() []string {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.ids)
+}
+
 // NewAsyncEvents returns a non-blocking dispatcher for diagnostic events.
 func NewAsyncEvents(cfg EventsConfig, sinks ...EventSink) *AsyncEvents {
 	if cfg.Buffer < 0 {
@@ -266,6 +266,30 @@ func TestAsyncEventsAcceptsConcurrentEmits(t *testing.T) {
 	}
 }
 
+func TestTrackRenderedPartialsRecordsTargetAndOOB(t *testing.T) {
+	files := fstest.MapFS{
+		"shell.gohtml":   {Data: []byte(`<main>{{ content }}</main>`)},
+		"content.gohtml": {Data: []byte(`<div id="content">content</div>`)},
+		"footer.gohtml":  {Data: []byte(`<footer id="footer"{{ oobAttr }}>footer</footer>`)},
+	}
+	shell := NewID("shell", "shell.gohtml").SetFileSystem(files)
+	shell.WithOOB(NewID("footer", "footer.gohtml"))
+	content := NewID("content", "content.gohtml")
+	shell.SetContent(content)
+
+	req := httptestRequest("GET", "/")
+	req.Header.Set("X-Target", "content")
+	ctx, rendered := TrackRenderedPartials(context.Background())
+	if _, err := RenderWithRequest(ctx, req, shell); err != nil {
+		t.Fatalf("RenderWithRequest() error = %v", err)
+	}
+
+	ids := rendered.IDs()
+	if len(ids) != 2 || ids[0] != "content" || ids[1] != "footer" {
+		t.Fatalf("rendered partials = %#v, want content then footer", ids)
+	}
+}
+
 func httptestRequest(method, target string) *http.Request {
 	req, _ := http.NewRequest(method, target, nil)
 	return req
@@ -5,31 +5,112 @@ import (
 	"context"
 	"fmt"
 	"html/template"
+	"maps"
 	"slices"
+	"sync"
 
 	partial "github.com/donseba/go-partial"
 )
 
 type (
 	// Action can replace or render a partial during a request-aware render.
+	// A nil partial keeps the current partial for WithAction and renders
+	// nothing for WithTemplateAction.
 	Action func(ctx context.Context, p *partial.Partial, runtime *partial.Runtime) (*partial.Partial, error)
 
+	// Step is one stage of a Pipeline, such as validating or persisting a
+	// submitted form.
+	Step func(ctx context.Context, p *partial.Partial, runtime *partial.Runtime) error
+
 	config struct {
 		action         Action
 		templateAction Action
 	}
 
 	extensionKey struct{}
+
+	registryKey struct{}
+
+	layoutKey struct{}
+
+	// registry holds the actions registered on the partial with ID id.
+	registry struct {
+		id      string
+		actions map[string]Action
+	}
+
+	// layoutConfig marks the partial with ID id as a layout. Descendants see
+	// the extension through their parents but are not layouts themselves.
+	layoutConfig struct {
+		id string
+	}
+
+	// layoutSwap carries a layout requested by an action through one render
+	// pass. depth counts the partial renders in progress, so the layout
+	// replaces the output of the outermost one.
+	layoutSwap struct {
+		mu     sync.Mutex
+		depth  int
+		layout *partial.Partial
+	}
+)
+
+const (
+	// EventActionEmpty identifies template actions that returned no partial.
+	EventActionEmpty = "exp.actions.empty"
 )
 
 // WithAction configures a partial-level action that may replace the partial
-// before its template is rendered.
+// before its template is rendered. OOB children attached to the returned
+// partial with WithOOB are rendered as out-of-band swaps on target requests.
 func WithAction(p *partial.Partial, action Action) *partial.Partial {
 	cfg := getConfig(p)
 	cfg.action = action
 	return p.SetExtension(extensionKey{}, cfg)
 }
 
+// Pipeline returns an action that runs steps in order. The first step that
+// returns an error stops the pipeline, and the error fails the render so the
+// configured error response is shown; otherwise the partial renders as usual.
+func Pipeline(steps ...Step) Action {
+	return func(ctx context.Context, p *partial.Partial, runtime *partial.Runtime) (*partial.Partial, error) {
+		for i, step := range steps {
+			if step == nil {
+				continue
+			}
+			if err := step(ctx, p, runtime); err != nil {
+				return nil, fmt.Errorf("pipeline step %d: %w", i+1, err)
+			}
+		}
+		return nil, nil
+	}
+}
+
+// WithPipeline configures a partial-level action that runs steps in order,
+// as described for Pipeline.
+func WithPipeline(p *partial.Partial, steps ...Step) *partial.Partial {
+	return WithAction(p, Pipeline(steps...))
+}
+
+// Register adds a named action to p's tree. When the request's action value
+// matches name, the requested target runs the action unless it has its own
+// action configured with WithAction. The target is resolved as for the render
+// itself, so aliases and the default target count. Actions are looked up from
+// the target up through its parents at render time, so Register on the root
+// partial makes an action such as "logout" or "refresh" available to every
+// partial, including children attached before it was registered.
+func Register(p *partial.Partial, name string, action Action) *partial.Partial {
+	if p == nil {
+		return nil
+	}
+	actions := maps.Clone(ownRegistry(p).actions)
+	if actions == nil {
+		actions = make(map[string]Action)
+	}
+	actions[name] = action
+	return p.SetExtension(registryKey{}, registry{id: p.PartialID(), actions: actions})
+}
+
 // WithTemplateAction configures the action template helper for a partial.
 func WithTemplateAction(p *partial.Partial, action Action) *partial.Partial {
 	cfg := getConfig(p)
@@ -37,6 +118,24 @@ func WithTemplateAction(p *partial.Partial, action Action) *partial.Partial {
 	return p.SetExtension(extensionKey{}, cfg)
 }
 
+// AsLayout marks p as a page layout. When an action returns a marked partial,
+// the outermost partial of the render, normally the wrapper, is rendered as p
+// instead, so an action on the content can switch the whole page to a login
+// shell or another layout. On the root partial it behaves like any returned
+// partial.
+func AsLayout(p *partial.Partial) *partial.Partial {
+	if p == nil {
+		return nil
+	}
+	return p.SetExtension(layoutKey{}, layoutConfig{id: p.PartialID()})
+}
+
+func isLayout(p *partial.Partial) bool {
+	value, _ := p.Extension(layoutKey{})
+	cfg, ok := value.(layoutConfig)
+	return ok && cfg.id == p.PartialID()
+}
+
 // FuncMap returns placeholders for the action template helpers.
 func FuncMap() template.FuncMap {
 	return template.FuncMap{
@@ -109,19 +208,82 @@ func Stage() partial.RenderStage {
 			})
 			ctx.SetFunc("action", func() template.HTML { return ActionHTML(ctx) })
 
-			cfg := getConfig(ctx.Partial)
-			if cfg.action == nil || ctx.Kind != partial.RenderKindPartial {
+			if ctx.Kind != partial.RenderKindPartial {
 				return ctx, nil
 			}
-			nextPartial, err := cfg.action(ctx.Context, ctx.Partial, ctx.Runtime)
+			action := getConfig(ctx.Partial).action
+			if action == nil {
+				action = registeredAction(ctx)
+			}
+			if action == nil {
+				return ctx, nil
+			}
+			nextPartial, err := action(ctx.Context, ctx.Partial, ctx.Runtime)
 			if err != nil {
 				return ctx, fmt.Errorf("error in action function: %w", err)
 			}
+			if nextPartial != nil && isLayout(nextPartial) && ctx.Partial.ParentID() != "" {
+				requestLayout(ctx, nextPartial)
+				return ctx, nil
+			}
 			if nextPartial != nil {
 				ctx.Partial = nextPartial
 			}
 			return ctx, nil
 		},
+		RenderFunc: func(ctx *partial.RenderContext, next partial.RenderNext) (template.HTML, error) {
+			if ctx == nil || ctx.Kind != partial.RenderKindPartial || ctx.Runtime == nil {
+				return next(ctx)
+			}
+			swap := pendingLayout(ctx)
+			swap.mu.Lock()
+			swap.depth++
+			swap.mu.Unlock()
+
+			out, err := next(ctx)
+
+			swap.mu.Lock()
+			swap.depth--
+			layout := swap.layout
+			if swap.depth > 0 {
+				layout = nil
+			} else {
+				swap.layout = nil
+			}
+			swap.mu.Unlock()
+
+			if err != nil || layout == nil {
+				return out, err
+			}
+			return ctx.Runtime.RenderPartial(layout)
+		},
+	}
+}
+
+// pendingLayout returns the layout swap shared by the renders of the current
+// render pass.
+func pendingLayout(ctx *partial.RenderContext) *layoutSwap {
+	value, _ := ctx.Runtime.Once(layoutKey{}, func() (any, error) {
+		return &layoutSwap{}, nil
+	})
+	swap, _ := value.(*layoutSwap)
+	if swap == nil {
+		return &layoutSwap{}
+	}
+	return swap
+}
+
+// requestLayout records layout for the outermost render. The first request
+// in a render pass wins.
+func requestLayout(ctx *partial.RenderContext, layout *partial.Partial) {
+	if ctx.Runtime == nil {
+		return
+	}
+	swap := pendingLayout(ctx)
+	swap.mu.Lock()
+	defer swap.mu.Unlock()
+	if swap.layout == nil {
+		swap.layout = layout
 	}
 }
 
@@ -144,6 +306,36 @@ func getConfig(p *partial.Partial) config {
 	return cfg
 }
 
+// ownRegistry returns the actions registered on p itself, ignoring those
+// inherited from its parents.
+func ownRegistry(p *partial.Partial) registry {
+	value, ok := p.Extension(registryKey{})
+	if !ok {
+		return registry{}
+	}
+	reg, _ := value.(registry)
+	if reg.id != p.PartialID() {
+		return registry{}
+	}
+	return reg
+}
+
+// registeredAction returns the registered action matching the request's
+// action value when ctx renders the partial the request resolved to. The
+// nearest registration from ctx's partial up to the root wins.
+func registeredAction(ctx *partial.RenderContext) Action {
+	name := ActionValue(ctx)
+	if name == "" || !ctx.IsRequestTarget() {
+		return nil
+	}
+	for p := ctx.Partial; p != nil; p = p.Parent() {
+		if action := ownRegistry(p).actions[name]; action != nil {
+			return action
+		}
+	}
+	return nil
+}
+
 func renderTemplateAction(ctx *partial.RenderContext) template.HTML {
 	cfg := getConfig(ctx.Partial)
 	if cfg.templateAction == nil {
@@ -153,6 +345,14 @@ func renderTemplateAction(ctx *partial.RenderContext) template.HTML {
 	if err != nil {
 		return template.HTML(fmt.Sprintf("error in action function: %v", err))
 	}
+	if actionPartial == nil {
+		ctx.Emit(partial.Event{
+			Kind:    EventActionEmpty,
+			Level:   partial.EventDebug,
+			Message: "template action returned no partial",
+		})
+		return ""
+	}
 	html, err := ctx.Runtime.RenderPartialWithFallback(actionPartial)
 	if err != nil {
 		return template.HTML(fmt.Sprintf("error rendering action partial: %v", err))
@@ -2,6 +2,7 @@ package actions
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
@@ -37,6 +38,324 @@ func TestWithActionCanReplacePartial(t *testing.T) {
 	}
 }
 
+func TestActionOOBChildrenRenderOnTargetRequest(t *testing.T) {
+	fsys := fstest.MapFS{
+		"shell.gohtml":   &fstest.MapFile{Data: []byte(`<main>{{ content }}</main>`)},
+		"content.gohtml": &fstest.MapFile{Data: []byte(`<div id="content">before</div>`)},
+		"saved.gohtml":   &fstest.MapFile{Data: []byte(`<div id="content">saved</div>`)},
+		"toast.gohtml":   &fstest.MapFile{Data: []byte(`<div id="toast"{{ oobAttr }}>Saved!</div>`)},
+	}
+	shell := partial.NewID("shell", "shell.gohtml").
+		SetFileSystem(fsys).
+		SetConnector(connector.NewHTMX(nil)).
+		SetFunc(FuncMap()).
+		Use(Stage())
+	content := partial.NewID("content", "content.gohtml")
+	WithAction(content, func(ctx context.Context, p *partial.Partial, runtime *partial.Runtime) (*partial.Partial, error) {
+		saved := partial.NewID("content", "saved.gohtml").SetFileSystem(fsys)
+		return saved.WithOOB(partial.NewID("toast", "toast.gohtml")), nil
+	})
+	shell.SetContent(content)
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+	req.Header.Set(connector.HTMXHeaderTarget.String(), "content")
+	out, err := partial.RenderWithRequest(context.Background(), req, shell)
+	if err != nil {
+		t.Fatalf("RenderWithRequest() error = %v", err)
+	}
+	want := `<div id="content">saved</div><div id="toast" hx-swap-oob="true">Saved!</div>`
+	if string(out) != want {
+		t.Fatalf("output = %q, want %q", out, want)
+	}
+}
+
+func TestRegisteredActionRunsOnRequestedTarget(t *testing.T) {
+	fsys := fstest.MapFS{
+		"shell.gohtml":     &fstest.MapFile{Data: []byte(`<main>{{ content }}</main>`)},
+		"content.gohtml":   &fstest.MapFile{Data: []byte(`<div id="content">content</div>`)},
+		"signedout.gohtml": &fstest.MapFile{Data: []byte(`<div id="content">signed out</div>`)},
+	}
+	shell := partial.NewID("shell", "shell.gohtml").
+		SetFileSystem(fsys).
+		SetConnector(connector.NewHTMX(nil)).
+		SetFunc(FuncMap()).
+		Use(Stage())
+	Register(shell, "logout", func(ctx context.Context, p *partial.Partial, runtime *partial.Runtime) (*partial.Partial, error) {
+		return partial.NewID(p.PartialID(), "signedout.gohtml").SetFileSystem(fsys), nil
+	})
+	shell.SetContent(partial.NewID("content", "content.gohtml"))
+
+	tests := []struct {
+		name   string
+		action string
+		want   string
+	}{
+		{name: "registered action", action: "logout", want: `<div id="content">signed out</div>`},
+		{name: "unknown action", action: "save", want: `<div id="content">content</div>`},
+		{name: "no action", want: `<div id="content">content</div>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+			req.Header.Set(connector.HTMXHeaderTarget.String(), "content")
+			if tt.action != "" {
+				req.Header.Set(connector.HeaderAction.String(), tt.action)
+			}
+			out, err := partial.RenderWithRequest(context.Background(), req, shell)
+			if err != nil {
+				t.Fatalf("RenderWithRequest() error = %v", err)
+			}
+			if string(out) != tt.want {
+				t.Fatalf("output = %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisteredActionFollowsAliasesAndDefaultTarget(t *testing.T) {
+	fsys := fstest.MapFS{
+		"shell.gohtml":     &fstest.MapFile{Data: []byte(`<main>{{ content }}</main>`)},
+		"content.gohtml":   &fstest.MapFile{Data: []byte(`<div id="content">content</div>`)},
+		"signedout.gohtml": &fstest.MapFile{Data: []byte(`<div id="content">signed out</div>`)},
+	}
+	shell := partial.NewID("shell", "shell.gohtml").
+		SetFileSystem(fsys).
+		SetConnector(connector.NewHTMX(nil)).
+		SetDefaultTarget("content").
+		Use(Stage())
+	Register(shell, "logout", func(ctx context.Context, p *partial.Partial, runtime *partial.Runtime) (*partial.Partial, error) {
+		return partial.NewID(p.PartialID(), "signedout.gohtml").SetFileSystem(fsys), nil
+	})
+	shell.SetContent(partial.NewID("content", "content.gohtml").AddAlias("main-content"))
+
+	for _, target := range []string{"main-content", ""} {
+		t.Run("target "+target, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+			if target != "" {
+				req.Header.Set(connector.HTMXHeaderTarget.String(), target)
+			}
+			req.Header.Set(connector.HeaderAction.String(), "logout")
+			out, err := partial.RenderWithRequest(context.Background(), req, shell)
+			if err != nil {
+				t.Fatalf("RenderWithRequest() error = %v", err)
+			}
+			if want := `<div id="content">signed out</div>`; string(out) != want {
+				t.Fatalf("output = %q, want %q", out, want)
+			}
+		})
+	}
+}
+
+func TestRegisterOnParentReachesExistingChildRegistries(t *testing.T) {
+	fsys := fstest.MapFS{
+		"shell.gohtml":   &fstest.MapFile{Data: []byte(`<main>{{ content }}</main>`)},
+		"content.gohtml": &fstest.MapFile{Data: []byte(`content`)},
+		"result.gohtml":  &fstest.MapFile{Data: []byte(`{{ . }}`)},
+	}
+	result := func(text string) Action {
+		return func(ctx context.Context, p *partial.Partial, runtime *partial.Runtime) (*partial.Partial, error) {
+			return partial.NewID(p.PartialID(), "result.gohtml").
+				SetFileSystem(fsys).
+				SetDot(text), nil
+		}
+	}
+	shell := partial.NewID("shell", "shell.gohtml").
+		SetFileSystem(fsys).
+		SetConnector(connector.NewHTMX(nil)).
+		Use(Stage())
+	content := partial.NewID("content", "content.gohtml")
+	shell.SetContent(content)
+	Register(content, "save", result("saved"))
+	Register(shell, "logout", result("signed out"))
+
+	tests := []struct {
+		action string
+		want   string
+	}{
+		{action: "save", want: "saved"},
+		{action: "logout", want: "signed out"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+			req.Header.Set(connector.HTMXHeaderTarget.String(), "content")
+			req.Header.Set(connector.HeaderAction.String(), tt.action)
+			out, err := partial.RenderWithRequest(context.Background(), req, shell)
+			if err != nil {
+				t.Fatalf("RenderWithRequest() error = %v", err)
+			}
+			if string(out) != tt.want {
+				t.Fatalf("output = %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestActionReturningNoContentWrites204(t *testing.T) {
+	fsys := fstest.MapFS{
+		"heartbeat.gohtml": &fstest.MapFile{Data: []byte(`alive`)},
+	}
+	heartbeat := partial.NewID("heartbeat", "heartbeat.gohtml").
+		SetFileSystem(fsys).
+		Use(Stage())
+	WithAction(heartbeat, func(ctx context.Context, p *partial.Partial, runtime *partial.Runtime) (*partial.Partial, error) {
+		return nil, partial.ErrNoContent
+	})
+
+	rec := httptest.NewRecorder()
+	if err := partial.Write(context.Background(), rec, httptest.NewRequest(http.MethodPost, "/heartbeat", nil), heartbeat); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if rec.Body.Len() != 0 {
+		t.Fatalf("body = %q, want empty", rec.Body.String())
+	}
+}
+
+func TestActionCanSwapToDifferentLayout(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.gohtml":       &fstest.MapFile{Data: []byte(`<body class="app"><nav>menu</nav>{{ content }}</body>`)},
+		"dashboard.gohtml": &fstest.MapFile{Data: []byte(`<main>dashboard</main>`)},
+		"login.gohtml":     &fstest.MapFile{Data: []byte(`<body class="login">{{ content }}</body>`)},
+		"form.gohtml":      &fstest.MapFile{Data: []byte(`<form>sign in</form>`)},
+	}
+	newPage := func(signedIn bool) *partial.Partial {
+		app := partial.NewID("app", "app.gohtml").
+			SetFileSystem(fsys).
+			SetFunc(FuncMap()).
+			Use(Stage())
+		dashboard := partial.NewID("dashboard", "dashboard.gohtml")
+		WithAction(dashboard, func(ctx context.Context, p *partial.Partial, runtime *partial.Runtime) (*partial.Partial, error) {
+			if signedIn {
+				return nil, nil
+			}
+			login := partial.NewID("login", "login.gohtml")
+			login.SetContent(partial.NewID("form", "form.gohtml"))
+			return AsLayout(login), nil
+		})
+		return app.SetContent(dashboard)
+	}
+
+	for signedIn, want := range map[bool]string{
+		true:  `<body class="app"><nav>menu</nav><main>dashboard</main></body>`,
+		false: `<body class="login"><form>sign in</form></body>`,
+	} {
+		out, err := partial.RenderWithRequest(context.Background(), httptest.NewRequest(http.MethodGet, "/", nil), newPage(signedIn))
+		if err != nil {
+			t.Fatalf("RenderWithRequest(signedIn=%v) error = %v", signedIn, err)
+		}
+		if string(out) != want {
+			t.Fatalf("RenderWithRequest(signedIn=%v) = %q, want %q", signedIn, out, want)
+		}
+	}
+}
+
+func TestLayoutChildIsNotALayout(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.gohtml":       &fstest.MapFile{Data: []byte(`<main>{{ content }}</main>`)},
+		"dashboard.gohtml": &fstest.MapFile{Data: []byte(`<div>dashboard</div>`)},
+		"login.gohtml":     &fstest.MapFile{Data: []byte(`<body class="login">{{ content }}</body>`)},
+		"other.gohtml":     &fstest.MapFile{Data: []byte(`<div>other</div>`)},
+	}
+	login := AsLayout(partial.NewID("login", "login.gohtml").SetFileSystem(fsys))
+	other := partial.NewID("other", "other.gohtml")
+	login.SetContent(other)
+
+	app := partial.NewID("app", "app.gohtml").
+		SetFileSystem(fsys).
+		SetFunc(FuncMap()).
+		Use(Stage())
+	dashboard := partial.NewID("dashboard", "dashboard.gohtml")
+	WithAction(dashboard, func(ctx context.Context, p *partial.Partial, runtime *partial.Runtime) (*partial.Partial, error) {
+		return other, nil
+	})
+	app.SetContent(dashboard)
+
+	out, err := partial.RenderWithRequest(context.Background(), httptest.NewRequest(http.MethodGet, "/", nil), app)
+	if err != nil {
+		t.Fatalf("RenderWithRequest() error = %v", err)
+	}
+	if want := `<main><div>other</div></main>`; string(out) != want {
+		t.Fatalf("RenderWithRequest() = %q, want %q", out, want)
+	}
+}
+
+func TestPipelineStopsAtFailingStep(t *testing.T) {
+	fsys := fstest.MapFS{
+		"signup.gohtml": &fstest.MapFile{Data: []byte(`<p>welcome</p>`)},
+	}
+	signup := partial.NewID("signup", "signup.gohtml").
+		SetFileSystem(fsys).
+		Use(Stage(), exterrors.Stage(exterrors.WithMode(exterrors.ModeDetailed)))
+
+	persisted := false
+	WithPipeline(signup,
+		func(ctx context.Context, p *partial.Partial, runtime *partial.Runtime) error {
+			return errors.New("email is required")
+		},
+		func(ctx context.Context, p *partial.Partial, runtime *partial.Runtime) error {
+			persisted = true
+			return nil
+		},
+	)
+
+	rec := httptest.NewRecorder()
+	err := partial.Write(context.Background(), rec, httptest.NewRequest(http.MethodPost, "/signup", nil), signup)
+	if err == nil || !strings.Contains(err.Error(), "email is required") {
+		t.Fatalf("Write() error = %v, want the step error", err)
+	}
+	if persisted {
+		t.Fatal("second step ran after the first step failed")
+	}
+	body := rec.Body.String()
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(body, "pipeline step 1: email is required") {
+		t.Fatalf("status = %d, body = %q, want the error page for step 1", rec.Code, body)
+	}
+	if strings.Contains(body, "welcome") {
+		t.Fatalf("body = %q, want the partial not to render", body)
+	}
+}
+
+func TestTemplateActionReturningNilRendersNothing(t *testing.T) {
+	fsys := fstest.MapFS{
+		"page.gohtml": &fstest.MapFile{Data: []byte(`[{{ action }}]`)},
+	}
+	p := partial.NewID("page", "page.gohtml").
+		SetFileSystem(fsys).
+		SetFunc(FuncMap()).
+		Use(Stage())
+	WithTemplateAction(p, func(ctx context.Context, p *partial.Partial, runtime *partial.Runtime) (*partial.Partial, error) {
+		return nil, nil
+	})
+
+	var events []partial.Event
+	ctx := partial.WithEventSink(context.Background(), partial.EventSinkFunc(func(ctx *partial.RenderContext, event partial.Event) {
+		events = append(events, event)
+	}))
+	out, err := partial.Render(ctx, p)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if string(out) != "[]" {
+		t.Fatalf("output = %q", out)
+	}
+	found := false
+	for _, event := range events {
+		if event.Kind == EventActionEmpty && event.Level == partial.EventDebug {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("events = %#v, want %s", events, EventActionEmpty)
+	}
+}
+
 func TestTemplateActionAndHelpers(t *testing.T) {
 	fsys := fstest.MapFS{
 		"page.gohtml":   &fstest.MapFile{Data: []byte(`{{ actionHeader }}={{ actionValue }}:{{ action }}`)},
@@ -0,0 +1,224 @@
+// Package cache provides experimental rendered-output caching for partials.
+package cache
+
+import (
+	"container/list"
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+
+	partial "github.com/donseba/go-partial"
+)
+
+type (
+	// KeyFunc returns the cache key of a render, or false to bypass the cache.
+	// The key must identify everything the output depends on, such as the
+	// user, the session, and the partial's data; the cache adds the partial
+	// ID itself. Keys derived only from the request URL leak one user's HTML
+	// to another.
+	KeyFunc func(ctx *partial.RenderContext) (string, bool)
+
+	// Store keeps rendered partial output in memory, indexed by tag so related
+	// fragments can be invalidated together. It holds at most a fixed number
+	// of entries and evicts the least recently used one when full.
+	Store struct {
+		mu         sync.Mutex
+		maxEntries int
+		ttl        time.Duration
+		now        func() time.Time
+		order      *list.List
+		entries    map[string]*list.Element
+		tags       map[string]map[string]struct{}
+	}
+
+	entry struct {
+		key     string
+		out     template.HTML
+		tags    []string
+		expires time.Time
+	}
+
+	config struct {
+		id   string
+		key  KeyFunc
+		tags []string
+		cond func(r *http.Request) bool
+	}
+
+	extensionKey struct{}
+)
+
+// DefaultMaxEntries is the capacity NewStore uses when maxEntries is not
+// positive.
+const DefaultMaxEntries = 1024
+
+// NewStore creates an empty output cache holding at most maxEntries entries.
+// Entries expire ttl after they are stored; a ttl of zero keeps them until
+// they are evicted or invalidated.
+func NewStore(maxEntries int, ttl time.Duration) *Store {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &Store{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		now:        time.Now,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+		tags:       make(map[string]map[string]struct{}),
+	}
+}
+
+// WithTags enables output caching for p, keyed by key and labelled with tags.
+// Without a key function p is not cached. Children do not inherit the cache
+// configuration.
+func WithTags(p *partial.Partial, key KeyFunc, tags ...string) *partial.Partial {
+	if p == nil {
+		return nil
+	}
+	cfg, _ := cacheConfig(p)
+	cfg.id = p.PartialID()
+	cfg.key = key
+	cfg.tags = append([]string(nil), tags...)
+	return p.SetExtension(extensionKey{}, cfg)
+}
+
+// If limits output caching for p to renders where cond returns true, for
+// example GET requests from anonymous users. Other renders bypass the cache
+// and are not stored. cond receives nil for renders without a request.
+func If(p *partial.Partial, cond func(r *http.Request) bool) *partial.Partial {
+	if p == nil {
+		return nil
+	}
+	cfg, ok := cacheConfig(p)
+	if !ok {
+		cfg = config{id: p.PartialID()}
+	}
+	cfg.cond = cond
+	return p.SetExtension(extensionKey{}, cfg)
+}
+
+// InvalidateTags drops every cached entry labelled with any of tags.
+func (s *Store) InvalidateTags(tags ...string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			if elem, ok := s.entries[key]; ok {
+				s.removeLocked(elem)
+			}
+		}
+	}
+}
+
+// Len returns the number of cached entries.
+func (s *Store) Len() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+func (s *Store) load(key string) (template.HTML, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return "", false
+	}
+	e := elem.Value.(*entry)
+	if !e.expires.IsZero() && !s.now().Before(e.expires) {
+		s.removeLocked(elem)
+		return "", false
+	}
+	s.order.MoveToFront(elem)
+	return e.out, true
+}
+
+func (s *Store) store(key string, out template.HTML, tags []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		s.removeLocked(elem)
+	}
+	e := &entry{key: key, out: out, tags: tags}
+	if s.ttl > 0 {
+		e.expires = s.now().Add(s.ttl)
+	}
+	s.entries[key] = s.order.PushFront(e)
+	for _, tag := range tags {
+		if s.tags[tag] == nil {
+			s.tags[tag] = make(map[string]struct{})
+		}
+		s.tags[tag][key] = struct{}{}
+	}
+	for len(s.entries) > s.maxEntries {
+		s.removeLocked(s.order.Back())
+	}
+}
+
+// removeLocked drops the entry held by elem from the store and from the index
+// of every tag it is labelled with.
+func (s *Store) removeLocked(elem *list.Element) {
+	e := elem.Value.(*entry)
+	s.order.Remove(elem)
+	delete(s.entries, e.key)
+	for _, tag := range e.tags {
+		delete(s.tags[tag], e.key)
+		if len(s.tags[tag]) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+}
+
+// Stage serves cached output for configured partials and stores successful
+// renders in store.
+func Stage(store *Store) partial.RenderStage {
+	return partial.RenderStageHooks{
+		RenderFunc: func(ctx *partial.RenderContext, next partial.RenderNext) (template.HTML, error) {
+			if store == nil || ctx == nil || ctx.Partial == nil || ctx.Kind != partial.RenderKindPartial {
+				return next(ctx)
+			}
+			cfg, ok := cacheConfig(ctx.Partial)
+			if !ok || cfg.key == nil || (cfg.cond != nil && !cfg.cond(ctx.Request)) {
+				return next(ctx)
+			}
+			key, ok := cfg.key(ctx)
+			if !ok {
+				return next(ctx)
+			}
+
+			key = cfg.id + "|" + key
+			if out, ok := store.load(key); ok {
+				return out, nil
+			}
+			out, err := next(ctx)
+			if err != nil {
+				return out, err
+			}
+			store.store(key, out, cfg.tags)
+			return out, nil
+		},
+	}
+}
+
+func cacheConfig(p *partial.Partial) (config, bool) {
+	value, ok := p.Extension(extensionKey{})
+	if !ok {
+		return config{}, false
+	}
+	cfg, ok := value.(config)
+	if !ok || cfg.id != p.PartialID() {
+		return config{}, false
+	}
+	return cfg, true
+}
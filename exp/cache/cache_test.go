@@ -0,0 +1,271 @@
+package cache
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	partial "github.com/donseba/go-partial"
+)
+
+// pathKey keys renders by URL path. It is only safe for output that is the
+// same for every user.
+func pathKey(ctx *partial.RenderContext) (string, bool) {
+	if ctx.URL == nil {
+		return "", false
+	}
+	return ctx.URL.Path, true
+}
+
+// userKey keys renders by the requesting user and the URL path.
+func userKey(ctx *partial.RenderContext) (string, bool) {
+	if ctx.Request == nil {
+		return "", false
+	}
+	return ctx.Request.Header.Get("X-User") + "|" + ctx.Request.URL.Path, true
+}
+
+// countingTree returns a root using store whose templates can call count, and
+// a pointer to the number of template executions.
+func countingTree(store *Store, files map[string]string) (*partial.Partial, *int) {
+	fsys := fstest.MapFS{}
+	for name, data := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(data)}
+	}
+	calls := 0
+	root := partial.New().
+		SetFileSystem(fsys).
+		SetFunc(template.FuncMap{"count": func() int {
+			calls++
+			return calls
+		}}).
+		Use(Stage(store))
+	return root, &calls
+}
+
+func TestInvalidateTagsDropsTaggedEntries(t *testing.T) {
+	fsys := fstest.MapFS{
+		"list.gohtml": &fstest.MapFile{Data: []byte(`list:{{ count }}`)},
+		"card.gohtml": &fstest.MapFile{Data: []byte(`card:{{ count }}`)},
+	}
+	calls := 0
+	store := NewStore(0, 0)
+	root := partial.New().
+		SetFileSystem(fsys).
+		SetFunc(template.FuncMap{"count": func() int {
+			calls++
+			return calls
+		}}).
+		Use(Stage(store))
+
+	list := WithTags(partial.NewID("list", "list.gohtml"), pathKey, "products")
+	card := WithTags(partial.NewID("card", "card.gohtml"), pathKey, "products", "cards")
+	root.With(list).With(card)
+
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	render := func(p *partial.Partial) string {
+		t.Helper()
+		out, err := partial.RenderWithRequest(context.Background(), req, p)
+		if err != nil {
+			t.Fatalf("RenderWithRequest() error = %v", err)
+		}
+		return string(out)
+	}
+
+	if got := render(list); got != "list:1" {
+		t.Fatalf("first list render = %q", got)
+	}
+	if got := render(card); got != "card:2" {
+		t.Fatalf("first card render = %q", got)
+	}
+	if got := render(list); got != "list:1" {
+		t.Fatalf("cached list render = %q", got)
+	}
+	if store.Len() != 2 {
+		t.Fatalf("store entries = %d, want 2", store.Len())
+	}
+
+	store.InvalidateTags("products")
+	if store.Len() != 0 {
+		t.Fatalf("store entries after invalidation = %d, want 0", store.Len())
+	}
+	if got := render(list); got != "list:3" {
+		t.Fatalf("list render after invalidation = %q", got)
+	}
+	if got := render(card); got != "card:4" {
+		t.Fatalf("card render after invalidation = %q", got)
+	}
+}
+
+func TestIfBypassesCacheForPost(t *testing.T) {
+	fsys := fstest.MapFS{
+		"list.gohtml": &fstest.MapFile{Data: []byte(`list:{{ count }}`)},
+	}
+	calls := 0
+	store := NewStore(0, 0)
+	root := partial.New().
+		SetFileSystem(fsys).
+		SetFunc(template.FuncMap{"count": func() int {
+			calls++
+			return calls
+		}}).
+		Use(Stage(store))
+
+	list := If(WithTags(partial.NewID("list", "list.gohtml"), pathKey, "products"), func(r *http.Request) bool {
+		return r != nil && r.Method == http.MethodGet
+	})
+	root.With(list)
+
+	render := func(method string) string {
+		t.Helper()
+		req := httptest.NewRequest(method, "/products", nil)
+		out, err := partial.RenderWithRequest(context.Background(), req, list)
+		if err != nil {
+			t.Fatalf("RenderWithRequest(%s) error = %v", method, err)
+		}
+		return string(out)
+	}
+
+	if got := render(http.MethodGet); got != "list:1" {
+		t.Fatalf("first GET render = %q", got)
+	}
+	if got := render(http.MethodPost); got != "list:2" {
+		t.Fatalf("POST render = %q, want cache bypass", got)
+	}
+	if got := render(http.MethodGet); got != "list:1" {
+		t.Fatalf("second GET render = %q, want cached output", got)
+	}
+	if store.Len() != 1 {
+		t.Fatalf("store entries = %d, want 1", store.Len())
+	}
+
+	store.InvalidateTags("products")
+	if store.Len() != 0 {
+		t.Fatalf("store entries after invalidation = %d, want 0", store.Len())
+	}
+}
+
+func TestKeyFuncSeparatesUsers(t *testing.T) {
+	store := NewStore(0, 0)
+	root, _ := countingTree(store, map[string]string{"inbox.gohtml": `inbox:{{ count }}`})
+	inbox := WithTags(partial.NewID("inbox", "inbox.gohtml"), userKey, "inbox")
+	root.With(inbox)
+
+	render := func(user string) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/inbox", nil)
+		req.Header.Set("X-User", user)
+		out, err := partial.RenderWithRequest(context.Background(), req, inbox)
+		if err != nil {
+			t.Fatalf("RenderWithRequest(%s) error = %v", user, err)
+		}
+		return string(out)
+	}
+
+	if got := render("ada"); got != "inbox:1" {
+		t.Fatalf("ada render = %q", got)
+	}
+	if got := render("bob"); got != "inbox:2" {
+		t.Fatalf("bob render = %q, want his own output", got)
+	}
+	if got := render("ada"); got != "inbox:1" {
+		t.Fatalf("second ada render = %q, want cached output", got)
+	}
+}
+
+func TestWithoutKeyFuncBypassesCache(t *testing.T) {
+	store := NewStore(0, 0)
+	root, _ := countingTree(store, map[string]string{"list.gohtml": `list:{{ count }}`})
+	list := WithTags(partial.NewID("list", "list.gohtml"), nil, "products")
+	root.With(list)
+
+	for want := 1; want <= 2; want++ {
+		out, err := partial.Render(context.Background(), list)
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		if got := string(out); got != "list:"+strconv.Itoa(want) {
+			t.Fatalf("render %d = %q, want uncached output", want, got)
+		}
+	}
+	if store.Len() != 0 {
+		t.Fatalf("store entries = %d, want 0", store.Len())
+	}
+}
+
+func TestStoreEvictsLeastRecentlyUsedFromEveryTag(t *testing.T) {
+	store := NewStore(2, 0)
+	root, _ := countingTree(store, map[string]string{"list.gohtml": `list:{{ count }}`})
+	list := WithTags(partial.NewID("list", "list.gohtml"), pathKey, "products", "home")
+	root.With(list)
+
+	render := func(path string) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		out, err := partial.RenderWithRequest(context.Background(), req, list)
+		if err != nil {
+			t.Fatalf("RenderWithRequest(%s) error = %v", path, err)
+		}
+		return string(out)
+	}
+
+	render("/a")
+	render("/b")
+	if got := render("/a"); got != "list:1" {
+		t.Fatalf("cached /a render = %q", got)
+	}
+	render("/c")
+	if store.Len() != 2 {
+		t.Fatalf("store entries = %d, want 2", store.Len())
+	}
+	for _, tag := range []string{"products", "home"} {
+		if _, ok := store.tags[tag]["list|/b"]; ok {
+			t.Fatalf("evicted key still indexed under tag %q", tag)
+		}
+		if len(store.tags[tag]) != 2 {
+			t.Fatalf("tag %q indexes %d keys, want 2", tag, len(store.tags[tag]))
+		}
+	}
+	if got := render("/a"); got != "list:1" {
+		t.Fatalf("recently used /a render = %q, want cached output", got)
+	}
+
+	store.InvalidateTags("products")
+	if store.Len() != 0 || len(store.tags) != 0 {
+		t.Fatalf("after invalidation: %d entries, %d tags, want none", store.Len(), len(store.tags))
+	}
+}
+
+func TestStoreExpiresEntriesAfterTTL(t *testing.T) {
+	store := NewStore(0, time.Minute)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	root, _ := countingTree(store, map[string]string{"list.gohtml": `list:{{ count }}`})
+	list := WithTags(partial.NewID("list", "list.gohtml"), pathKey, "products")
+	root.With(list)
+
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	render := func() string {
+		t.Helper()
+		out, err := partial.RenderWithRequest(context.Background(), req, list)
+		if err != nil {
+			t.Fatalf("RenderWithRequest() error = %v", err)
+		}
+		return string(out)
+	}
+
+	render()
+	now = now.Add(59 * time.Second)
+	if got := render(); got != "list:1" {
+		t.Fatalf("render before expiry = %q, want cached output", got)
+	}
+	now = now.Add(time.Second)
+	if got := render(); got != "list:2" {
+		t.Fatalf("render after expiry = %q, want fresh output", got)
+	}
+}
@@ -0,0 +1,104 @@
+// Package csp provides experimental Content Security Policy nonce helpers for
+// templates.
+package csp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"html/template"
+
+	partial "github.com/donseba/go-partial"
+)
+
+type (
+	nonceKey struct{}
+
+	scriptNonceKey struct{}
+
+	styleNonceKey struct{}
+)
+
+// FuncMap returns placeholders for the nonce template helpers.
+//
+// go-doc:funcmap
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"nonce":       Nonce,
+		"scriptNonce": ScriptNonce,
+		"styleNonce":  StyleNonce,
+	}
+}
+
+// Stage installs the nonce template helpers from the render context.
+func Stage() partial.RenderStage {
+	return partial.RenderStageHooks{
+		PrepareFunc: func(ctx *partial.RenderContext) (*partial.RenderContext, error) {
+			ctx.SetFunc("nonce", func() string { return Nonce(ctx) })
+			ctx.SetFunc("scriptNonce", func() string { return ScriptNonce(ctx) })
+			ctx.SetFunc("styleNonce", func() string { return StyleNonce(ctx) })
+			return ctx, nil
+		},
+	}
+}
+
+// NewNonce returns a random base64 nonce suitable for one response.
+func NewNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// WithNonce stores the nonce used for both scripts and styles on a context.
+func WithNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, nonceKey{}, nonce)
+}
+
+// WithScriptNonce stores a nonce used for scripts only, overriding WithNonce.
+func WithScriptNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, scriptNonceKey{}, nonce)
+}
+
+// WithStyleNonce stores a nonce used for styles only, overriding WithNonce.
+func WithStyleNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, styleNonceKey{}, nonce)
+}
+
+// Nonce returns the shared nonce for a render context.
+//
+// go-doc:sig func() string
+func Nonce(ctx ...*partial.RenderContext) string {
+	return nonceValue(ctx, nonceKey{})
+}
+
+// ScriptNonce returns the script nonce for a render context, falling back to
+// the shared nonce.
+//
+// go-doc:sig func() string
+func ScriptNonce(ctx ...*partial.RenderContext) string {
+	if nonce := nonceValue(ctx, scriptNonceKey{}); nonce != "" {
+		return nonce
+	}
+	return Nonce(ctx...)
+}
+
+// StyleNonce returns the style nonce for a render context, falling back to
+// the shared nonce.
+//
+// go-doc:sig func() string
+func StyleNonce(ctx ...*partial.RenderContext) string {
+	if nonce := nonceValue(ctx, styleNonceKey{}); nonce != "" {
+		return nonce
+	}
+	return Nonce(ctx...)
+}
+
+func nonceValue(ctx []*partial.RenderContext, key any) string {
+	if len(ctx) == 0 || ctx[0] == nil || ctx[0].Context == nil {
+		return ""
+	}
+	nonce, _ := ctx[0].Context.Value(key).(string)
+	return nonce
+}
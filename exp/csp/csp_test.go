@@ -0,0 +1,68 @@
+package csp
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	partial "github.com/donseba/go-partial"
+)
+
+func TestNonceRendersOnStyleAndScriptTags(t *testing.T) {
+	fsys := fstest.MapFS{
+		"page.gohtml": &fstest.MapFile{Data: []byte(`<style nonce="{{ styleNonce }}"></style><script nonce="{{ scriptNonce }}"></script><link nonce="{{ nonce }}">`)},
+	}
+	p := partial.NewID("page", "page.gohtml").
+		SetFileSystem(fsys).
+		SetFunc(FuncMap()).
+		Use(Stage())
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{
+			name: "shared nonce",
+			ctx:  WithNonce(context.Background(), "abc"),
+			want: `<style nonce="abc"></style><script nonce="abc"></script><link nonce="abc">`,
+		},
+		{
+			name: "separate nonces",
+			ctx:  WithStyleNonce(WithScriptNonce(WithNonce(context.Background(), "abc"), "js1"), "css1"),
+			want: `<style nonce="css1"></style><script nonce="js1"></script><link nonce="abc">`,
+		},
+		{
+			name: "no nonce",
+			ctx:  context.Background(),
+			want: `<style nonce=""></style><script nonce=""></script><link nonce="">`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			out, err := partial.RenderWithRequest(tt.ctx, req, p)
+			if err != nil {
+				t.Fatalf("RenderWithRequest() error = %v", err)
+			}
+			if string(out) != tt.want {
+				t.Fatalf("output = %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewNonceIsUnique(t *testing.T) {
+	a, err := NewNonce()
+	if err != nil {
+		t.Fatalf("NewNonce() error = %v", err)
+	}
+	b, err := NewNonce()
+	if err != nil {
+		t.Fatalf("NewNonce() error = %v", err)
+	}
+	if a == "" || a == b {
+		t.Fatalf("NewNonce() = %q, %q", a, b)
+	}
+}
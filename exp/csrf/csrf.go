@@ -10,12 +10,21 @@ import (
 	partial "github.com/donseba/go-partial"
 )
 
-// DefaultTokenKey is the default form/header name used by fallback tokens.
-const DefaultTokenKey = "X-CSRF-Token"
+const (
+	// DefaultTokenKey is the default form/header name used by fallback tokens.
+	DefaultTokenKey = "X-CSRF-Token"
+
+	// DefaultFieldName is the default input name rendered by csrfField.
+	DefaultFieldName = "_csrf"
+)
 
 var tokenContextKey = contextKey{}
 
-type contextKey struct{}
+type (
+	contextKey struct{}
+
+	fieldNameKey struct{}
+)
 
 // Token describes the CSRF token exposed to templates.
 type Token interface {
@@ -33,7 +42,8 @@ type defaultToken struct {
 // go-doc:funcmap
 func FuncMap() template.FuncMap {
 	return template.FuncMap{
-		"csrf": CSRF,
+		"csrf":      CSRF,
+		"csrfField": Field,
 	}
 }
 
@@ -47,11 +57,43 @@ func CSRF(ctx ...*partial.RenderContext) Token {
 	return FromContext(ctx[0].Context)
 }
 
-// Stage installs the csrf template helper from the render context.
+// Field renders a hidden input carrying the token for a render context. The
+// input name defaults to DefaultFieldName and can be changed with
+// SetFieldName.
+//
+// go-doc:sig func() html/template.HTML
+func Field(ctx ...*partial.RenderContext) template.HTML {
+	if len(ctx) == 0 || ctx[0] == nil {
+		return ""
+	}
+	token := CSRF(ctx[0]).Token(ctx[0].Context)
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(fieldName(ctx[0].Partial)) +
+		`" value="` + template.HTMLEscapeString(token) + `">`)
+}
+
+// SetFieldName configures the input name rendered by csrfField for p and its
+// children.
+func SetFieldName(p *partial.Partial, name string) *partial.Partial {
+	return p.SetExtension(fieldNameKey{}, name)
+}
+
+func fieldName(p *partial.Partial) string {
+	if p != nil {
+		if value, ok := p.Extension(fieldNameKey{}); ok {
+			if name, ok := value.(string); ok && name != "" {
+				return name
+			}
+		}
+	}
+	return DefaultFieldName
+}
+
+// Stage installs the csrf template helpers from the render context.
 func Stage() partial.RenderStage {
 	return partial.RenderStageHooks{
 		PrepareFunc: func(ctx *partial.RenderContext) (*partial.RenderContext, error) {
 			ctx.SetFunc("csrf", func() Token { return CSRF(ctx) })
+			ctx.SetFunc("csrfField", func() template.HTML { return Field(ctx) })
 			return ctx, nil
 		},
 	}
@@ -41,6 +41,42 @@ func TestRendererAddsCSRFHelper(t *testing.T) {
 	}
 }
 
+func TestCSRFFieldRendersHiddenInput(t *testing.T) {
+	fsys := fstest.MapFS{
+		"form.gohtml": &fstest.MapFile{Data: []byte(`<form>{{ csrfField }}</form>`)},
+	}
+	tests := []struct {
+		name      string
+		fieldName string
+		want      string
+	}{
+		{name: "default name", want: `<form><input type="hidden" name="_csrf" value="a&lt;b&#34;c"></form>`},
+		{name: "configured name", fieldName: "authenticity_token", want: `<form><input type="hidden" name="authenticity_token" value="a&lt;b&#34;c"></form>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := partial.NewID("root", "form.gohtml").
+				SetFileSystem(fsys).
+				SetFunc(FuncMap()).
+				Use(Stage())
+			if tt.fieldName != "" {
+				SetFieldName(root, tt.fieldName)
+			}
+			form := partial.NewID("form", "form.gohtml")
+			root.SetContent(form)
+
+			req := httptest.NewRequest("GET", "/", nil)
+			out, err := partial.RenderWithRequest(WithTokenString(context.Background(), `a<b"c`), req, form)
+			if err != nil {
+				t.Fatalf("RenderWithRequest() error = %v", err)
+			}
+			if string(out) != tt.want {
+				t.Fatalf("output = %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
 func TestWithTokenString(t *testing.T) {
 	token := FromContext(WithTokenString(context.Background(), "abc"))
 	if got := token.Token(WithTokenString(context.Background(), "abc")); got != "abc" {
@@ -0,0 +1,95 @@
+// Package flags provides experimental feature-flag template selection for
+// partials.
+package flags
+
+import (
+	"context"
+
+	partial "github.com/donseba/go-partial"
+)
+
+type (
+	// Resolver reports whether the named flag is enabled for a render.
+	Resolver func(ctx context.Context, name string) bool
+
+	config struct {
+		id          string
+		name        string
+		onTemplate  string
+		offTemplate string
+	}
+
+	extensionKey struct{}
+
+	resolverKey struct{}
+)
+
+// SetResolver configures the flag resolver for p and its children. Register it
+// on the root partial to resolve flags for the whole tree.
+func SetResolver(p *partial.Partial, resolver Resolver) *partial.Partial {
+	if p == nil {
+		return nil
+	}
+	return p.SetExtension(resolverKey{}, resolver)
+}
+
+// WithFlag makes p render onTemplate when the named flag is enabled and
+// offTemplate otherwise. Without a resolver the flag is off. Children do not
+// inherit the flag.
+func WithFlag(p *partial.Partial, name string, onTemplate, offTemplate string) *partial.Partial {
+	if p == nil {
+		return nil
+	}
+	return p.SetExtension(extensionKey{}, config{
+		id:          p.PartialID(),
+		name:        name,
+		onTemplate:  onTemplate,
+		offTemplate: offTemplate,
+	})
+}
+
+// Stage selects the template of flagged partials for each render.
+func Stage() partial.RenderStage {
+	return partial.RenderStageHooks{
+		PrepareFunc: func(ctx *partial.RenderContext) (*partial.RenderContext, error) {
+			if ctx == nil || ctx.Partial == nil || ctx.Kind != partial.RenderKindPartial {
+				return ctx, nil
+			}
+			cfg, ok := flagConfig(ctx.Partial)
+			if !ok {
+				return ctx, nil
+			}
+
+			templatePath := cfg.offTemplate
+			if enabled(ctx, cfg.name) {
+				templatePath = cfg.onTemplate
+			}
+			ctx.Partial = ctx.Partial.Clone().SetTemplates(templatePath)
+			return ctx, nil
+		},
+	}
+}
+
+func enabled(ctx *partial.RenderContext, name string) bool {
+	value, ok := ctx.Partial.Extension(resolverKey{})
+	if !ok {
+		return false
+	}
+	resolver, _ := value.(Resolver)
+	if resolver == nil {
+		return false
+	}
+	return resolver(ctx.Context, name)
+}
+
+func flagConfig(p *partial.Partial) (config, bool) {
+	value, ok := p.Extension(extensionKey{})
+	if !ok {
+		return config{}, false
+	}
+	cfg, ok := value.(config)
+	if !ok || cfg.id != p.PartialID() {
+		return config{}, false
+	}
+	return cfg, true
+}
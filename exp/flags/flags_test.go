@@ -0,0 +1,73 @@
+package flags
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	partial "github.com/donseba/go-partial"
+)
+
+type flagsKey struct{}
+
+func TestWithFlagSelectsTemplatePerRequest(t *testing.T) {
+	fsys := fstest.MapFS{
+		"page.gohtml":         &fstest.MapFile{Data: []byte(`<main>{{ content }}</main>`)},
+		"checkout.gohtml":     &fstest.MapFile{Data: []byte(`classic checkout`)},
+		"checkout_new.gohtml": &fstest.MapFile{Data: []byte(`new checkout`)},
+	}
+	root := partial.NewID("page", "page.gohtml").
+		SetFileSystem(fsys).
+		Use(Stage())
+	SetResolver(root, func(ctx context.Context, name string) bool {
+		enabled, _ := ctx.Value(flagsKey{}).(map[string]bool)
+		return enabled[name]
+	})
+	checkout := WithFlag(partial.NewID("checkout", "checkout.gohtml"), "new-checkout", "checkout_new.gohtml", "checkout.gohtml")
+	root.SetContent(checkout)
+
+	tests := []struct {
+		name    string
+		enabled map[string]bool
+		want    string
+	}{
+		{name: "flag on", enabled: map[string]bool{"new-checkout": true}, want: "<main>new checkout</main>"},
+		{name: "flag off", enabled: map[string]bool{"new-checkout": false}, want: "<main>classic checkout</main>"},
+		{name: "other flag on", enabled: map[string]bool{"dark-mode": true}, want: "<main>classic checkout</main>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), flagsKey{}, tt.enabled)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			out, err := partial.RenderWithRequest(ctx, req, root)
+			if err != nil {
+				t.Fatalf("RenderWithRequest() error = %v", err)
+			}
+			if string(out) != tt.want {
+				t.Fatalf("output = %q, want %q", out, tt.want)
+			}
+		})
+	}
+
+	if got := checkout.TemplatePaths(); len(got) != 1 || got[0] != "checkout.gohtml" {
+		t.Fatalf("flagged partial templates changed to %v", got)
+	}
+}
+
+func TestWithFlagIsOffWithoutResolver(t *testing.T) {
+	fsys := fstest.MapFS{
+		"on.gohtml":  &fstest.MapFile{Data: []byte(`on`)},
+		"off.gohtml": &fstest.MapFile{Data: []byte(`off`)},
+	}
+	p := WithFlag(partial.NewID("banner", "on.gohtml").SetFileSystem(fsys).Use(Stage()), "promo", "on.gohtml", "off.gohtml")
+
+	out, err := partial.Render(context.Background(), p)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if string(out) != "off" {
+		t.Fatalf("output = %q, want %q", out, "off")
+	}
+}
@@ -0,0 +1,515 @@
+// Package inlinecss provides experimental CSS inlining of rendered partials,
+// for HTML email where clients strip <style> elements.
+//
+// The built-in inliner returned by New is dependency-free and handles simple
+// selectors: a tag, an #id, classes, or a compound of those such as p.note.
+// Rules with other selectors are ignored. Applications that need full CSS
+// support can plug in their own Inliner with With.
+package inlinecss
+
+import (
+	"html"
+	"html/template"
+	"slices"
+	"strings"
+
+	partial "github.com/donseba/go-partial"
+	"github.com/donseba/go-partial/internal/htmlscan"
+)
+
+type (
+	// Inliner rewrites rendered HTML so styles live in style attributes.
+	Inliner interface {
+		Inline(html string) (string, error)
+	}
+
+	// InlinerFunc adapts a function to Inliner.
+	InlinerFunc func(html string) (string, error)
+
+	config struct {
+		id      string
+		inliner Inliner
+	}
+
+	extensionKey struct{}
+)
+
+// Inline calls f(html).
+func (f InlinerFunc) Inline(html string) (string, error) {
+	return f(html)
+}
+
+// With makes Stage pass the rendered output of p through inliner. Children do
+// not inherit the inliner; configure the partial whose output is the email.
+func With(p *partial.Partial, inliner Inliner) *partial.Partial {
+	if p == nil {
+		return nil
+	}
+	return p.SetExtension(extensionKey{}, config{id: p.PartialID(), inliner: inliner})
+}
+
+// InlineCSS inlines stylesheet into the rendered output of p with the
+// built-in inliner.
+func InlineCSS(p *partial.Partial, stylesheet string) *partial.Partial {
+	return With(p, New(stylesheet))
+}
+
+// Stage inlines styles into the output of partials configured with With or
+// InlineCSS.
+func Stage() partial.RenderStage {
+	return partial.RenderStageHooks{
+		RenderFunc: func(ctx *partial.RenderContext, next partial.RenderNext) (template.HTML, error) {
+			if ctx == nil || ctx.Partial == nil || ctx.Kind != partial.RenderKindPartial {
+				return next(ctx)
+			}
+			cfg, ok := inlineConfig(ctx.Partial)
+			if !ok || cfg.inliner == nil {
+				return next(ctx)
+			}
+			out, err := next(ctx)
+			if err != nil {
+				return out, err
+			}
+			inlined, err := cfg.inliner.Inline(string(out))
+			if err != nil {
+				return "", err
+			}
+			return template.HTML(inlined), nil
+		},
+	}
+}
+
+func inlineConfig(p *partial.Partial) (config, bool) {
+	value, ok := p.Extension(extensionKey{})
+	if !ok {
+		return config{}, false
+	}
+	cfg, ok := value.(config)
+	if !ok || cfg.id != p.PartialID() {
+		return config{}, false
+	}
+	return cfg, true
+}
+
+type (
+	selector struct {
+		tag     string
+		id      string
+		classes []string
+	}
+
+	rule struct {
+		selector     selector
+		declarations string
+		specificity  int
+		order        int
+	}
+
+	stylesheetInliner struct {
+		rules []rule
+	}
+)
+
+// New returns the built-in inliner for stylesheet.
+func New(stylesheet string) Inliner {
+	return &stylesheetInliner{rules: parseStylesheet(stylesheet)}
+}
+
+func parseStylesheet(stylesheet string) []rule {
+	css := stripComments(stylesheet)
+	var rules []rule
+	for css != "" {
+		open := strings.IndexByte(css, '{')
+		if open < 0 {
+			break
+		}
+		prelude := strings.TrimSpace(css[:open])
+		end := blockEnd(css, open)
+		body := css[open+1 : end]
+		if end < len(css) {
+			css = css[end+1:]
+		} else {
+			css = ""
+		}
+		if strings.HasPrefix(prelude, "@") {
+			continue
+		}
+		declarations := normalizeDeclarations(body)
+		if declarations == "" {
+			continue
+		}
+		for _, part := range strings.Split(prelude, ",") {
+			sel, ok := parseSelector(strings.TrimSpace(part))
+			if !ok {
+				continue
+			}
+			rules = append(rules, rule{
+				selector:     sel,
+				declarations: declarations,
+				specificity:  sel.specificity(),
+				order:        len(rules),
+			})
+		}
+	}
+	return rules
+}
+
+// stripComments removes CSS comments from css, leaving comment markers inside
+// strings alone.
+func stripComments(css string) string {
+	var b strings.Builder
+	for i := 0; i < len(css); {
+		switch {
+		case css[i] == '"' || css[i] == '\'':
+			end := skipString(css, i)
+			b.WriteString(css[i:end])
+			i = end
+		case strings.HasPrefix(css[i:], "/*"):
+			end := strings.Index(css[i+2:], "*/")
+			if end < 0 {
+				return b.String()
+			}
+			i += 2 + end + 2
+		default:
+			b.WriteByte(css[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// skipString returns the index just past the string opened by the quote at
+// css[i], or len(css) when the string is not closed.
+func skipString(css string, i int) int {
+	quote := css[i]
+	for i++; i < len(css); i++ {
+		switch css[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+	return len(css)
+}
+
+// blockEnd returns the index of the brace closing the block opened at open,
+// or len(css) when the block is not closed.
+func blockEnd(css string, open int) int {
+	depth := 0
+	for i := open; i < len(css); i++ {
+		switch css[i] {
+		case '"', '\'':
+			i = skipString(css, i) - 1
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(css)
+}
+
+// splitDeclarations splits a declaration block on the semicolons that end
+// declarations, keeping those inside strings and parentheses such as
+// url(data:image/png;base64,...).
+func splitDeclarations(body string) []string {
+	var declarations []string
+	depth, start := 0, 0
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '"', '\'':
+			i = skipString(body, i) - 1
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ';':
+			if depth == 0 {
+				declarations = append(declarations, body[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(declarations, body[start:])
+}
+
+func normalizeDeclarations(body string) string {
+	var parts []string
+	for _, declaration := range splitDeclarations(body) {
+		name, value, ok := strings.Cut(declaration, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" || value == "" {
+			continue
+		}
+		parts = append(parts, name+": "+value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func parseSelector(s string) (selector, bool) {
+	if s == "" || s == "*" {
+		return selector{}, false
+	}
+	var sel selector
+	i := 0
+	for i < len(s) {
+		kind := s[i]
+		if kind == '.' || kind == '#' {
+			i++
+		}
+		start := i
+		for i < len(s) && isNameChar(s[i]) {
+			i++
+		}
+		name := s[start:i]
+		if name == "" {
+			return selector{}, false
+		}
+		switch {
+		case kind == '.':
+			sel.classes = append(sel.classes, name)
+		case kind == '#':
+			if sel.id != "" {
+				return selector{}, false
+			}
+			sel.id = name
+		case start == 0:
+			sel.tag = strings.ToLower(name)
+		default:
+			return selector{}, false
+		}
+	}
+	return sel, true
+}
+
+func isNameChar(c byte) bool {
+	return c == '-' || c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func (s selector) specificity() int {
+	specificity := len(s.classes) * 10
+	if s.id != "" {
+		specificity += 100
+	}
+	if s.tag != "" {
+		specificity++
+	}
+	return specificity
+}
+
+func (s selector) matches(tag, id string, classes []string) bool {
+	if s.tag != "" && s.tag != tag {
+		return false
+	}
+	if s.id != "" && s.id != id {
+		return false
+	}
+	for _, class := range s.classes {
+		if !slices.Contains(classes, class) {
+			return false
+		}
+	}
+	return true
+}
+
+func (in *stylesheetInliner) Inline(markup string) (string, error) {
+	if len(in.rules) == 0 {
+		return markup, nil
+	}
+	var b strings.Builder
+	b.Grow(len(markup))
+	i := 0
+	for i < len(markup) {
+		lt := strings.IndexByte(markup[i:], '<')
+		if lt < 0 {
+			b.WriteString(markup[i:])
+			break
+		}
+		lt += i
+		b.WriteString(markup[i:lt])
+
+		if strings.HasPrefix(markup[lt:], "<!--") {
+			end := strings.Index(markup[lt+4:], "-->")
+			if end < 0 {
+				b.WriteString(markup[lt:])
+				break
+			}
+			end = lt + 4 + end + 3
+			b.WriteString(markup[lt:end])
+			i = end
+			continue
+		}
+		if lt+1 >= len(markup) || !htmlscan.IsASCIILetter(markup[lt+1]) {
+			b.WriteByte('<')
+			i = lt + 1
+			continue
+		}
+
+		tag, ok := scanStartTag(markup, lt)
+		if !ok {
+			b.WriteString(markup[lt:])
+			break
+		}
+		b.WriteString(in.rewriteTag(markup, tag))
+		i = tag.end
+
+		if tag.name == "style" || tag.name == "script" {
+			closing := indexFold(markup[i:], "</"+tag.name)
+			if closing < 0 {
+				b.WriteString(markup[i:])
+				break
+			}
+			b.WriteString(markup[i : i+closing])
+			i += closing
+		}
+	}
+	return b.String(), nil
+}
+
+func (in *stylesheetInliner) rewriteTag(markup string, tag startTag) string {
+	var id string
+	var classes []string
+	style := -1
+	for n, attr := range tag.attrs {
+		switch attr.name {
+		case "id":
+			id = attr.value
+		case "class":
+			classes = strings.Fields(attr.value)
+		case "style":
+			style = n
+		}
+	}
+
+	var matched []rule
+	for _, r := range in.rules {
+		if r.selector.matches(tag.name, id, classes) {
+			matched = append(matched, r)
+		}
+	}
+	if len(matched) == 0 {
+		return markup[tag.start:tag.end]
+	}
+	slices.SortStableFunc(matched, func(a, b rule) int {
+		if a.specificity != b.specificity {
+			return a.specificity - b.specificity
+		}
+		return a.order - b.order
+	})
+
+	declarations := make([]string, 0, len(matched)+1)
+	for _, r := range matched {
+		declarations = append(declarations, r.declarations)
+	}
+
+	var b strings.Builder
+	b.WriteString(markup[tag.start:tag.nameEnd])
+	rest := markup[tag.nameEnd:tag.end]
+	if style >= 0 {
+		attr := tag.attrs[style]
+		if existing := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(attr.value), ";")); existing != "" {
+			declarations = append(declarations, existing)
+		}
+		rest = markup[tag.nameEnd:attr.start] + markup[attr.end:tag.end]
+	}
+	b.WriteString(` style="`)
+	b.WriteString(template.HTMLEscapeString(strings.Join(declarations, "; ")))
+	b.WriteString(`"`)
+	b.WriteString(rest)
+	return b.String()
+}
+
+type (
+	startTag struct {
+		name    string
+		start   int
+		nameEnd int
+		end     int
+		attrs   []attribute
+	}
+
+	attribute struct {
+		name  string
+		value string
+		start int
+		end   int
+	}
+)
+
+// scanStartTag reads the start tag beginning at pos. ok is false when the tag
+// is not terminated.
+func scanStartTag(s string, pos int) (startTag, bool) {
+	tag := startTag{start: pos}
+	i := pos + 1
+	for i < len(s) && !htmlscan.IsTagSpace(s[i]) && s[i] != '/' && s[i] != '>' {
+		i++
+	}
+	tag.name = strings.ToLower(s[pos+1 : i])
+	tag.nameEnd = i
+
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == '>':
+			tag.end = i + 1
+			return tag, true
+		case c == '/' || htmlscan.IsTagSpace(c):
+			i++
+		default:
+			attrStart := i
+			for attrStart > tag.nameEnd && htmlscan.IsTagSpace(s[attrStart-1]) {
+				attrStart--
+			}
+			nameStart := i
+			for i < len(s) && !htmlscan.IsTagSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+				i++
+			}
+			attr := attribute{name: strings.ToLower(s[nameStart:i]), start: attrStart}
+			j := i
+			for j < len(s) && htmlscan.IsTagSpace(s[j]) {
+				j++
+			}
+			if j < len(s) && s[j] == '=' {
+				j++
+				for j < len(s) && htmlscan.IsTagSpace(s[j]) {
+					j++
+				}
+				if j < len(s) && (s[j] == '"' || s[j] == '\'') {
+					end := strings.IndexByte(s[j+1:], s[j])
+					if end < 0 {
+						return startTag{}, false
+					}
+					attr.value = html.UnescapeString(s[j+1 : j+1+end])
+					j += end + 2
+				} else {
+					valueStart := j
+					for j < len(s) && !htmlscan.IsTagSpace(s[j]) && s[j] != '>' {
+						j++
+					}
+					attr.value = html.UnescapeString(s[valueStart:j])
+				}
+				i = j
+			}
+			attr.end = i
+			tag.attrs = append(tag.attrs, attr)
+		}
+	}
+	return startTag{}, false
+}
+
+// indexFold returns the index of the lower-case ASCII substr in s, ignoring
+// ASCII case.
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
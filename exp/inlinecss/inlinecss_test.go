@@ -0,0 +1,111 @@
+package inlinecss
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	partial "github.com/donseba/go-partial"
+)
+
+func TestInlineCSSInlinesClassStyles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"email.gohtml": &fstest.MapFile{Data: []byte(`<div class="card"><p class="note big" style="margin: 0">{{ . }}</p><p>plain</p></div>`)},
+	}
+	email := partial.NewID("email", "email.gohtml").
+		SetFileSystem(fsys).
+		SetDot("Hi").
+		Use(Stage())
+	InlineCSS(email, `
+		/* card layout */
+		.card { padding: 8px; }
+		p.note { color: red }
+		.big { font-size: 20px; color: blue; }
+		a:hover { color: green; }
+		@media (max-width: 600px) { .card { padding: 0; } }
+	`)
+
+	out, err := partial.Render(context.Background(), email)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	want := `<div style="padding: 8px" class="card"><p style="font-size: 20px; color: blue; color: red; margin: 0" class="note big">Hi</p><p>plain</p></div>`
+	if string(out) != want {
+		t.Fatalf("output = %q, want %q", out, want)
+	}
+}
+
+func TestInlinerSkipsRawTextAndComments(t *testing.T) {
+	in := New(`b { font-weight: bold } #main { width: 100% }`)
+	out, err := in.Inline(`<!-- <b> --><style>b { x: y } <b></style><section id='main'><b>x</b> a < b</section>`)
+	if err != nil {
+		t.Fatalf("Inline() error = %v", err)
+	}
+	want := `<!-- <b> --><style>b { x: y } <b></style><section style="width: 100%" id='main'><b style="font-weight: bold">x</b> a < b</section>`
+	if out != want {
+		t.Fatalf("Inline() = %q, want %q", out, want)
+	}
+}
+
+func TestInlinerKeepsStringsURLsAndComments(t *testing.T) {
+	tests := []struct {
+		name       string
+		stylesheet string
+		want       string
+	}{
+		{
+			name:       "data url",
+			stylesheet: `p { background: url(data:image/png;base64,iVBORw0KGgo=); color: red }`,
+			want:       `<p style="background: url(data:image/png;base64,iVBORw0KGgo=); color: red">x</p>`,
+		},
+		{
+			name:       "quoted values",
+			stylesheet: `p { font-family: "Helvetica; Neue", 'a}b'; content: "/* not a comment */" }`,
+			want:       `<p style="font-family: &#34;Helvetica; Neue&#34;, &#39;a}b&#39;; content: &#34;/* not a comment */&#34;">x</p>`,
+		},
+		{
+			name:       "comments",
+			stylesheet: `p { /* lead; */ color: red; /* margin: 0; */ padding: 0 /* trailing */ }`,
+			want:       `<p style="color: red; padding: 0">x</p>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := New(tt.stylesheet).Inline(`<p>x</p>`)
+			if err != nil {
+				t.Fatalf("Inline() error = %v", err)
+			}
+			if out != tt.want {
+				t.Fatalf("Inline() = %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithUsesCustomInliner(t *testing.T) {
+	fsys := fstest.MapFS{
+		"email.gohtml": &fstest.MapFile{Data: []byte(`<p>hello</p>`)},
+	}
+	failure := errors.New("inliner failed")
+	email := partial.NewID("email", "email.gohtml").
+		SetFileSystem(fsys).
+		Use(Stage())
+	With(email, InlinerFunc(func(html string) (string, error) {
+		return strings.ToUpper(html), nil
+	}))
+
+	out, err := partial.Render(context.Background(), email)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if string(out) != "<P>HELLO</P>" {
+		t.Fatalf("output = %q", out)
+	}
+
+	With(email, InlinerFunc(func(string) (string, error) { return "", failure }))
+	if _, err := partial.Render(context.Background(), email); !errors.Is(err, failure) {
+		t.Fatalf("Render() error = %v, want inliner error", err)
+	}
+}
@@ -0,0 +1,104 @@
+// Package requesthelpers provides experimental template helpers that read the
+// active request, such as submitted form values and the request method.
+//
+//	root.SetFunc(requesthelpers.FuncMap())
+//	root.Use(requesthelpers.Stage())
+package requesthelpers
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	partial "github.com/donseba/go-partial"
+)
+
+// FuncMap returns placeholders for the request template helpers.
+//
+// go-doc:funcmap
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"form":      Form,
+		"formValue": FormValue,
+		"isMethod":  IsMethod,
+	}
+}
+
+// Form returns the parsed form values of the active request. The form is
+// parsed on first use.
+//
+// go-doc:sig func() net/url.Values
+func Form(ctx ...*partial.RenderContext) url.Values {
+	r := request(ctx)
+	if r == nil {
+		return url.Values{}
+	}
+	if err := r.ParseForm(); err != nil && r.Form == nil {
+		return url.Values{}
+	}
+	return r.Form
+}
+
+// FormValue returns the first form value for name. Stage binds it to the
+// active request.
+//
+// go-doc:sig func(name string) string
+func FormValue(name string) string {
+	return formValue(nil, name)
+}
+
+func formValue(ctx *partial.RenderContext, name string) string {
+	if ctx == nil {
+		return ""
+	}
+	return Form(ctx).Get(name)
+}
+
+// IsMethod reports whether the active request uses one of the given HTTP
+// methods, compared case-insensitively, so one template can render the GET and
+// POST variants of a form. Stage binds it to the active request.
+//
+// go-doc:sig func(methods ...string) bool
+func IsMethod(methods ...string) bool {
+	return isMethod(nil, methods...)
+}
+
+func isMethod(ctx *partial.RenderContext, methods ...string) bool {
+	r := request([]*partial.RenderContext{ctx})
+	if r == nil {
+		return false
+	}
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	for _, candidate := range methods {
+		if strings.EqualFold(method, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stage installs the request helpers for the active render context.
+func Stage() partial.RenderStage {
+	return partial.RenderStageHooks{
+		PrepareFunc: func(ctx *partial.RenderContext) (*partial.RenderContext, error) {
+			if ctx == nil {
+				return ctx, nil
+			}
+			ctx.SetFunc("form", func() url.Values { return Form(ctx) })
+			ctx.SetFunc("formValue", func(name string) string { return formValue(ctx, name) })
+			ctx.SetFunc("isMethod", func(methods ...string) bool { return isMethod(ctx, methods...) })
+			return ctx, nil
+		},
+	}
+}
+
+func request(ctx []*partial.RenderContext) *http.Request {
+	if len(ctx) == 0 || ctx[0] == nil {
+		return nil
+	}
+	return ctx[0].Request
+}
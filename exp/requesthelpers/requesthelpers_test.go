@@ -0,0 +1,58 @@
+package requesthelpers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	partial "github.com/donseba/go-partial"
+)
+
+func TestFormValuesRedisplaySubmittedForm(t *testing.T) {
+	fsys := fstest.MapFS{
+		"form.gohtml": &fstest.MapFile{Data: []byte(`<input name="email" value="{{ formValue "email" }}">{{ range (index form "tags") }}<span>{{ . }}</span>{{ end }}`)},
+	}
+	page := partial.NewID("form", "form.gohtml").
+		SetFileSystem(fsys).
+		SetFunc(FuncMap()).
+		Use(Stage())
+
+	req := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader("email=ada%40example.com&tags=a&tags=b"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	out, err := partial.RenderWithRequest(context.Background(), req, page)
+	if err != nil {
+		t.Fatalf("RenderWithRequest() error = %v", err)
+	}
+
+	want := `<input name="email" value="ada@example.com"><span>a</span><span>b</span>`
+	if string(out) != want {
+		t.Fatalf("output = %q, want %q", out, want)
+	}
+}
+
+func TestIsMethodBranchesOnRequestMethod(t *testing.T) {
+	fsys := fstest.MapFS{
+		"form.gohtml": &fstest.MapFile{Data: []byte(`{{ if isMethod "POST" }}<p>saved</p>{{ else }}<form method="post"></form>{{ end }}`)},
+	}
+	page := partial.NewID("form", "form.gohtml").
+		SetFileSystem(fsys).
+		SetFunc(FuncMap()).
+		Use(Stage())
+
+	for method, want := range map[string]string{
+		http.MethodGet:  `<form method="post"></form>`,
+		http.MethodPost: `<p>saved</p>`,
+	} {
+		req := httptest.NewRequest(method, "/signup", nil)
+		out, err := partial.RenderWithRequest(context.Background(), req, page)
+		if err != nil {
+			t.Fatalf("RenderWithRequest(%s) error = %v", method, err)
+		}
+		if string(out) != want {
+			t.Fatalf("RenderWithRequest(%s) = %q, want %q", method, out, want)
+		}
+	}
+}
@@ -2,8 +2,10 @@
 package selection
 
 import (
+	"context"
 	"fmt"
 	"html/template"
+	"maps"
 	"net/http"
 	"slices"
 
@@ -13,10 +15,27 @@ import (
 type config struct {
 	Default  string
 	Partials map[string]*partial.Partial
+	// Order lists the keys in registration order for WithSelectOptions.
+	Order []string
 }
 
 type extensionKey struct{}
 
+// Option is one named partial of an ordered selection.
+type Option struct {
+	Key     string
+	Partial *partial.Partial
+}
+
+// SelectionKeys returns the configured selection keys in registration order,
+// or in sorted order for a map registered with WithSelectMap.
+func (c config) SelectionKeys() []string {
+	if c.Order != nil {
+		return slices.Clone(c.Order)
+	}
+	return slices.Sorted(maps.Keys(c.Partials))
+}
+
 // WithSelectMap configures the named partials that the selection helper can render.
 func WithSelectMap(p *partial.Partial, defaultKey string, partials map[string]*partial.Partial) *partial.Partial {
 	if p == nil {
@@ -25,6 +44,50 @@ func WithSelectMap(p *partial.Partial, defaultKey string, partials map[string]*p
 	return p.SetExtension(extensionKey{}, config{Default: defaultKey, Partials: partials})
 }
 
+// WithSelectOptions configures the named partials that the selection helper
+// can render, like WithSelectMap, and keeps their order for selectionKeys so a
+// tab bar renders in a stable order. A repeated key replaces the earlier
+// partial and keeps its position.
+func WithSelectOptions(p *partial.Partial, defaultKey string, options ...Option) *partial.Partial {
+	if p == nil {
+		return nil
+	}
+	cfg := config{
+		Default:  defaultKey,
+		Partials: make(map[string]*partial.Partial, len(options)),
+		Order:    make([]string, 0, len(options)),
+	}
+	for _, option := range options {
+		if _, ok := cfg.Partials[option.Key]; !ok {
+			cfg.Order = append(cfg.Order, option.Key)
+		}
+		cfg.Partials[option.Key] = option.Partial
+	}
+	return p.SetExtension(extensionKey{}, cfg)
+}
+
+// Render renders the partial registered under key in p's selection map,
+// without reading the selection from a request. It is useful for tests and
+// server-side rendering of a known selection.
+func Render(ctx context.Context, p *partial.Partial, key string) (template.HTML, error) {
+	if p == nil {
+		return "", partial.ErrPartialNotInitialized
+	}
+	value, ok := p.Extension(extensionKey{})
+	if !ok {
+		return "", fmt.Errorf("selection is not configured for partial '%s'", p.PartialID())
+	}
+	cfg, _ := value.(config)
+	selected := cfg.Partials[key]
+	if selected == nil {
+		return "", fmt.Errorf("selected partial '%s' not found in parent '%s'", key, p.PartialID())
+	}
+
+	child := selected.Clone()
+	p.Clone().With(child)
+	return partial.Render(ctx, child)
+}
+
 // FuncMap returns placeholders for the selection template helpers.
 func FuncMap() template.FuncMap {
 	return template.FuncMap{
@@ -32,6 +95,7 @@ func FuncMap() template.FuncMap {
 		"selectionHeader": SelectionHeader,
 		"selectionValue":  SelectionValue,
 		"selectionIs":     SelectionIs,
+		"selectionKeys":   SelectionKeys,
 	}
 }
 
@@ -68,6 +132,42 @@ func SelectionValue(ctx ...*partial.RenderContext) string {
 	return selectionValue(renderCtx)()
 }
 
+// Selected returns the selection key requested for p, falling back to the
+// default configured with WithSelectMap. Actions use it to load data for the
+// active selection before the selected partial renders.
+func Selected(p *partial.Partial, runtime *partial.Runtime) string {
+	if p == nil || runtime == nil || runtime.Connector() == nil {
+		return ""
+	}
+	if selected := runtime.Connector().GetSelectValue(requestFrom(runtime.Request())); selected != "" {
+		return selected
+	}
+	value, ok := p.Extension(extensionKey{})
+	if !ok {
+		return ""
+	}
+	cfg, _ := value.(config)
+	return cfg.Default
+}
+
+// SelectionKeys returns the keys of the partial's selection, in the order
+// given to WithSelectOptions or sorted for WithSelectMap, so templates can
+// render a tab bar without hardcoding the labels. Combine it with selectionIs
+// to mark the active key.
+//
+// go-doc:sig func() []string
+func SelectionKeys(ctx ...*partial.RenderContext) []string {
+	renderCtx := firstRenderContext(ctx)
+	if renderCtx == nil || renderCtx.Partial == nil {
+		return nil
+	}
+	cfg, ok := selectionConfig(renderCtx)
+	if !ok {
+		return nil
+	}
+	return cfg.SelectionKeys()
+}
+
 // SelectionIs reports whether the selected key matches any provided value.
 //
 // go-doc:sig func(values ...string) bool
@@ -95,6 +195,7 @@ func Stage() partial.RenderStage {
 			ctx.SetFunc("selectionIs", func(in ...string) bool {
 				return selectionIs(ctx, in...)
 			})
+			ctx.SetFunc("selectionKeys", func() []string { return SelectionKeys(ctx) })
 			ctx.SetFunc("selection", func() template.HTML { return SelectionHTML(ctx) })
 			return ctx, nil
 		},
@@ -153,8 +254,15 @@ func render(ctx *partial.RenderContext) template.HTML {
 }
 
 func request(ctx *partial.RenderContext) *http.Request {
-	if ctx == nil || ctx.Request == nil {
+	if ctx == nil {
+		return &http.Request{}
+	}
+	return requestFrom(ctx.Request)
+}
+
+func requestFrom(r *http.Request) *http.Request {
+	if r == nil {
 		return &http.Request{}
 	}
-	return ctx.Request
+	return r
 }
@@ -12,6 +12,7 @@ import (
 
 	partial "github.com/donseba/go-partial"
 	"github.com/donseba/go-partial/connector"
+	"github.com/donseba/go-partial/exp/actions"
 	exterrors "github.com/donseba/go-partial/ext/errors"
 )
 
@@ -42,6 +43,60 @@ func TestRendererRendersSelectedPartial(t *testing.T) {
 	}
 }
 
+func TestSelectionKeysRenderTabBar(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tabs.gohtml":    &fstest.MapFile{Data: []byte(`<nav>{{ range selectionKeys }}<a{{ if selectionIs . }} class="active"{{ end }}>{{ . }}</a>{{ end }}</nav>{{ selection }}`)},
+		"details.gohtml": &fstest.MapFile{Data: []byte(`details`)},
+		"summary.gohtml": &fstest.MapFile{Data: []byte(`summary`)},
+	}
+	tabs := partial.NewID("tabs", "tabs.gohtml").
+		SetFileSystem(fsys).
+		SetConnector(connector.NewPartial(nil)).
+		SetFunc(FuncMap()).
+		Use(Stage())
+	WithSelectMap(tabs, "summary", map[string]*partial.Partial{
+		"summary": partial.NewID("summary", "summary.gohtml"),
+		"details": partial.NewID("details", "details.gohtml"),
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/tabs", nil)
+	req.Header.Set(connector.HeaderSelect.String(), "details")
+	out, err := partial.RenderWithRequest(context.Background(), req, tabs)
+	if err != nil {
+		t.Fatalf("RenderWithRequest() error = %v", err)
+	}
+	want := `<nav><a class="active">details</a><a>summary</a></nav>details`
+	if string(out) != want {
+		t.Fatalf("output = %q, want %q", out, want)
+	}
+}
+
+func TestWithSelectOptionsKeepsTabOrder(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tabs.gohtml": &fstest.MapFile{Data: []byte(`{{ range selectionKeys }}[{{ . }}]{{ end }}`)},
+	}
+	tabs := partial.NewID("tabs", "tabs.gohtml").
+		SetFileSystem(fsys).
+		SetFunc(FuncMap()).
+		Use(Stage())
+	WithSelectOptions(tabs, "overview",
+		Option{Key: "overview", Partial: partial.NewID("overview")},
+		Option{Key: "activity", Partial: partial.NewID("activity")},
+		Option{Key: "settings", Partial: partial.NewID("settings")},
+		Option{Key: "billing", Partial: partial.NewID("billing")},
+	)
+
+	for range 10 {
+		out, err := partial.RenderWithRequest(context.Background(), httptest.NewRequest(http.MethodGet, "/tabs", nil), tabs)
+		if err != nil {
+			t.Fatalf("RenderWithRequest() error = %v", err)
+		}
+		if want := "[overview][activity][settings][billing]"; string(out) != want {
+			t.Fatalf("output = %q, want %q", out, want)
+		}
+	}
+}
+
 func TestSelectionIsUsesDefault(t *testing.T) {
 	fsys := fstest.MapFS{
 		"content.gohtml": &fstest.MapFile{Data: []byte(`{{ selectionHeader }}:{{ if selectionIs "summary" }}yes{{ end }}`)},
@@ -62,6 +117,100 @@ func TestSelectionIsUsesDefault(t *testing.T) {
 	}
 }
 
+func TestActionReadsSelectedKey(t *testing.T) {
+	fsys := fstest.MapFS{
+		"content.gohtml": &fstest.MapFile{Data: []byte(`{{ .Title }}|{{ selection }}`)},
+		"summary.gohtml": &fstest.MapFile{Data: []byte(`summary`)},
+		"details.gohtml": &fstest.MapFile{Data: []byte(`details`)},
+	}
+	titles := map[string]string{"summary": "Summary data", "details": "Details data"}
+	content := partial.NewID("content", "content.gohtml").
+		SetFileSystem(fsys).
+		SetConnector(connector.NewPartial(nil)).
+		SetFunc(FuncMap()).
+		Use(Stage(), actions.Stage())
+	WithSelectMap(content, "summary", map[string]*partial.Partial{
+		"summary": partial.NewID("summary", "summary.gohtml").SetFileSystem(fsys),
+		"details": partial.NewID("details", "details.gohtml").SetFileSystem(fsys),
+	})
+	actions.WithAction(content, func(ctx context.Context, p *partial.Partial, runtime *partial.Runtime) (*partial.Partial, error) {
+		return p.SetDot(map[string]string{"Title": titles[Selected(p, runtime)]}), nil
+	})
+
+	cases := map[string]string{
+		"":        "Summary data|summary",
+		"details": "Details data|details",
+	}
+	for selected, want := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/tabs", nil)
+		req.Header.Set(connector.HeaderTarget.String(), "content")
+		if selected != "" {
+			req.Header.Set(connector.HeaderSelect.String(), selected)
+		}
+		out, err := partial.RenderWithRequest(context.Background(), req, content)
+		if err != nil {
+			t.Fatalf("RenderWithRequest(%q) error = %v", selected, err)
+		}
+		if string(out) != want {
+			t.Fatalf("RenderWithRequest(%q) = %q, want %q", selected, out, want)
+		}
+	}
+}
+
+func TestRenderRendersSelectionByKey(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tab1.gohtml": &fstest.MapFile{Data: []byte(`tab one`)},
+		"tab2.gohtml": &fstest.MapFile{Data: []byte(`tab two`)},
+	}
+	content := partial.NewID("content", "content.gohtml").SetFileSystem(fsys)
+	WithSelectMap(content, "tab1", map[string]*partial.Partial{
+		"tab1": partial.NewID("tab1", "tab1.gohtml"),
+		"tab2": partial.NewID("tab2", "tab2.gohtml"),
+	})
+
+	out, err := Render(context.Background(), content, "tab2")
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if string(out) != "tab two" {
+		t.Fatalf("output = %q", out)
+	}
+
+	if _, err := Render(context.Background(), content, "tab3"); err == nil || !strings.Contains(err.Error(), "tab3") {
+		t.Fatalf("Render(tab3) error = %v, want missing key error", err)
+	}
+}
+
+func TestFromConfigAppliesSelectMap(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tab1.gohtml": &fstest.MapFile{Data: []byte(`tab one`)},
+		"tab2.gohtml": &fstest.MapFile{Data: []byte(`tab two`)},
+	}
+	content, err := partial.FromConfig(partial.PartialConfig{
+		ID: "content",
+		Selection: &partial.SelectionConfig{
+			Default: "tab1",
+			Partials: map[string]partial.PartialConfig{
+				"tab1": {Templates: []string{"tab1.gohtml"}},
+				"tab2": {Templates: []string{"tab2.gohtml"}},
+			},
+			Apply: WithSelectMap,
+		},
+	})
+	if err != nil {
+		t.Fatalf("FromConfig() error = %v", err)
+	}
+	content.SetFileSystem(fsys)
+
+	out, err := Render(context.Background(), content, "tab2")
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if string(out) != "tab two" {
+		t.Fatalf("output = %q", out)
+	}
+}
+
 func TestRendererUsesErrorFallbackForSelectedPartial(t *testing.T) {
 	fsys := fstest.MapFS{
 		"content.gohtml": &fstest.MapFile{Data: []byte(`{{ selection }}`)},
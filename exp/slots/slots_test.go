@@ -35,6 +35,26 @@ func TestSlotRendersChildPartial(t *testing.T) {
 	}
 }
 
+func TestNamedSlotsRenderInPlace(t *testing.T) {
+	card := partial.NewID("card", "card.gohtml").
+		SetFileSystem(fstest.MapFS{
+			"card.gohtml":   &fstest.MapFile{Data: []byte(`<article><header>{{ slot "header" }}</header><p>{{ slot "body" }}</p></article>`)},
+			"header.gohtml": &fstest.MapFile{Data: []byte(`<h2>{{ .Title }}</h2>`)},
+			"body.gohtml":   &fstest.MapFile{Data: []byte(`{{ .Text }}`)},
+		}).
+		Use(Stage())
+	Set(card, "header", partial.NewID("card-header", "header.gohtml").SetDot(map[string]string{"Title": "Hello"}))
+	Set(card, "body", partial.NewID("card-body", "body.gohtml").SetDot(map[string]string{"Text": "World"}))
+
+	out, err := partial.Render(context.Background(), card)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if want := `<article><header><h2>Hello</h2></header><p>World</p></article>`; string(out) != want {
+		t.Fatalf("Render() = %q, want %q", out, want)
+	}
+}
+
 func TestHasSlot(t *testing.T) {
 	parent := partial.NewID("page", "page.gohtml").
 		SetFileSystem(fstest.MapFS{
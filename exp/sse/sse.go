@@ -162,7 +162,7 @@ func (s *Writer) PatchHTML(target string, html template.HTML) error {
 
 func (s *Writer) PatchPartial(ctx context.Context, r *http.Request, target string, p *partial.Partial) error {
 	if p == nil {
-		return fmt.Errorf("partial is not initialized")
+		return partial.ErrPartialNotInitialized
 	}
 	if len(s.stages) > 0 {
 		p = p.Clone()
@@ -12,6 +12,8 @@ import (
 	"html/template"
 	"maps"
 	"net/url"
+	"reflect"
+	"slices"
 	"strings"
 	"time"
 	"unicode"
@@ -50,6 +52,8 @@ var urlFuncMap = template.FuncMap{
 // go-doc:funcmap
 var htmlFuncMap = template.FuncMap{
 	"safeHTML": safeHTML,
+	"attr":     attr,
+	"skeleton": skeleton,
 }
 
 // go-doc:funcmap
@@ -64,9 +68,11 @@ var collectionFuncMap = template.FuncMap{
 	"first": first,
 	"last":  last,
 
-	"dict":   dict,
-	"hasKey": hasKey,
-	"keys":   keys,
+	"dict":        dict,
+	"hasKey":      hasKey,
+	"keys":        keys,
+	"sortedPairs": sortedPairs,
+	"length":      length,
 }
 
 // go-doc:funcmap
@@ -75,6 +81,14 @@ var numberFuncMap = template.FuncMap{
 	"dec": dec,
 }
 
+// go-doc:funcmap
+var typeFuncMap = template.FuncMap{
+	"isSlice":  isSlice,
+	"isMap":    isMap,
+	"isString": isString,
+	"isNil":    isNil,
+}
+
 // FuncMap returns a fresh copy of the optional helper function map.
 func FuncMap() template.FuncMap {
 	return mergeFuncMaps(
@@ -84,6 +98,7 @@ func FuncMap() template.FuncMap {
 		TimeFuncMap(),
 		CollectionFuncMap(),
 		NumberFuncMap(),
+		TypeFuncMap(),
 	)
 }
 
@@ -117,6 +132,11 @@ func NumberFuncMap() template.FuncMap {
 	return maps.Clone(numberFuncMap)
 }
 
+// TypeFuncMap returns helpers that report the kind of a value held in any.
+func TypeFuncMap() template.FuncMap {
+	return maps.Clone(typeFuncMap)
+}
+
 func mergeFuncMaps(funcMaps ...template.FuncMap) template.FuncMap {
 	total := 0
 	for _, funcMap := range funcMaps {
@@ -133,6 +153,105 @@ func safeHTML(s string) template.HTML {
 	return template.HTML(s)
 }
 
+// attr renders a single attribute with an escaped value, including the leading
+// space. Like html/template, it classifies the attribute by name: URL values
+// with a scheme other than http, https, or mailto are replaced with #ZgotmplZ,
+// and invalid names as well as event handler, style, srcdoc, and srcset
+// attributes render nothing, because their values cannot be escaped safely
+// here.
+func attr(name string, value any) template.HTMLAttr {
+	if !validAttrName(name) {
+		return ""
+	}
+	s := fmt.Sprint(value)
+	switch attrKind(name) {
+	case attrKindUnsafe:
+		return ""
+	case attrKindURL:
+		s = filterURL(s)
+	}
+	return template.HTMLAttr(" " + name + `="` + template.HTMLEscapeString(s) + `"`)
+}
+
+type attrKindType int
+
+const (
+	attrKindPlain attrKindType = iota
+	attrKindURL
+	attrKindUnsafe
+)
+
+// urlAttrs are the attributes html/template treats as URLs.
+var urlAttrs = map[string]struct{}{
+	"action": {}, "archive": {}, "background": {}, "cite": {}, "classid": {},
+	"codebase": {}, "data": {}, "formaction": {}, "href": {}, "icon": {},
+	"longdesc": {}, "manifest": {}, "poster": {}, "profile": {}, "src": {},
+	"usemap": {},
+}
+
+// attrKind classifies an attribute name the way html/template does: a data-
+// prefix and XML namespace are ignored, and unknown names mentioning src, uri,
+// or url are URLs.
+func attrKind(name string) attrKindType {
+	name = strings.ToLower(name)
+	name = strings.TrimPrefix(name, "data-")
+	if namespace, local, ok := strings.Cut(name, ":"); ok {
+		if namespace == "xmlns" {
+			return attrKindURL
+		}
+		name = local
+	}
+	switch {
+	case strings.HasPrefix(name, "on"), name == "style", name == "srcdoc", name == "srcset":
+		return attrKindUnsafe
+	}
+	if _, ok := urlAttrs[name]; ok {
+		return attrKindURL
+	}
+	if strings.Contains(name, "src") || strings.Contains(name, "uri") || strings.Contains(name, "url") {
+		return attrKindURL
+	}
+	return attrKindPlain
+}
+
+// filterURL returns s unless it has a scheme other than http, https, or
+// mailto, in which case it returns html/template's #ZgotmplZ placeholder.
+func filterURL(s string) string {
+	scheme, _, ok := strings.Cut(s, ":")
+	if !ok || strings.Contains(scheme, "/") {
+		return s
+	}
+	switch strings.ToLower(scheme) {
+	case "http", "https", "mailto":
+		return s
+	}
+	return "#ZgotmplZ"
+}
+
+// skeleton repeats placeholder markup count times, for loading states of
+// lists and card grids. A count below one renders nothing.
+func skeleton(count int, placeholder template.HTML) template.HTML {
+	if count < 1 {
+		return ""
+	}
+	return template.HTML(strings.Repeat(string(placeholder), count))
+}
+
+func validAttrName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == ':', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func upperFirst(s string) string {
 	if s == "" {
 		return ""
@@ -206,6 +325,70 @@ func keys(m map[string]any) []string {
 	return out
 }
 
+// Pair is a map entry returned by the sortedPairs helper.
+type Pair struct {
+	Key   string
+	Value any
+}
+
+func sortedPairs(m any) []Pair {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Map {
+		return nil
+	}
+	out := make([]Pair, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		out = append(out, Pair{Key: fmt.Sprint(iter.Key().Interface()), Value: iter.Value().Interface()})
+	}
+	slices.SortFunc(out, func(a, b Pair) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return out
+}
+
+func length(value any) int {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return v.Len()
+	case reflect.Pointer:
+		if v.IsNil() {
+			return 0
+		}
+		return length(v.Elem().Interface())
+	default:
+		return 0
+	}
+}
+
+func isSlice(value any) bool {
+	kind := reflect.ValueOf(value).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
+
+func isMap(value any) bool {
+	return reflect.ValueOf(value).Kind() == reflect.Map
+}
+
+func isString(value any) bool {
+	return reflect.ValueOf(value).Kind() == reflect.String
+}
+
+// isNil reports whether value is nil or a nil pointer, map, slice, channel,
+// function, or interface, which a plain `eq . nil` does not catch.
+func isNil(value any) bool {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func, reflect.Interface:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
 func inc(args ...any) any {
 	if len(args) == 0 {
 		return 1
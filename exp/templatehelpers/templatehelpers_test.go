@@ -3,6 +3,7 @@ package templatehelpers
 import (
 	"html/template"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -49,6 +50,11 @@ func TestFuncMapIncludesSubsets(t *testing.T) {
 			t.Fatalf("FuncMap() missing number helper %q", name)
 		}
 	}
+	for name := range TypeFuncMap() {
+		if _, ok := all[name]; !ok {
+			t.Fatalf("FuncMap() missing type helper %q", name)
+		}
+	}
 }
 
 func TestSubsetsStayScoped(t *testing.T) {
@@ -82,6 +88,58 @@ func TestSafeHTML(t *testing.T) {
 	}
 }
 
+func TestAttr(t *testing.T) {
+	cases := []struct {
+		name     string
+		value    any
+		expected template.HTMLAttr
+	}{
+		{"class", "btn primary", ` class="btn primary"`},
+		{"title", `say "hi" & <bye>`, ` title="say &#34;hi&#34; &amp; &lt;bye&gt;"`},
+		{"data-count", 3, ` data-count="3"`},
+		{"onclick", "alert(1)", ""},
+		{"data-onclick", "alert(1)", ""},
+		{"href", "/users?page=2&sort=name", ` href="/users?page=2&amp;sort=name"`},
+		{"href", "https://example.com", ` href="https://example.com"`},
+		{"href", "javascript:alert(1)", ` href="#ZgotmplZ"`},
+		{"HREF", " JavaScript:alert(1)", ` HREF="#ZgotmplZ"`},
+		{"data-src", "vbscript:msgbox", ` data-src="#ZgotmplZ"`},
+		{"formaction", "data:text/html,x", ` formaction="#ZgotmplZ"`},
+		{"style", "background:url(javascript:alert(1))", ""},
+		{"srcdoc", "<script>alert(1)</script>", ""},
+		{`x" onload="alert(1)`, "v", ""},
+		{"", "v", ""},
+	}
+	for _, c := range cases {
+		if output := attr(c.name, c.value); output != c.expected {
+			t.Errorf("attr(%q, %v) = %q; want %q", c.name, c.value, output, c.expected)
+		}
+	}
+}
+
+func TestSkeletonRepeatsPlaceholder(t *testing.T) {
+	var tmpl *template.Template
+	include := func(name string) (template.HTML, error) {
+		var buf strings.Builder
+		err := tmpl.ExecuteTemplate(&buf, name, nil)
+		return template.HTML(buf.String()), err
+	}
+	tmpl = template.Must(template.New("list").Funcs(HTMLFuncMap()).Funcs(template.FuncMap{"include": include}).Parse(
+		`{{ define "card" }}<li class="skeleton"></li>{{ end }}<ul>{{ skeleton .Count (include "card") }}</ul>`,
+	))
+
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, map[string]int{"Count": 4}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if got := strings.Count(buf.String(), `<li class="skeleton"></li>`); got != 4 {
+		t.Fatalf("rendered %d placeholders, want 4: %s", got, buf.String())
+	}
+	if output := skeleton(0, "<li></li>"); output != "" {
+		t.Fatalf("skeleton(0) = %q, want empty", output)
+	}
+}
+
 func TestTitle(t *testing.T) {
 	cases := []struct {
 		input    string
@@ -267,6 +325,41 @@ func TestKeys(t *testing.T) {
 	}
 }
 
+func TestSortedPairs(t *testing.T) {
+	out := sortedPairs(map[string]int{"c": 3, "a": 1, "b": 2})
+	expected := []Pair{{Key: "a", Value: 1}, {Key: "b", Value: 2}, {Key: "c", Value: 3}}
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatalf("sortedPairs() = %#v; want %#v", out, expected)
+	}
+	if out := sortedPairs("not a map"); out != nil {
+		t.Fatalf("sortedPairs(string) = %#v; want nil", out)
+	}
+}
+
+func TestLength(t *testing.T) {
+	ch := make(chan int, 3)
+	ch <- 1
+	var nilSlice []string
+	cases := []struct {
+		name     string
+		input    any
+		expected int
+	}{
+		{"string", "héllo", 6},
+		{"slice", []int{1, 2, 3}, 3},
+		{"array", [2]string{"a", "b"}, 2},
+		{"map", map[string]any{"a": 1}, 1},
+		{"channel", ch, 1},
+		{"nil slice", nilSlice, 0},
+		{"nil", nil, 0},
+	}
+	for _, c := range cases {
+		if output := length(c.input); output != c.expected {
+			t.Errorf("length(%s) = %d; want %d", c.name, output, c.expected)
+		}
+	}
+}
+
 func TestIncDec(t *testing.T) {
 	if got := inc(10); got != 11 {
 		t.Fatalf("inc(10) = %v; want 11", got)
@@ -282,6 +375,53 @@ func TestIncDec(t *testing.T) {
 	}
 }
 
+func TestTypePredicates(t *testing.T) {
+	type label string
+	var nilPointer *int
+	var nilMap map[string]any
+	var nilSlice []string
+	var nilFunc func()
+	n := 1
+
+	cases := []struct {
+		name     string
+		input    any
+		isSlice  bool
+		isMap    bool
+		isString bool
+		isNil    bool
+	}{
+		{name: "nil", input: nil, isNil: true},
+		{name: "slice", input: []int{1, 2}, isSlice: true},
+		{name: "array", input: [2]string{"a", "b"}, isSlice: true},
+		{name: "nil slice", input: nilSlice, isSlice: true, isNil: true},
+		{name: "map", input: map[string]any{"a": 1}, isMap: true},
+		{name: "nil map", input: nilMap, isMap: true, isNil: true},
+		{name: "string", input: "text", isString: true},
+		{name: "named string", input: label("text"), isString: true},
+		{name: "html", input: template.HTML("<b>x</b>"), isString: true},
+		{name: "int", input: 42},
+		{name: "pointer", input: &n},
+		{name: "nil pointer", input: nilPointer, isNil: true},
+		{name: "nil func", input: nilFunc, isNil: true},
+		{name: "struct", input: struct{}{}},
+	}
+	for _, c := range cases {
+		if got := isSlice(c.input); got != c.isSlice {
+			t.Errorf("isSlice(%s) = %v; want %v", c.name, got, c.isSlice)
+		}
+		if got := isMap(c.input); got != c.isMap {
+			t.Errorf("isMap(%s) = %v; want %v", c.name, got, c.isMap)
+		}
+		if got := isString(c.input); got != c.isString {
+			t.Errorf("isString(%s) = %v; want %v", c.name, got, c.isString)
+		}
+		if got := isNil(c.input); got != c.isNil {
+			t.Errorf("isNil(%s) = %v; want %v", c.name, got, c.isNil)
+		}
+	}
+}
+
 func sameStrings(a, b []string) bool {
 	if len(a) != len(b) {
 		return false
@@ -3,11 +3,13 @@ package debug
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/http"
 	"net/url"
+	"time"
 
 	partial "github.com/donseba/go-partial"
 )
@@ -15,11 +17,39 @@ import (
 // RenderKindDebug is the renderer kind used for debug fragments.
 const RenderKindDebug partial.RenderKind = "debug"
 
-// FuncMap returns the optional debug template helper.
+type renderStartKey struct{}
+
+// FuncMap returns the optional debug template helpers. elapsed returns zero
+// until Stage is registered.
 func FuncMap() template.FuncMap {
 	return template.FuncMap{
-		"debug": Debug,
+		"debug":   Debug,
+		"elapsed": Elapsed,
+	}
+}
+
+// Elapsed is the placeholder for the elapsed helper. Stage binds it to the
+// time since the render began.
+//
+// go-doc:sig func() time.Duration
+func Elapsed() time.Duration {
+	return 0
+}
+
+// WithRenderStart records when a render began. Stage records the start of the
+// outermost render when ctx does not carry one yet; middleware can record an
+// earlier start, such as the time the request arrived.
+func WithRenderStart(ctx context.Context, start time.Time) context.Context {
+	return context.WithValue(ctx, renderStartKey{}, start)
+}
+
+// RenderStart returns the render start recorded in ctx.
+func RenderStart(ctx context.Context) (time.Time, bool) {
+	if ctx == nil {
+		return time.Time{}, false
 	}
+	start, ok := ctx.Value(renderStartKey{}).(time.Time)
+	return start, ok
 }
 
 // Debug renders a diagnostic value through the active render stage chain.
@@ -57,9 +87,22 @@ const debugTemplate = `<section class="go-partial-debug" role="note" style="back
 <pre style="background:#eeece4;border:1px solid #d8d5ca;border-radius:6px;color:#252522;font-family:ui-monospace,SFMono-Regular,Consolas,'Liberation Mono',Menlo,monospace;font-size:12px;line-height:1.45;margin:0;overflow:auto;padding:12px;white-space:pre-wrap">{{ .Output }}</pre>
 </section>`
 
-// Stage returns a render stage that handles debug render contexts.
+// Stage returns a render stage that handles debug render contexts and binds
+// the elapsed helper to the time since the render began.
 func Stage() partial.RenderStage {
 	return partial.RenderStageHooks{
+		PrepareFunc: func(ctx *partial.RenderContext) (*partial.RenderContext, error) {
+			if ctx == nil {
+				return ctx, nil
+			}
+			start, ok := RenderStart(ctx.Context)
+			if !ok {
+				start = time.Now()
+				ctx.Context = WithRenderStart(ctx.Context, start)
+			}
+			ctx.SetFunc("elapsed", func() time.Duration { return time.Since(start) })
+			return ctx, nil
+		},
 		RenderFunc: func(ctx *partial.RenderContext, next partial.RenderNext) (template.HTML, error) {
 			if ctx == nil || ctx.Kind != RenderKindDebug {
 				return next(ctx)
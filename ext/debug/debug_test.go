@@ -10,6 +10,7 @@ import (
 	"sync"
 	"testing"
 	"testing/fstest"
+	"time"
 
 	partial "github.com/donseba/go-partial"
 )
@@ -162,3 +163,46 @@ func TestFuncMapRendersConcurrently(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestElapsedReportsRenderDuration(t *testing.T) {
+	fsys := fstest.MapFS{
+		"shell.gohtml": &fstest.MapFile{Data: []byte(`{{ content }}<footer>{{ elapsed }}</footer>`)},
+		"slow.gohtml":  &fstest.MapFile{Data: []byte(`{{ wait }}`)},
+	}
+	newShell := func() *partial.Partial {
+		shell := partial.NewID("shell", "shell.gohtml").
+			SetFileSystem(fsys).
+			SetFunc(FuncMap()).
+			SetFunc(template.FuncMap{"wait": func() string {
+				time.Sleep(2 * time.Millisecond)
+				return ""
+			}})
+		shell.SetContent(partial.NewID("slow", "slow.gohtml"))
+		return shell
+	}
+	footerDuration := func(out template.HTML) time.Duration {
+		t.Helper()
+		text := strings.TrimSuffix(strings.TrimPrefix(string(out), "<footer>"), "</footer>")
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			t.Fatalf("footer %q is not a duration: %v", out, err)
+		}
+		return d
+	}
+
+	out, err := partial.Render(context.Background(), newShell().Use(Stage()))
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if d := footerDuration(out); d < 2*time.Millisecond {
+		t.Fatalf("elapsed = %v, want at least the child's render time", d)
+	}
+
+	out, err = partial.Render(context.Background(), newShell())
+	if err != nil {
+		t.Fatalf("Render() without stage error = %v", err)
+	}
+	if d := footerDuration(out); d != 0 {
+		t.Fatalf("elapsed without stage = %v, want 0", d)
+	}
+}
@@ -131,8 +131,11 @@ func Stage(options ...Option) partial.RenderStage {
 				ctx.Response.Headers = make(map[string]string)
 			}
 			ctx.Response.Headers["Content-Type"] = "text/html; charset=utf-8"
-			ctx.Response.Status = http.StatusInternalServerError
-			if ctx.Name == "fragment" {
+			// Fragments for server failures answer 200 so clients swap them in;
+			// errors with their own status, such as a missing required header,
+			// keep it.
+			ctx.Response.Status = partial.ErrorStatus(ctx.Error)
+			if ctx.Name == "fragment" && ctx.Response.Status == http.StatusInternalServerError {
 				ctx.Response.Status = http.StatusOK
 			}
 
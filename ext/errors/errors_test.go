@@ -126,6 +126,56 @@ func TestWriteUsesErrorRenderResponse(t *testing.T) {
 	}
 }
 
+func TestWriteAnswersMissingHeaderWithBadRequest(t *testing.T) {
+	p := partial.NewID("rows", "rows.gohtml").
+		SetFileSystem(fstest.MapFS{
+			"rows.gohtml": &fstest.MapFile{Data: []byte(`<tr></tr>`)},
+		}).
+		RequireHeader("X-Requested-With", "fetch").
+		Use(Stage())
+
+	req := httptest.NewRequest(http.MethodGet, "/rows", nil)
+	rec := httptest.NewRecorder()
+	var headerErr *partial.MissingHeaderError
+	if err := partial.Write(req.Context(), rec, req, p); !errors.As(err, &headerErr) {
+		t.Fatalf("Write() error = %v, want MissingHeaderError", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestWriteShowsErrorDetailsOnlyInDetailedMode(t *testing.T) {
+	fsys := fstest.MapFS{
+		"broken.gohtml": &fstest.MapFile{Data: []byte(`{{ if .Missing }}missing`)},
+	}
+	for _, mode := range []Mode{ModeDetailed, ModeSafe} {
+		var logged error
+		p := partial.NewID("broken", "broken.gohtml").
+			SetFileSystem(fsys).
+			Use(Stage(WithMode(mode))).
+			SetEvents(partial.EventSinkFunc(func(ctx *partial.RenderContext, event partial.Event) {
+				if event.Kind == partial.EventRenderError && event.Error != nil {
+					logged = event.Error
+				}
+			}))
+
+		req := httptest.NewRequest(http.MethodGet, "/broken", nil)
+		rec := httptest.NewRecorder()
+		if err := partial.Write(req.Context(), rec, req, p); err == nil || !strings.Contains(err.Error(), "unexpected EOF") {
+			t.Fatalf("Write(mode=%d) error = %v, want the render error", mode, err)
+		}
+		if logged == nil || !strings.Contains(logged.Error(), "unexpected EOF") {
+			t.Fatalf("Write(mode=%d) emitted %v, want the render error", mode, logged)
+		}
+
+		detailed := strings.Contains(rec.Body.String(), "unexpected EOF")
+		if detailed != (mode == ModeDetailed) {
+			t.Fatalf("Write(mode=%d) body shows details = %v:\n%s", mode, detailed, rec.Body.String())
+		}
+	}
+}
+
 func TestRendererFinalizeKeepsOriginalRenderErrorWhenErrorTemplateFails(t *testing.T) {
 	originalErr := errors.New("original render failed")
 	rendererErr := errors.New("error template failed")
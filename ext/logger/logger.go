@@ -12,7 +12,8 @@ import (
 
 type (
 	config struct {
-		minLevel partial.EventLevel
+		minLevel  partial.EventLevel
+		requestID func(ctx context.Context) string
 	}
 
 	// Option configures a logger sink.
@@ -33,6 +34,14 @@ func WithMinLevel(level partial.EventLevel) Option {
 	}
 }
 
+// WithRequestID adds a request attribute to every log line, read from the
+// render context with fn, for example a request ID stored by middleware.
+func WithRequestID(fn func(ctx context.Context) string) Option {
+	return func(cfg *config) {
+		cfg.requestID = fn
+	}
+}
+
 // FuncMap returns the optional logger template helper for static parsing and docs.
 func FuncMap() template.FuncMap {
 	return template.FuncMap{
@@ -100,6 +109,15 @@ func Sink(log *slog.Logger, options ...Option) partial.EventSink {
 		if event.Name != "" {
 			attrs = append(attrs, slog.String("name", event.Name))
 		}
+		logCtx := context.Background()
+		if ctx != nil && ctx.Context != nil {
+			logCtx = ctx.Context
+		}
+		if cfg.requestID != nil {
+			if requestID := cfg.requestID(logCtx); requestID != "" {
+				attrs = append(attrs, slog.String("request", requestID))
+			}
+		}
 		if event.TraceID != "" {
 			attrs = append(attrs, slog.String("trace", event.TraceID))
 		}
@@ -114,10 +132,6 @@ func Sink(log *slog.Logger, options ...Option) partial.EventSink {
 		if message == "" {
 			message = event.Kind
 		}
-		logCtx := context.Background()
-		if ctx != nil && ctx.Context != nil {
-			logCtx = ctx.Context
-		}
 		log.LogAttrs(logCtx, slogLevel(event.Level), message, attrs...)
 	})
 }
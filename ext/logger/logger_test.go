@@ -35,6 +35,38 @@ func TestSinkWritesEventsToSlog(t *testing.T) {
 	}
 }
 
+type requestIDKey struct{}
+
+func TestSinkBindsPartialAndRequestID(t *testing.T) {
+	var out bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	sink := Sink(log, WithMinLevel(partial.EventDebug), WithRequestID(func(ctx context.Context) string {
+		requestID, _ := ctx.Value(requestIDKey{}).(string)
+		return requestID
+	}))
+
+	files := fstest.MapFS{
+		"page.gohtml": {Data: []byte(`{{ logger "rendering" }}`)},
+	}
+	p := partial.NewID("page", "page.gohtml").
+		SetFileSystem(files).
+		SetEvents(sink).
+		SetFunc(FuncMap()).
+		Use(Stage())
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "req-42")
+	if _, err := partial.RenderWithRequest(ctx, httptest.NewRequest("GET", "/", nil), p); err != nil {
+		t.Fatalf("RenderWithRequest() error = %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{`msg=rendering`, `partial.partial=page`, `partial.request=req-42`} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("log output missing %q: %s", want, got)
+		}
+	}
+}
+
 func TestSinkFiltersBelowMinimumLevel(t *testing.T) {
 	var out bytes.Buffer
 	log := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
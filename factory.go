@@ -1,5 +1,12 @@
 package partial
 
+import (
+	"context"
+	"net/http"
+)
+
+type factoryContextKey struct{}
+
 // Factory creates request-scoped partials from a configured prototype. The
 // returned values are ordinary *Partial instances and can use the complete
 // native API directly.
@@ -29,3 +36,31 @@ func (f *Factory) New(templates ...string) *Partial {
 func (f *Factory) NewID(id string, templates ...string) *Partial {
 	return f.New(templates...).ID(id)
 }
+
+// Middleware stores the factory in each request context so handlers that only
+// receive the request can build partials with FactoryFromContext.
+func (f *Factory) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(WithFactory(r.Context(), f)))
+	})
+}
+
+// WithFactory returns a child context carrying f.
+func WithFactory(ctx context.Context, f *Factory) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if f == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, factoryContextKey{}, f)
+}
+
+// FactoryFromContext returns the factory attached to ctx, or nil.
+func FactoryFromContext(ctx context.Context) *Factory {
+	if ctx == nil {
+		return nil
+	}
+	f, _ := ctx.Value(factoryContextKey{}).(*Factory)
+	return f
+}
@@ -1,6 +1,11 @@
 package partial
 
-import "testing"
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
 
 func TestFactoryCreatesNativeConfiguredPartials(t *testing.T) {
 	prototype := New("prototype.gohtml").ID("prototype").SetBasePath("/app").SetStatus(201)
@@ -22,3 +27,46 @@ func TestFactoryCreatesNativeConfiguredPartials(t *testing.T) {
 		t.Fatal("factory retained mutable prototype state")
 	}
 }
+
+func TestFactoryMiddlewareStoresFactoryInContext(t *testing.T) {
+	factory := NewFactory(New().SetBasePath("/app"))
+
+	var got *Factory
+	handler := factory.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FactoryFromContext(r.Context())
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got != factory {
+		t.Fatalf("FactoryFromContext() = %p, want %p", got, factory)
+	}
+	if created := got.NewID("content", "content.gohtml"); created.GetBasePath() != "/app" {
+		t.Fatalf("base path = %q, want /app", created.GetBasePath())
+	}
+}
+
+func TestFactoryPartialCanOptOutOfTemplateCache(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("static.gohtml", `static v1`)
+	fsys.AddFile("dynamic.gohtml", `dynamic v1`)
+
+	factory := NewFactory(New().SetFileSystem(fsys).UseTemplateCache(true))
+	static := factory.NewID("static", "static.gohtml")
+	dynamic := factory.NewID("dynamic", "dynamic.gohtml").UseTemplateCache(false)
+
+	for _, p := range []*Partial{static, dynamic} {
+		if _, err := Render(context.Background(), p); err != nil {
+			t.Fatalf("Render(%s) error = %v", p.PartialID(), err)
+		}
+	}
+
+	fsys.AddFile("static.gohtml", `static v2`)
+	fsys.AddFile("dynamic.gohtml", `dynamic v2`)
+
+	if out, err := Render(context.Background(), static); err != nil || out != "static v1" {
+		t.Fatalf("Render(static) = %q, %v; want the cached template", out, err)
+	}
+	if out, err := Render(context.Background(), dynamic); err != nil || out != "dynamic v2" {
+		t.Fatalf("Render(dynamic) = %q, %v; want a fresh parse", out, err)
+	}
+}
@@ -0,0 +1,14 @@
+// Package htmlscan holds the byte classes shared by the lightweight HTML
+// scanners of the root package and the experimental packages.
+package htmlscan
+
+// IsTagSpace reports whether c is HTML whitespace inside a tag.
+func IsTagSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// IsASCIILetter reports whether c is an ASCII letter, as required for the
+// first character of a tag name.
+func IsASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -13,8 +13,17 @@ type CachedTemplate struct {
 }
 
 type Store struct {
-	templates sync.Map
-	mutexes   sync.Map
+	templates   sync.Map
+	calls       sync.Map
+	retryFailed sync.Map
+}
+
+// parseCall is a parse in progress. Callers for the same key wait on done and
+// share its result.
+type parseCall struct {
+	done  chan struct{}
+	entry *CachedTemplate
+	err   error
 }
 
 func NewStore() *Store {
@@ -47,12 +56,74 @@ func (store *Store) Store(key string, entry *CachedTemplate) {
 	store.templates.Store(key, entry)
 }
 
-func (store *Store) Mutex(key string) *sync.Mutex {
+// Parse returns the template cached under key, calling parse to create and
+// cache it when it is missing. Concurrent callers for the same key share one
+// call of parse and its result, including its error. Errors are not cached, so
+// a later call parses again. If parse panics, the panic propagates in the
+// calling goroutine and the waiting callers get an error instead.
+func (store *Store) Parse(key string, parse func() (*CachedTemplate, error)) (*CachedTemplate, error) {
+	if store == nil {
+		return parse()
+	}
+	if entry, ok := store.Load(key); ok {
+		return entry, nil
+	}
+
+	call := &parseCall{done: make(chan struct{})}
+	if value, loaded := store.calls.LoadOrStore(key, call); loaded {
+		call = value.(*parseCall)
+		<-call.done
+		return call.entry, call.err
+	}
+	defer func() {
+		store.calls.Delete(key)
+		close(call.done)
+	}()
+	// Runs before done is closed, so waiters never see a call that ended
+	// without an entry or an error.
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			call.entry, call.err = nil, fmt.Errorf("template parse panicked: %v", recovered)
+			panic(recovered)
+		}
+	}()
+
+	// A previous call may have stored the entry after the first check.
+	if entry, ok := store.Load(key); ok {
+		call.entry = entry
+		return entry, nil
+	}
+	call.entry, call.err = parse()
+	if call.err == nil && call.entry == nil {
+		call.err = fmt.Errorf("template parse returned no template")
+	}
+	if call.err == nil {
+		store.Store(key, call.entry)
+	}
+	return call.entry, call.err
+}
+
+// RetryFailed reports whether the last parse of key failed even after it was
+// retried.
+func (store *Store) RetryFailed(key string) bool {
+	if store == nil {
+		return false
+	}
+	_, failed := store.retryFailed.Load(key)
+	return failed
+}
+
+// SetRetryFailed records whether the last parse of key failed even after it
+// was retried.
+func (store *Store) SetRetryFailed(key string, failed bool) {
 	if store == nil {
-		return &sync.Mutex{}
+		return
+	}
+	if failed {
+		store.retryFailed.Store(key, struct{}{})
+	} else {
+		store.retryFailed.Delete(key)
 	}
-	value, _ := store.mutexes.LoadOrStore(key, &sync.Mutex{})
-	return value.(*sync.Mutex)
 }
 
 func (cached *CachedTemplate) Template(functions template.FuncMap) (*template.Template, func(), error) {
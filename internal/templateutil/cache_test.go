@@ -2,10 +2,13 @@ package templateutil
 
 import (
 	"bytes"
+	"errors"
 	"html/template"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestCachedTemplateCanExecuteConcurrently(t *testing.T) {
@@ -47,3 +50,95 @@ func TestCachedTemplateCanExecuteConcurrently(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestStoreParseSharesOneCallAndDoesNotCacheErrors(t *testing.T) {
+	store := NewStore()
+	release := make(chan struct{})
+	var calls atomic.Int32
+	failing := func() (*CachedTemplate, error) {
+		calls.Add(1)
+		<-release
+		return nil, errors.New("missing")
+	}
+
+	const callers = 16
+	var started, wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for range callers {
+		started.Add(1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started.Done()
+			_, err := store.Parse("page", failing)
+			errs <- err
+		}()
+	}
+	started.Wait()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err == nil || err.Error() != "missing" {
+			t.Fatalf("Parse() error = %v, want the shared parse error", err)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("parse called %d times, want 1", got)
+	}
+
+	base := template.Must(template.New("page").Parse(`ok`))
+	entry, err := store.Parse("page", func() (*CachedTemplate, error) {
+		return NewCachedTemplate(base, nil), nil
+	})
+	if err != nil || entry == nil {
+		t.Fatalf("Parse() after failure = %v, %v, want a fresh parse", entry, err)
+	}
+	if cached, ok := store.Load("page"); !ok || cached != entry {
+		t.Fatalf("Load() = %v, %v, want the parsed entry", cached, ok)
+	}
+}
+
+func TestStoreParsePanicFailsWaiters(t *testing.T) {
+	store := NewStore()
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	panicking := func() (*CachedTemplate, error) {
+		close(entered)
+		<-release
+		panic("boom")
+	}
+
+	panicked := make(chan any, 1)
+	go func() {
+		defer func() { panicked <- recover() }()
+		_, _ = store.Parse("page", panicking)
+	}()
+	<-entered
+
+	waited := make(chan error, 1)
+	go func() {
+		entry, err := store.Parse("page", func() (*CachedTemplate, error) {
+			t.Error("waiter started a second parse")
+			return nil, nil
+		})
+		if entry != nil {
+			t.Errorf("Parse() entry = %v, want nil", entry)
+		}
+		waited <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	if got := <-panicked; got != "boom" {
+		t.Fatalf("recovered %v, want the parse panic to propagate", got)
+	}
+	if err := <-waited; err == nil || err.Error() != "template parse panicked: boom" {
+		t.Fatalf("waiter Parse() error = %v, want the panic as an error", err)
+	}
+	if _, ok := store.Load("page"); ok {
+		t.Fatal("Load() found an entry after a panicking parse")
+	}
+}
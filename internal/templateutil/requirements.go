@@ -25,17 +25,42 @@ var (
 	templateCommentPattern = regexp.MustCompile(`(?s)\{\{/\*(.*?)\*/\}\}`)
 )
 
+// Delims are the template action delimiters. Empty values use "{{" and "}}".
+type Delims struct {
+	Left  string
+	Right string
+}
+
+// Values returns the delimiters with defaults applied.
+func (d Delims) Values() (string, string) {
+	left, right := d.Left, d.Right
+	if left == "" {
+		left = "{{"
+	}
+	if right == "" {
+		right = "}}"
+	}
+	return left, right
+}
+
+// IsDefault reports whether d resolves to the standard delimiters.
+func (d Delims) IsDefault() bool {
+	left, right := d.Values()
+	return left == "{{" && right == "}}"
+}
+
 type RootContract struct {
 	Annotation string
 	Type       string
 }
 
-func RequiredFuncs(name, src string) ([]string, error) {
+func RequiredFuncs(name, src string, delims Delims) ([]string, error) {
 	tree := parse.New(name)
 	tree.Mode = parse.SkipFuncCheck
 
 	treeSet := map[string]*parse.Tree{}
-	parsed, err := tree.Parse(src, "{{", "}}", treeSet)
+	left, right := delims.Values()
+	parsed, err := tree.Parse(src, left, right, treeSet)
 	if err != nil {
 		return nil, err
 	}
@@ -57,12 +82,13 @@ func RequiredFuncs(name, src string) ([]string, error) {
 	return funcs, nil
 }
 
-func ReferencedTemplates(name, src string) ([]string, error) {
+func ReferencedTemplates(name, src string, delims Delims) ([]string, error) {
 	tree := parse.New(name)
 	tree.Mode = parse.SkipFuncCheck
 
 	treeSet := map[string]*parse.Tree{}
-	parsed, err := tree.Parse(src, "{{", "}}", treeSet)
+	left, right := delims.Values()
+	parsed, err := tree.Parse(src, left, right, treeSet)
 	if err != nil {
 		return nil, err
 	}
@@ -81,12 +107,13 @@ func ReferencedTemplates(name, src string) ([]string, error) {
 	return names, nil
 }
 
-func DefinedTemplates(name, src string) ([]string, error) {
+func DefinedTemplates(name, src string, delims Delims) ([]string, error) {
 	tree := parse.New(name)
 	tree.Mode = parse.SkipFuncCheck
 
 	treeSet := map[string]*parse.Tree{}
-	parsed, err := tree.Parse(src, "{{", "}}", treeSet)
+	left, right := delims.Values()
+	parsed, err := tree.Parse(src, left, right, treeSet)
 	if err != nil {
 		return nil, err
 	}
@@ -104,14 +131,14 @@ func DefinedTemplates(name, src string) ([]string, error) {
 	return names, nil
 }
 
-func RequiredFuncsFromFS(fsys fs.FS, names []string) (map[string]struct{}, error) {
+func RequiredFuncsFromFS(fsys fs.FS, names []string, delims Delims) (map[string]struct{}, error) {
 	found := make(map[string]struct{})
 	for _, name := range names {
 		content, err := fs.ReadFile(fsys, name)
 		if err != nil {
 			return nil, err
 		}
-		funcs, err := RequiredFuncs(name, string(content))
+		funcs, err := RequiredFuncs(name, string(content), delims)
 		if err != nil {
 			return nil, err
 		}
@@ -122,14 +149,14 @@ func RequiredFuncsFromFS(fsys fs.FS, names []string) (map[string]struct{}, error
 	return found, nil
 }
 
-func ReferencedTemplatesFromFS(fsys fs.FS, names []string) map[string]struct{} {
+func ReferencedTemplatesFromFS(fsys fs.FS, names []string, delims Delims) map[string]struct{} {
 	found := make(map[string]struct{})
 	for _, name := range names {
 		content, err := fs.ReadFile(fsys, name)
 		if err != nil {
 			continue
 		}
-		refs, err := ReferencedTemplates(name, string(content))
+		refs, err := ReferencedTemplates(name, string(content), delims)
 		if err != nil {
 			continue
 		}
@@ -140,7 +167,7 @@ func ReferencedTemplatesFromFS(fsys fs.FS, names []string) map[string]struct{} {
 	return found
 }
 
-func DefinedTemplatesFromFS(fsys fs.FS, names []string) map[string]struct{} {
+func DefinedTemplatesFromFS(fsys fs.FS, names []string, delims Delims) map[string]struct{} {
 	found := make(map[string]struct{})
 	for _, name := range names {
 		found[name] = struct{}{}
@@ -153,7 +180,7 @@ func DefinedTemplatesFromFS(fsys fs.FS, names []string) map[string]struct{} {
 		if err != nil {
 			continue
 		}
-		defined, err := DefinedTemplates(name, string(content))
+		defined, err := DefinedTemplates(name, string(content), delims)
 		if err != nil {
 			continue
 		}
@@ -220,7 +247,7 @@ func PathAliases(name string) []string {
 	return append(aliases, "/"+trimmed)
 }
 
-func AddPathAliases(tmpl *template.Template, names []string) error {
+func AddPathAliases(tmpl *template.Template, names []string, delims Delims) error {
 	if tmpl == nil {
 		return nil
 	}
@@ -233,7 +260,8 @@ func AddPathAliases(tmpl *template.Template, names []string) error {
 			if tmpl.Lookup(alias) != nil {
 				continue
 			}
-			if _, err := tmpl.New(alias).Parse(fmt.Sprintf(`{{ template %q . }}`, base)); err != nil {
+			left, right := delims.Values()
+			if _, err := tmpl.New(alias).Parse(fmt.Sprintf(`%s template %q . %s`, left, base, right)); err != nil {
 				return err
 			}
 		}
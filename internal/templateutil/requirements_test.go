@@ -6,7 +6,7 @@ import (
 )
 
 func TestRequiredFunctionScannerFindsTopLevelFunctions(t *testing.T) {
-	funcs, err := RequiredFuncs("page.gohtml", `{{ partial runtime "templates/content.gohtml" }}{{ if eq .Status "ok" }}{{ debug runtime . }}{{ end }}`)
+	funcs, err := RequiredFuncs("page.gohtml", `{{ partial runtime "templates/content.gohtml" }}{{ if eq .Status "ok" }}{{ debug runtime . }}{{ end }}`, Delims{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -18,7 +18,7 @@ func TestRequiredFunctionScannerFindsTopLevelFunctions(t *testing.T) {
 }
 
 func TestRequiredFunctionScannerFindsDefinedTemplateFunctions(t *testing.T) {
-	funcs, err := RequiredFuncs("page.gohtml", `{{ define "row" }}{{ partial runtime "templates/row.gohtml" . }}{{ end }}`)
+	funcs, err := RequiredFuncs("page.gohtml", `{{ define "row" }}{{ partial runtime "templates/row.gohtml" . }}{{ end }}`, Delims{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -30,7 +30,7 @@ func TestRequiredFunctionScannerFindsDefinedTemplateFunctions(t *testing.T) {
 }
 
 func TestRequiredFunctionScannerFindsPipelineFunctions(t *testing.T) {
-	funcs, err := RequiredFuncs("page.gohtml", `{{ .Price | money }} {{ printf "%s" .Name }}`)
+	funcs, err := RequiredFuncs("page.gohtml", `{{ .Price | money }} {{ printf "%s" .Name }}`, Delims{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,72 @@
+package partial
+
+import (
+	"encoding/json"
+	"slices"
+)
+
+type (
+	// SelectionKeyer is implemented by extension values that render one of
+	// several named partials, such as the exp/selection map. MarshalJSON uses
+	// it to list the selection keys of a partial.
+	SelectionKeyer interface {
+		SelectionKeys() []string
+	}
+
+	// partialJSON is the serialized structure of a partial tree.
+	partialJSON struct {
+		ID            string              `json:"id"`
+		Aliases       []string            `json:"aliases,omitempty"`
+		Templates     []string            `json:"templates,omitempty"`
+		TemplatesFor  map[string][]string `json:"templatesFor,omitempty"`
+		Content       string              `json:"content,omitempty"`
+		OOB           bool                `json:"oob,omitempty"`
+		SelectionKeys []string            `json:"selectionKeys,omitempty"`
+		Children      []partialJSON       `json:"children,omitempty"`
+	}
+)
+
+// MarshalJSON serializes the structure of the partial tree: IDs, aliases,
+// templates, the content child, OOB flags, selection keys, and children
+// sorted by ID. It describes composition for tooling such as inspectors and
+// does not render anything; the result cannot be decoded back into a Partial.
+func (p *Partial) MarshalJSON() ([]byte, error) {
+	if p == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(p.describe(false))
+}
+
+func (p *Partial) describe(oob bool) partialJSON {
+	p.mu.RLock()
+	out := partialJSON{
+		ID:        p.id,
+		Aliases:   slices.Clone(p.aliases),
+		Templates: slices.Clone(p.templates),
+		Content:   p.contentID,
+		OOB:       oob,
+	}
+	if len(p.templatesFor) > 0 {
+		out.TemplatesFor = make(map[string][]string, len(p.templatesFor))
+		for name, templates := range p.templatesFor {
+			out.TemplatesFor[name] = slices.Clone(templates)
+		}
+	}
+	for _, value := range p.extensions {
+		if keyer, ok := value.(SelectionKeyer); ok {
+			out.SelectionKeys = append(out.SelectionKeys, keyer.SelectionKeys()...)
+		}
+	}
+	oobChildren := make(map[string]bool, len(p.oobChildren))
+	for id := range p.oobChildren {
+		oobChildren[id] = true
+	}
+	p.mu.RUnlock()
+
+	slices.Sort(out.SelectionKeys)
+	out.SelectionKeys = slices.Compact(out.SelectionKeys)
+	for _, child := range p.Children() {
+		out.Children = append(out.Children, child.describe(oobChildren[child.id]))
+	}
+	return out
+}
@@ -0,0 +1,39 @@
+package partial
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type testSelection struct{}
+
+func (testSelection) SelectionKeys() []string { return []string{"profile", "account"} }
+
+func TestMarshalJSONDescribesTree(t *testing.T) {
+	root := NewID("root", "shell.gohtml")
+	root.SetContent(NewID("content", "content.gohtml").SetExtension(testSelection{}, testSelection{}))
+	root.WithOOB(NewID("toast", "toast.gohtml"))
+
+	data, err := json.Marshal(root)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	var got partialJSON
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if got.ID != "root" || got.Content != "content" || len(got.Children) != 2 {
+		t.Fatalf("unexpected root: %s", data)
+	}
+	content, toast := got.Children[0], got.Children[1]
+	if content.ID != "content" || content.OOB || len(content.Templates) != 1 || content.Templates[0] != "content.gohtml" {
+		t.Fatalf("unexpected content child: %+v", content)
+	}
+	if len(content.SelectionKeys) != 2 || content.SelectionKeys[0] != "account" {
+		t.Fatalf("SelectionKeys = %v, want sorted keys", content.SelectionKeys)
+	}
+	if toast.ID != "toast" || !toast.OOB {
+		t.Fatalf("unexpected oob child: %+v", toast)
+	}
+}
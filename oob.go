@@ -0,0 +1,133 @@
+package partial
+
+import (
+	"html/template"
+	"strings"
+
+	"github.com/donseba/go-partial/internal/htmlscan"
+)
+
+// injectOOBAttr adds name="value" to the root element of an OOB fragment.
+// The fragment is returned unchanged when the root element already carries the
+// attribute or when no root start tag can be found, leaving templates that use
+// oobAttr in charge.
+func injectOOBAttr(fragment template.HTML, name, value string) template.HTML {
+	s := string(fragment)
+	start, ok := rootTagStart(s)
+	if !ok {
+		return fragment
+	}
+
+	nameEnd := start + 1
+	for nameEnd < len(s) && !htmlscan.IsTagSpace(s[nameEnd]) && s[nameEnd] != '/' && s[nameEnd] != '>' {
+		nameEnd++
+	}
+
+	present, ok := tagHasAttr(s, nameEnd, name)
+	if !ok || present {
+		return fragment
+	}
+
+	attr := " " + name + `="` + template.HTMLEscapeString(value) + `"`
+	return template.HTML(s[:nameEnd] + attr + s[nameEnd:])
+}
+
+// rootTagStart returns the index of the first start tag, skipping leading
+// whitespace and comments. Text before the first tag means there is no single
+// root element to mark.
+func rootTagStart(s string) (int, bool) {
+	i := 0
+	for i < len(s) {
+		switch {
+		case htmlscan.IsTagSpace(s[i]):
+			i++
+		case strings.HasPrefix(s[i:], "<!--"):
+			end := strings.Index(s[i+4:], "-->")
+			if end < 0 {
+				return 0, false
+			}
+			i += 4 + end + 3
+		case s[i] == '<' && i+1 < len(s) && htmlscan.IsASCIILetter(s[i+1]):
+			return i, true
+		default:
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// rootElementID returns the id attribute of the fragment's root element. ok is
+// false when the fragment has no single root element or it carries no id.
+func rootElementID(fragment template.HTML) (id string, ok bool) {
+	s := string(fragment)
+	start, found := rootTagStart(s)
+	if !found {
+		return "", false
+	}
+	nameEnd := start + 1
+	for nameEnd < len(s) && !htmlscan.IsTagSpace(s[nameEnd]) && s[nameEnd] != '/' && s[nameEnd] != '>' {
+		nameEnd++
+	}
+	id, present, terminated := tagAttr(s, nameEnd, "id")
+	return id, present && terminated
+}
+
+// tagHasAttr scans the attributes of the tag starting at pos and reports
+// whether name is among them. ok is false when the tag is not terminated.
+func tagHasAttr(s string, pos int, name string) (present bool, ok bool) {
+	_, present, ok = tagAttr(s, pos, name)
+	return present, ok
+}
+
+// tagAttr scans the attributes of the tag starting at pos and returns the
+// value of name, unquoted but not unescaped. ok is false when the tag is not
+// terminated.
+func tagAttr(s string, pos int, name string) (value string, present bool, ok bool) {
+	for pos < len(s) {
+		c := s[pos]
+		switch {
+		case c == '>':
+			return value, present, true
+		case c == '/' || htmlscan.IsTagSpace(c):
+			pos++
+		default:
+			attrStart := pos
+			for pos < len(s) && !htmlscan.IsTagSpace(s[pos]) && s[pos] != '=' && s[pos] != '>' && s[pos] != '/' {
+				pos++
+			}
+			matched := !present && strings.EqualFold(s[attrStart:pos], name)
+			if matched {
+				present = true
+			}
+			for pos < len(s) && htmlscan.IsTagSpace(s[pos]) {
+				pos++
+			}
+			if pos >= len(s) || s[pos] != '=' {
+				continue
+			}
+			pos++
+			for pos < len(s) && htmlscan.IsTagSpace(s[pos]) {
+				pos++
+			}
+			if pos < len(s) && (s[pos] == '"' || s[pos] == '\'') {
+				end := strings.IndexByte(s[pos+1:], s[pos])
+				if end < 0 {
+					return "", false, false
+				}
+				if matched {
+					value = s[pos+1 : pos+1+end]
+				}
+				pos += end + 2
+				continue
+			}
+			valueStart := pos
+			for pos < len(s) && !htmlscan.IsTagSpace(s[pos]) && s[pos] != '>' {
+				pos++
+			}
+			if matched {
+				value = s[valueStart:pos]
+			}
+		}
+	}
+	return "", false, false
+}
@@ -0,0 +1,145 @@
+package partial
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/donseba/go-partial/connector"
+)
+
+func TestInjectOOBAttr(t *testing.T) {
+	tests := []struct {
+		name     string
+		fragment template.HTML
+		want     template.HTML
+	}{
+		{name: "root element", fragment: `<footer id="footer">footer</footer>`, want: `<footer hx-swap-oob="true" id="footer">footer</footer>`},
+		{name: "leading whitespace and comment", fragment: "\n<!-- footer --><div>x</div>", want: "\n<!-- footer --><div hx-swap-oob=\"true\">x</div>"},
+		{name: "attribute already present", fragment: `<div id="x" hx-swap-oob="outerHTML">x</div>`, want: `<div id="x" hx-swap-oob="outerHTML">x</div>`},
+		{name: "quoted value containing the name", fragment: `<div title="hx-swap-oob">x</div>`, want: `<div hx-swap-oob="true" title="hx-swap-oob">x</div>`},
+		{name: "text before root", fragment: `text<div>x</div>`, want: `text<div>x</div>`},
+		{name: "unterminated tag", fragment: `<div id="x`, want: `<div id="x`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := injectOOBAttr(tt.fragment, "hx-swap-oob", "true"); got != tt.want {
+				t.Fatalf("injectOOBAttr() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRootElementID(t *testing.T) {
+	cases := []struct {
+		fragment template.HTML
+		id       string
+		ok       bool
+	}{
+		{`<div id="content">x</div>`, "content", true},
+		{`<!-- note --> <div class="a" ID='content'>x</div>`, "content", true},
+		{`<div data-id="x" id=content>x</div>`, "content", true},
+		{`<div class="content">x</div>`, "", false},
+		{`text <div id="content">x</div>`, "", false},
+	}
+	for _, c := range cases {
+		id, ok := rootElementID(c.fragment)
+		if id != c.id || ok != c.ok {
+			t.Errorf("rootElementID(%q) = %q, %v; want %q, %v", c.fragment, id, ok, c.id, c.ok)
+		}
+	}
+}
+
+func TestOOBChildGetsConnectorAttributeWithoutTemplateConditional(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("content.gohtml", `<div id="content">content</div>`)
+	fsys.AddFile("footer.gohtml", `<footer id="footer">footer</footer>`)
+
+	shell := NewID("shell", "shell.gohtml").
+		SetFileSystem(fsys).
+		SetConnector(connector.NewHTMX(nil))
+	shell.SetContent(NewID("content", "content.gohtml"))
+	shell.WithOOB(NewID("footer", "footer.gohtml"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+	req.Header.Set(connector.HTMXHeaderTarget.String(), "content")
+
+	out, err := RenderWithRequest(context.Background(), req, shell)
+	if err != nil {
+		t.Fatalf("RenderWithRequest() error = %v", err)
+	}
+	want := template.HTML(`<div id="content">content</div><footer hx-swap-oob="true" id="footer">footer</footer>`)
+	if out != want {
+		t.Fatalf("output = %q, want %q", out, want)
+	}
+}
+
+func TestSetOOBPositionOrdersOOBAroundTarget(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("content.gohtml", `<div id="content">content</div>`)
+	fsys.AddFile("footer.gohtml", `<footer id="footer">footer</footer>`)
+
+	tests := []struct {
+		position OOBPosition
+		want     template.HTML
+	}{
+		{OOBAfter, `<div id="content">content</div><footer hx-swap-oob="true" id="footer">footer</footer>`},
+		{OOBBefore, `<footer hx-swap-oob="true" id="footer">footer</footer><div id="content">content</div>`},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.position), func(t *testing.T) {
+			shell := NewID("shell", "shell.gohtml").
+				SetFileSystem(fsys).
+				SetConnector(connector.NewHTMX(nil)).
+				SetOOBPosition(tt.position)
+			shell.SetContent(NewID("content", "content.gohtml"))
+			shell.WithOOB(NewID("footer", "footer.gohtml"))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+			req.Header.Set(connector.HTMXHeaderTarget.String(), "content")
+
+			out, err := RenderWithRequest(context.Background(), req, shell)
+			if err != nil {
+				t.Fatalf("RenderWithRequest() error = %v", err)
+			}
+			if out != tt.want {
+				t.Fatalf("output = %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetOOBOnlyWritesOnlyOOBRegions(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("like.gohtml", `<button id="like">like</button>`)
+	fsys.AddFile("counter.gohtml", `<span id="counter">42</span>`)
+
+	shell := NewID("shell", "shell.gohtml").
+		SetFileSystem(fsys).
+		SetConnector(connector.NewHTMX(nil))
+	shell.SetContent(NewID("like", "like.gohtml").SetOOBOnly(true))
+	shell.WithOOB(NewID("counter", "counter.gohtml"))
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+	req.Header.Set(connector.HTMXHeaderTarget.String(), "like")
+	rec := httptest.NewRecorder()
+
+	if err := Write(context.Background(), rec, req, shell); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got, want := rec.Body.String(), `<span hx-swap-oob="true" id="counter">42</span>`; got != want {
+		t.Fatalf("body = %q, want %q", got, want)
+	}
+	if got := rec.Header().Get(connector.HTMXHeaderReswap.String()); got != "none" {
+		t.Fatalf("HX-Reswap = %q, want %q", got, "none")
+	}
+}
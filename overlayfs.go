@@ -0,0 +1,61 @@
+package partial
+
+import (
+	"errors"
+	"io/fs"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// overlayIDs gives every overlay configuration a distinct template cache scope.
+var overlayIDs atomic.Uint64
+
+// overlayFS resolves each name in the first layer that contains it.
+type overlayFS struct {
+	layers []fs.FS
+}
+
+func (o *overlayFS) Open(name string) (fs.File, error) {
+	var firstErr error
+	for _, layer := range o.layers {
+		f, err := layer.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (o *overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	seen := make(map[string]struct{})
+	var entries []fs.DirEntry
+	found := false
+	for _, layer := range o.layers {
+		layerEntries, err := fs.ReadDir(layer, name)
+		if err != nil {
+			continue
+		}
+		found = true
+		for _, entry := range layerEntries {
+			if _, ok := seen[entry.Name()]; ok {
+				continue
+			}
+			seen[entry.Name()] = struct{}{}
+			entries = append(entries, entry)
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
@@ -12,9 +12,14 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"reflect"
+	"runtime/debug"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/donseba/go-partial/connector"
 	"github.com/donseba/go-partial/internal/templateutil"
@@ -25,29 +30,84 @@ var (
 	// for its own tree, request, connector, and runtime behavior. Optional
 	// helper providers must not overwrite these names.
 	coreFunctionNames = templateutil.Names(placeholderRequestFuncMap())
+
+	// treeFunctionNames are the core helpers that render the partial tree and
+	// reveal nothing about the request. They stay available under
+	// SetFuncAllowlist; the other core helpers, such as request and ctx, must
+	// be listed.
+	treeFunctionNames = map[string]struct{}{
+		"partial":       {},
+		"render":        {},
+		"partialExists": {},
+		"include":       {},
+		"content":       {},
+		"oob":           {},
+		"oobAttr":       {},
+		"id":            {},
+		"basePath":      {},
+		"joinPath":      {},
+		"urlPath":       {},
+	}
+
+	// templateParseRetryDelay is the pause before a template parse that failed
+	// with a file system error is retried.
+	templateParseRetryDelay = 10 * time.Millisecond
 )
 
 type (
 	// Partial stores reusable template, data, and child-tree configuration.
 	Partial struct {
 		id              string
+		aliases         []string
 		parent          *Partial
 		contentID       string
 		renderOOB       bool
 		alwaysSwapOOB   bool
+		memoize         bool
+		memoKey         MemoKeyFunc
 		fs              fs.FS
 		fsSet           bool
+		overlays        []fs.FS
+		overlayID       uint64
+		baseDir         string
+		templateExt     string
+		delims          templateutil.Delims
 		connector       connector.Connector
+		connectors      []connector.Connector
 		useCache        bool
 		templates       []string
+		templatesFor    map[string][]string
 		staticFuncs     template.FuncMap
+		funcCache       atomic.Pointer[funcMapCache]
+		funcAliases     map[string]string
+		funcAllowlist   map[string]struct{}
+		protectedFuncs  []string
 		basePath        string
 		contracts       []contractInformation
+		dotTransform    DotTransform
+		resolver        TemplateResolver
+		trustedKeys     []string
 		extensions      map[any]any
 		responseHeaders map[string]string
+		requiredHeaders map[string]string
+		cacheControl    string
+		deleteOnEmpty   bool
+		oobOnly         bool
+		lastModified    LastModifiedFunc
 		responseStatus  int
+		contentType     string
+		maxOutputBytes  int64
+		recoverPanics   bool
+		fragmentMarkers bool
+		oobPosition     OOBPosition
+		validateSwaps   bool
+		renderTimeout   time.Duration
 		response        connector.Response
 		events          EventSink
+		errorHandler    ErrorHandler
+		notFound        *Partial
+		unknownTarget   UnknownTargetBehavior
+		defaultTarget   string
 		stages          []RenderStage
 		templateCache   *templateutil.Store
 		mu              sync.RWMutex
@@ -71,8 +131,32 @@ type (
 		Response *RenderResponse
 		Funcs    template.FuncMap
 		Events   EventSink
+
+		// executing is the template set currently executing for this render,
+		// used by the include helper.
+		executing *template.Template
 	}
 
+	// ErrorHandler writes the HTTP response for a failed render. It replaces
+	// the default failure response of Write.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+	// DotTransform returns the dot value a template executes with. It receives
+	// the active render context and the configured dot, which may be nil.
+	DotTransform func(ctx *RenderContext, dot any) (any, error)
+
+	// LastModifiedFunc returns when the data behind a partial last changed,
+	// for example the UpdatedAt of the dot. The zero time means unknown.
+	LastModifiedFunc func(r *http.Request, dot any) time.Time
+
+	// MemoKeyFunc returns the key that tells memoized renders of one partial
+	// apart, for example the ID of the record the dot shows.
+	MemoKeyFunc func(dot any) string
+
+	// TemplateResolver returns the template paths to render for the active
+	// render context and dot, such as a template chosen by a CMS block type.
+	TemplateResolver func(ctx *RenderContext, dot any) ([]string, error)
+
 	contractKind string
 
 	// contractInformation binds a Go value to a typed go-doc root declaration.
@@ -91,6 +175,12 @@ type (
 	NamedContract interface {
 		ContractName() string
 	}
+
+	// Component is a reusable type that builds its own partial, such as a
+	// struct holding typed data for one piece of UI.
+	Component interface {
+		Partial() *Partial
+	}
 )
 
 const (
@@ -119,6 +209,37 @@ func NewID(id string, templates ...string) *Partial {
 	return New(templates...).ID(id)
 }
 
+// NewGroup creates a partial with the provided ID that renders its members in
+// order and concatenates their HTML, so several partials can be addressed as a
+// single target. The group has no templates of its own; members are registered
+// as its children and inherit from it like any other child. A member without
+// an ID, with the ID of another member, or with the group's own ID cannot be
+// addressed on its own; rendering such a group fails with ErrInvalidGroup.
+func NewGroup(id string, members ...*Partial) *Partial {
+	group := NewID(id)
+	ids := make([]string, 0, len(members))
+	var err error
+	for _, member := range members {
+		if member == nil {
+			continue
+		}
+		switch {
+		case member.id == "":
+			err = fmt.Errorf("%w: group %s has a member without an ID", ErrInvalidGroup, id)
+		case member.id == id:
+			err = fmt.Errorf("%w: group %s has a member with the group's own ID", ErrInvalidGroup, id)
+		case slices.Contains(ids, member.id):
+			err = fmt.Errorf("%w: group %s has more than one member with ID %s", ErrInvalidGroup, id, member.id)
+		}
+		if err != nil {
+			break
+		}
+		group.With(member)
+		ids = append(ids, member.id)
+	}
+	return group.Use(groupStage(id, ids, err))
+}
+
 // ID sets the stable ID used for target lookup, child registration, and diagnostics.
 func (p *Partial) ID(id string) *Partial {
 	p.id = id
@@ -133,6 +254,25 @@ func (p *Partial) PartialID() string {
 	return p.id
 }
 
+// AddAlias registers additional IDs that resolve to this partial, for example
+// when a target ID changed but older clients still request the old one.
+func (p *Partial) AddAlias(ids ...string) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.aliases = append(p.aliases, ids...)
+	return p
+}
+
+func (p *Partial) hasAlias(id string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return slices.Contains(p.aliases, id)
+}
+
 // ParentID returns the ID of the parent partial, if one is attached.
 func (p *Partial) ParentID() string {
 	if p == nil || p.parent == nil {
@@ -141,6 +281,26 @@ func (p *Partial) ParentID() string {
 	return p.parent.PartialID()
 }
 
+// Parent returns the parent partial, or nil when p is the root of its tree.
+func (p *Partial) Parent() *Partial {
+	if p == nil {
+		return nil
+	}
+	return p.parent
+}
+
+// Breadcrumbs returns the partial IDs from the root of the tree down to this
+// partial. During a render, templates can read it as
+// {{ ctx.Partial.Breadcrumbs }}.
+func (p *Partial) Breadcrumbs() []string {
+	var trail []string
+	for current := p; current != nil; current = current.parent {
+		trail = append(trail, current.PartialID())
+	}
+	slices.Reverse(trail)
+	return trail
+}
+
 // TemplatePaths returns the template paths configured for this partial.
 func (p *Partial) TemplatePaths() []string {
 	if p == nil {
@@ -164,6 +324,22 @@ func (p *Partial) SetTemplates(templates ...string) *Partial {
 	return p
 }
 
+// TemplatesFor sets the template paths used when the active connector has the
+// given name, such as connector.NameHTMX or connector.NameTurbo. Connectors
+// without a template set fall back to the default templates.
+func (p *Partial) TemplatesFor(connectorName string, templates ...string) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.templatesFor == nil {
+		p.templatesFor = make(map[string][]string)
+	}
+	p.templatesFor[connectorName] = slices.Clone(templates)
+	return p
+}
+
 // IsOOB reports whether the partial is currently being rendered out-of-band.
 func (p *Partial) IsOOB() bool {
 	if p == nil {
@@ -227,6 +403,23 @@ func (p *Partial) Use(stages ...RenderStage) *Partial {
 	return p
 }
 
+// Decorate wraps every render of this partial and its children with
+// decorators, in order, so the first decorator is the outermost. Decorators
+// are render stages; use Use directly for stages that also need Prepare or
+// Finalize.
+func (p *Partial) Decorate(decorators ...Decorator) *Partial {
+	if p == nil {
+		return nil
+	}
+	stages := make([]RenderStage, 0, len(decorators))
+	for _, decorator := range decorators {
+		if decorator != nil {
+			stages = append(stages, decoratorStage(decorator))
+		}
+	}
+	return p.Use(stages...)
+}
+
 // SetBasePath sets the base path for the partial.
 func (p *Partial) SetBasePath(basePath string) *Partial {
 	if p == nil {
@@ -258,6 +451,99 @@ func (p *Partial) SetDot(value any) *Partial {
 	return p
 }
 
+// AddDot sets key on a map[string]any dot, creating the map when no dot is
+// configured, so handlers can add one value without rebuilding the whole dot.
+// The caller's map passed to SetDot is not modified. Dots of other types are
+// left unchanged.
+func (p *Partial) AddDot(key string, value any) *Partial {
+	return p.updateMapDot(func(dot map[string]any) {
+		dot[key] = value
+	})
+}
+
+// MergeDot copies values into a map[string]any dot like AddDot. Keys that
+// already exist keep their value unless override is true.
+func (p *Partial) MergeDot(values map[string]any, override bool) *Partial {
+	return p.updateMapDot(func(dot map[string]any) {
+		for key, value := range values {
+			if _, exists := dot[key]; exists && !override {
+				continue
+			}
+			dot[key] = value
+		}
+	})
+}
+
+func (p *Partial) updateMapDot(update func(dot map[string]any)) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	values := map[string]any{}
+	for i := len(p.contracts) - 1; i >= 0; i-- {
+		if p.contracts[i].Kind != contractDot {
+			continue
+		}
+		existing, ok := p.contracts[i].Value.(map[string]any)
+		if !ok && p.contracts[i].Value != nil {
+			return p
+		}
+		if existing != nil {
+			values = maps.Clone(existing)
+		}
+		break
+	}
+	update(values)
+	p.upsertContractLocked(contractInformation{Kind: contractDot, Value: values}, func(existing contractInformation) bool {
+		return existing.Kind == contractDot
+	})
+	return p
+}
+
+// SetDotTransform registers a function that prepares the dot value right
+// before the partial's template executes, after stages and actions have run.
+// It applies to this partial only.
+func (p *Partial) SetDotTransform(transform DotTransform) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dotTransform = transform
+	return p
+}
+
+// SetTemplateResolver configures a function that chooses this partial's
+// template paths at render time from the dot, after any dot transform. The
+// resolved paths replace the configured templates for that render, resolve
+// against the base directory, and are part of the template cache key.
+func (p *Partial) SetTemplateResolver(resolver TemplateResolver) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resolver = resolver
+	return p
+}
+
+// SetTrustedKeys marks keys of a map dot whose string values are trusted HTML.
+// Those values are passed to the template as template.HTML and are not
+// escaped. Only use it for content that is already sanitized: an untrusted
+// value under a trusted key is an XSS vulnerability. It applies to this
+// partial only and to map[string]any and map[string]string dots.
+func (p *Partial) SetTrustedKeys(keys ...string) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.trustedKeys = slices.Clone(keys)
+	return p
+}
+
 // ClearDot removes the explicit root value.
 func (p *Partial) ClearDot() *Partial {
 	if p == nil {
@@ -340,177 +626,963 @@ func (p *Partial) getResponseHeaders() map[string]string {
 	return nil
 }
 
-// SetStatus configures the HTTP status written by Write. A zero status clears
-// the local value and falls back to the parent partial, then to net/http's
-// default status.
-func (p *Partial) SetStatus(status int) *Partial {
+// SetCacheControl sets the Cache-Control header Write sends when p is the
+// rendered partial, either the response root or the requested target, such as
+// "public, max-age=60" for a navigation fragment. Unlike SetResponseHeaders,
+// the value is not inherited: other targets in the tree send no Cache-Control
+// unless they set their own. Because the full page and its fragments share a
+// URL, a response with Cache-Control also carries a Vary header listing the
+// request headers of the configured connectors, so shared caches keep them
+// apart.
+func (p *Partial) SetCacheControl(value string) *Partial {
 	if p == nil {
 		return nil
 	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.responseStatus = status
+
+	p.cacheControl = value
 	return p
 }
 
-func (p *Partial) getStatus() int {
+func (p *Partial) getCacheControl() string {
 	if p == nil {
-		return 0
-	}
-	p.mu.RLock()
-	status := p.responseStatus
-	parent := p.parent
-	p.mu.RUnlock()
-	if status > 0 {
-		return status
-	}
-	if parent != nil {
-		return parent.getStatus()
+		return ""
 	}
-	return 0
-}
 
-// Response returns a builder for connector-specific response instructions.
-func (p *Partial) Response() *connector.ResponseBuilder {
-	if p == nil {
-		return connector.NewResponseBuilder(nil)
-	}
-	return connector.NewResponseBuilder(&p.response)
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.cacheControl
 }
 
-// SetResponse configures connector-specific response instructions.
-func (p *Partial) SetResponse(response connector.Response) *Partial {
+// SetDeleteOnEmpty makes a render of p that produces only whitespace ask the
+// client to delete the target element instead of swapping in blank content.
+// The connector expresses the delete swap, for example HX-Reswap: delete with
+// htmx. It applies when p is the rendered partial.
+func (p *Partial) SetDeleteOnEmpty(deleteOnEmpty bool) *Partial {
 	if p == nil {
 		return nil
 	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	p.response = response
+	p.deleteOnEmpty = deleteOnEmpty
 	return p
 }
 
-// SetEvents configures the diagnostic event sink inherited by this partial tree.
-func (p *Partial) SetEvents(events EventSink) *Partial {
+// emptyResponseHeaders returns the connector headers asking the client to
+// delete the target when p is configured with SetDeleteOnEmpty and its own
+// HTML was empty.
+func (p *Partial) emptyResponseHeaders(empty bool) map[string]string {
+	p.mu.RLock()
+	deleteOnEmpty := p.deleteOnEmpty
+	p.mu.RUnlock()
+	if !deleteOnEmpty || !empty {
+		return nil
+	}
+	return p.getConnectorOrDefault().ResponseHeaders(connector.Response{Reswap: string(connector.SwapDelete)})
+}
+
+func isBlankHTML(html template.HTML) bool {
+	return strings.TrimSpace(string(html)) == ""
+}
+
+// SetOOBOnly makes a partial request targeting p answer with the out-of-band
+// regions only. p is still rendered, so its stages and actions run, but its
+// HTML is dropped and the client is asked not to swap the target, which
+// leaves the triggering element in place.
+func (p *Partial) SetOOBOnly(oobOnly bool) *Partial {
 	if p == nil {
 		return nil
 	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	p.events = events
+	p.oobOnly = oobOnly
 	return p
 }
 
-// GetBasePath returns the configured base path, falling back to parents.
-func (p *Partial) GetBasePath() string {
+func (p *Partial) getOOBOnly() bool {
 	if p == nil {
-		return ""
+		return false
 	}
 
 	p.mu.RLock()
-	basePath := p.basePath
-	parent := p.parent
-	p.mu.RUnlock()
-
-	if basePath != "" {
-		return basePath
-	}
+	defer p.mu.RUnlock()
+	return p.oobOnly
+}
 
-	if parent != nil {
-		return parent.GetBasePath()
+// oobOnlyResponseHeaders returns the connector headers asking the client not
+// to swap the target of an OOB-only response.
+func (p *Partial) oobOnlyResponseHeaders() map[string]string {
+	if !p.getOOBOnly() {
+		return nil
 	}
-
-	return ""
+	return p.getConnectorOrDefault().ResponseHeaders(connector.Response{Reswap: string(connector.SwapNone)})
 }
 
-// SetConnector configures the request connector used by this partial.
-func (p *Partial) SetConnector(c connector.Connector) *Partial {
+// SetLastModified configures a function reporting when the data behind p
+// last changed. When Write renders p for a GET or HEAD request, as the root or
+// as the requested target, it sets the Last-Modified header and answers 304
+// Not Modified without rendering when the request's If-Modified-Since is not
+// older than that time. The times of other partials in the tree do not apply.
+func (p *Partial) SetLastModified(fn LastModifiedFunc) *Partial {
 	if p == nil {
 		return nil
 	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	p.connector = c
+	p.lastModified = fn
 	return p
 }
 
-// SetAlwaysSwapOOB makes this out-of-band partial render on every partial request.
-func (p *Partial) SetAlwaysSwapOOB(alwaysSwapOOB bool) *Partial {
+// lastModifiedAt returns the last-modified time of p for r, truncated to the
+// second precision of HTTP dates.
+func (p *Partial) lastModifiedAt(r *http.Request) (time.Time, bool) {
+	if p == nil || r == nil || r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return time.Time{}, false
+	}
+	p.mu.RLock()
+	fn := p.lastModified
+	p.mu.RUnlock()
+	if fn == nil {
+		return time.Time{}, false
+	}
+	dot, _ := p.getDotContract()
+	modified := fn(r, dot)
+	if modified.IsZero() {
+		return time.Time{}, false
+	}
+	return modified.UTC().Truncate(time.Second), true
+}
+
+// RequireHeader makes rendering p as the response of a request fail with a
+// *MissingHeaderError unless the request carries header name. An empty value
+// accepts any value. This guards partial-only endpoints, such as fragments
+// that require HX-Request, against direct URL access. The check applies when p
+// is the rendered root, the requested target, or a region rendered by
+// RenderRegions, RenderChangedRegions, or StreamJSON, not when a parent
+// includes p in a full page. Write answers the error with status 400.
+func (p *Partial) RequireHeader(name, value string) *Partial {
 	if p == nil {
 		return nil
 	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	p.alwaysSwapOOB = alwaysSwapOOB
+	if p.requiredHeaders == nil {
+		p.requiredHeaders = make(map[string]string)
+	}
+	p.requiredHeaders[name] = value
 	return p
 }
 
-// SetFunc registers template functions in the Partial scope.
-func (p *Partial) SetFunc(funcMaps ...template.FuncMap) *Partial {
+func (p *Partial) checkRequiredHeaders(r *http.Request) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	names := slices.Sorted(maps.Keys(p.requiredHeaders))
+	for _, name := range names {
+		value := p.requiredHeaders[name]
+		var got string
+		if r != nil {
+			got = r.Header.Get(name)
+		}
+		if got == "" || (value != "" && got != value) {
+			return &MissingHeaderError{ID: p.id, Name: name, Value: value}
+		}
+	}
+	return nil
+}
+
+// SetContentType configures the Content-Type written by Write. Without a
+// configured value, Write falls back to the parent partial, then to
+// "text/html; charset=utf-8". Response headers from SetResponseHeaders, the
+// connector, or render stages take precedence.
+func (p *Partial) SetContentType(contentType string) *Partial {
 	if p == nil {
 		return nil
 	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	for _, funcMap := range funcMaps {
-		p.setFuncMapLocked(funcMap)
-	}
+	p.contentType = contentType
 	return p
 }
 
-// SetFileSystem sets the file system for the partial.
-func (p *Partial) SetFileSystem(fs fs.FS) *Partial {
+func (p *Partial) getContentType() string {
+	if p == nil {
+		return defaultContentType
+	}
+
+	p.mu.RLock()
+	contentType := p.contentType
+	parent := p.parent
+	p.mu.RUnlock()
+
+	if contentType != "" {
+		return contentType
+	}
+	if parent != nil {
+		return parent.getContentType()
+	}
+	return defaultContentType
+}
+
+// SetMaxOutputBytes limits the size of the HTML a template of this partial
+// tree may produce. Rendering stops with ErrOutputTooLarge as soon as the
+// limit is exceeded. Zero falls back to the parent partial; without any
+// limit, output size is not checked.
+func (p *Partial) SetMaxOutputBytes(limit int64) *Partial {
 	if p == nil {
 		return nil
 	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	p.fs = fs
-	p.fsSet = true
+	p.maxOutputBytes = limit
 	return p
 }
 
-// UseTemplateCache sets the parsed template cache usage flag for the partial.
-func (p *Partial) UseTemplateCache(useCache bool) *Partial {
+func (p *Partial) getMaxOutputBytes() int64 {
+	if p == nil {
+		return 0
+	}
+
+	p.mu.RLock()
+	limit := p.maxOutputBytes
+	parent := p.parent
+	p.mu.RUnlock()
+
+	if limit > 0 {
+		return limit
+	}
+	if parent != nil {
+		return parent.getMaxOutputBytes()
+	}
+	return 0
+}
+
+// SetRecoverPanics makes a panic while rendering this partial or its children,
+// for example in a render stage or action, fail the render with a
+// *PanicError instead of crashing the handler goroutine. The error then goes
+// through the usual error fragment and OnError handling.
+func (p *Partial) SetRecoverPanics(recoverPanics bool) *Partial {
 	if p == nil {
 		return nil
 	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	p.useCache = useCache
+	p.recoverPanics = recoverPanics
 	return p
 }
 
-// With registers a child partial on the partial tree.
-//
-// Registered children are addressable by ID for partial requests. During a
-// full render, go-partial also includes child templates that are referenced by
-// native Go template calls, such as {{ template "row.gohtml" . }}.
-func (p *Partial) With(child *Partial) *Partial {
-	if p == nil || child == nil {
-		return p
+func (p *Partial) getRecoverPanics() bool {
+	for current := p; current != nil; {
+		current.mu.RLock()
+		recoverPanics := current.recoverPanics
+		parent := current.parent
+		current.mu.RUnlock()
+		if recoverPanics {
+			return true
+		}
+		current = parent
+	}
+	return false
+}
+
+// SetFragmentMarkers wraps each fragment of a Write or RenderWithRequest
+// response, the rendered partial and every OOB region, in comment markers such
+// as <!--partial:content--> and <!--/partial:content-->, so custom clients can
+// split a response that carries several fragments. It applies to this partial
+// and its children.
+func (p *Partial) SetFragmentMarkers(enabled bool) *Partial {
+	if p == nil {
+		return nil
 	}
 
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	p.children[child.id] = child
-	p.children[child.id].parent = p
-
+	p.fragmentMarkers = enabled
 	return p
 }
 
-// SetContent registers the primary content child rendered by the content helper.
-func (p *Partial) SetContent(child *Partial) *Partial {
-	if p == nil || child == nil {
-		return p
-	}
+func (p *Partial) getFragmentMarkers() bool {
+	for current := p; current != nil; {
+		current.mu.RLock()
+		enabled := current.fragmentMarkers
+		parent := current.parent
+		current.mu.RUnlock()
+		if enabled {
+			return true
+		}
+		current = parent
+	}
+	return false
+}
+
+// markFragment wraps html in the fragment markers of id when p has fragment
+// markers enabled.
+func (p *Partial) markFragment(id string, html template.HTML) template.HTML {
+	if !p.getFragmentMarkers() {
+		return html
+	}
+	return template.HTML("<!--partial:"+id+"-->") + html + template.HTML("<!--/partial:"+id+"-->")
+}
+
+// OOBPosition selects where OOB regions are placed relative to the main target
+// output of a partial response.
+type OOBPosition string
+
+const (
+	// OOBAfter appends OOB regions after the main target output. It is the default.
+	OOBAfter OOBPosition = "after"
+	// OOBBefore places OOB regions before the main target output, for clients
+	// that process swaps in document order.
+	OOBBefore OOBPosition = "before"
+)
+
+// SetOOBPosition configures where OOB regions are placed relative to the main
+// target output of a partial response. It applies to this partial and its
+// children.
+func (p *Partial) SetOOBPosition(position OOBPosition) *Partial {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.oobPosition = position
+	return p
+}
+
+func (p *Partial) getOOBPosition() OOBPosition {
+	if p == nil {
+		return OOBAfter
+	}
+	p.mu.RLock()
+	position := p.oobPosition
+	parent := p.parent
+	p.mu.RUnlock()
+
+	if position != "" {
+		return position
+	}
+	if parent != nil {
+		return parent.getOOBPosition()
+	}
+	return OOBAfter
+}
+
+// placeOOB combines the main output html with the OOB regions oob in the
+// configured OOB position.
+func (p *Partial) placeOOB(html, oob template.HTML) template.HTML {
+	if p.getOOBPosition() == OOBBefore {
+		return oob + html
+	}
+	return html + oob
+}
+
+// SetValidateSwapTargets checks that the root element of a requested target
+// fragment and of every OOB fragment carries the partial's ID as its id
+// attribute, which connectors need to swap the fragment into place. Fragments
+// that fail the check still render, but emit an EventSwapTargetInvalid
+// warning. The check scans each fragment's root tag, so it is opt-in. It
+// applies to this partial and its children.
+func (p *Partial) SetValidateSwapTargets(enabled bool) *Partial {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.validateSwaps = enabled
+	return p
+}
+
+func (p *Partial) getValidateSwapTargets() bool {
+	for current := p; current != nil; {
+		current.mu.RLock()
+		enabled := current.validateSwaps
+		parent := current.parent
+		current.mu.RUnlock()
+		if enabled {
+			return true
+		}
+		current = parent
+	}
+	return false
+}
+
+// validateSwapTarget emits EventSwapTargetInvalid when swap target validation
+// is enabled and the root element of html is not identified by one of ids.
+func (p *Partial) validateSwapTarget(ctx context.Context, r *http.Request, html template.HTML, ids ...string) {
+	if !p.getValidateSwapTargets() {
+		return
+	}
+	id, ok := rootElementID(html)
+	if ok && slices.Contains(ids, id) {
+		return
+	}
+	p.emitWithContext(ctx, r, Event{
+		Kind:    EventSwapTargetInvalid,
+		Level:   EventWarn,
+		Message: "swap target fragment root element lacks the expected id",
+		Fields:  map[string]any{"target": ids[0], "id": id},
+	})
+}
+
+// SetRenderTimeout bounds a whole render of this partial, including stages,
+// actions, and child templates, by timeout. Stages and actions receive the
+// deadline through their context, and once it passes no further stage or
+// child render starts; a stage or template already running finishes unless it
+// watches the context. Render, RenderWithRequest, and Write then fail with an
+// error wrapping context.DeadlineExceeded, which Write passes to the OnError
+// handler. The timeout applies wherever p renders: as the root, as the
+// requested target, or inside a parent's template, where it fails only p's
+// output. Zero falls back to the parent partial.
+func (p *Partial) SetRenderTimeout(timeout time.Duration) *Partial {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.renderTimeout = timeout
+	return p
+}
+
+func (p *Partial) getRenderTimeout() time.Duration {
+	if p == nil {
+		return 0
+	}
+
+	p.mu.RLock()
+	timeout := p.renderTimeout
+	parent := p.parent
+	p.mu.RUnlock()
+
+	if timeout > 0 {
+		return timeout
+	}
+	if parent != nil {
+		return parent.getRenderTimeout()
+	}
+	return 0
+}
+
+// SetStatus configures the HTTP status written by Write. A zero status clears
+// the local value and falls back to the parent partial, then to net/http's
+// default status.
+func (p *Partial) SetStatus(status int) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.responseStatus = status
+	return p
+}
+
+func (p *Partial) getStatus() int {
+	if p == nil {
+		return 0
+	}
+	p.mu.RLock()
+	status := p.responseStatus
+	parent := p.parent
+	p.mu.RUnlock()
+	if status > 0 {
+		return status
+	}
+	if parent != nil {
+		return parent.getStatus()
+	}
+	return 0
+}
+
+// Response returns a builder for connector-specific response instructions.
+func (p *Partial) Response() *connector.ResponseBuilder {
+	if p == nil {
+		return connector.NewResponseBuilder(nil)
+	}
+	return connector.NewResponseBuilder(&p.response)
+}
+
+// SetResponse configures connector-specific response instructions.
+func (p *Partial) SetResponse(response connector.Response) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.response = response
+	return p
+}
+
+// SetEvents configures the diagnostic event sink inherited by this partial tree.
+func (p *Partial) SetEvents(events EventSink) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.events = events
+	return p
+}
+
+// OnError configures the handler Write calls when rendering fails. Without a
+// handler, Write renders the failure through the error render stages and
+// returns the render error.
+func (p *Partial) OnError(handler ErrorHandler) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.errorHandler = handler
+	return p
+}
+
+func (p *Partial) getErrorHandler() ErrorHandler {
+	if p == nil {
+		return nil
+	}
+	p.mu.RLock()
+	handler := p.errorHandler
+	parent := p.parent
+	p.mu.RUnlock()
+
+	if handler != nil {
+		return handler
+	}
+	if parent != nil {
+		return parent.getErrorHandler()
+	}
+	return nil
+}
+
+// SetNotFound configures the partial rendered with status 404 when a partial
+// request targets an ID that does not exist in the tree.
+func (p *Partial) SetNotFound(notFound *Partial) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.notFound = notFound
+	return p
+}
+
+// UnknownTargetBehavior selects how a partial request for an ID that does not
+// exist in the tree is answered.
+type UnknownTargetBehavior string
+
+const (
+	// UnknownTargetError returns a TargetNotFoundError.
+	UnknownTargetError UnknownTargetBehavior = "error"
+	// UnknownTargetFullPage renders the full page instead of the missing target.
+	UnknownTargetFullPage UnknownTargetBehavior = "full-page"
+	// UnknownTargetNotFound renders the partial configured with SetNotFound,
+	// falling back to an error when none is configured.
+	UnknownTargetNotFound UnknownTargetBehavior = "not-found"
+)
+
+// SetUnknownTargetBehavior configures how requests for unknown partial IDs are
+// answered. Without a configured behavior, the not-found partial is rendered
+// when one is set and an error is returned otherwise.
+func (p *Partial) SetUnknownTargetBehavior(behavior UnknownTargetBehavior) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.unknownTarget = behavior
+	return p
+}
+
+// SetDefaultTarget sets the partial ID rendered when a request names no
+// target, instead of rendering the full page. Plain requests get the target
+// without out-of-band regions; history restore requests, such as htmx sends
+// with HX-History-Restore-Request, still get the full page.
+func (p *Partial) SetDefaultTarget(id string) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.defaultTarget = id
+	return p
+}
+
+func (p *Partial) getNotFound() *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.RLock()
+	notFound := p.notFound
+	parent := p.parent
+	p.mu.RUnlock()
+
+	if notFound != nil {
+		return notFound
+	}
+	if parent != nil {
+		return parent.getNotFound()
+	}
+	return nil
+}
+
+func (p *Partial) getUnknownTargetBehavior() UnknownTargetBehavior {
+	if p == nil {
+		return ""
+	}
+	p.mu.RLock()
+	behavior := p.unknownTarget
+	parent := p.parent
+	p.mu.RUnlock()
+
+	if behavior != "" {
+		return behavior
+	}
+	if parent != nil {
+		return parent.getUnknownTargetBehavior()
+	}
+	return ""
+}
+
+// GetBasePath returns the configured base path, falling back to parents.
+func (p *Partial) GetBasePath() string {
+	if p == nil {
+		return ""
+	}
+
+	p.mu.RLock()
+	basePath := p.basePath
+	parent := p.parent
+	p.mu.RUnlock()
+
+	if basePath != "" {
+		return basePath
+	}
+
+	if parent != nil {
+		return parent.GetBasePath()
+	}
+
+	return ""
+}
+
+// SetConnector configures the request connector used by this partial.
+func (p *Partial) SetConnector(c connector.Connector) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.connector = c
+	return p
+}
+
+// SetConnectors configures connectors that are detected per request, such as
+// htmx and Turbo serving the same routes. Write and RenderWithRequest render
+// with the first connector that recognizes the request, which then reads the
+// target, selection, and action and frames the response. Requests no
+// connector recognizes use the connector configured with SetConnector.
+// Detection clones the tree for requests that switch connectors.
+func (p *Partial) SetConnectors(connectors ...connector.Connector) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.connectors = slices.Clone(connectors)
+	return p
+}
+
+// SetAlwaysSwapOOB makes this out-of-band partial render on every partial request.
+func (p *Partial) SetAlwaysSwapOOB(alwaysSwapOOB bool) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.alwaysSwapOOB = alwaysSwapOOB
+	return p
+}
+
+// SetMemoize makes repeated references to this partial within one render
+// pass reuse the first output instead of rendering again, for example a nav
+// rendered in both the header and the footer. Outputs are keyed by partial ID;
+// use SetMemoizeKey when references with different dots must render
+// separately. Render stages such as actions run only for the first reference.
+func (p *Partial) SetMemoize(memoize bool) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.memoize = memoize
+	return p
+}
+
+// SetMemoizeKey memoizes this partial like SetMemoize, keying outputs by
+// partial ID and the key returned for the dot, so references whose keys
+// differ render separately. A nil key keys outputs by partial ID only.
+func (p *Partial) SetMemoizeKey(key MemoKeyFunc) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.memoize = true
+	p.memoKey = key
+	return p
+}
+
+// SetFunc registers template functions in the Partial scope. Functions named
+// after a core helper, or starting with an underscore, are dropped, and every
+// render of the partial emits an EventFuncProtected event for each of them.
+func (p *Partial) SetFunc(funcMaps ...template.FuncMap) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, funcMap := range funcMaps {
+		p.setFuncMapLocked(funcMap)
+	}
+	return p
+}
+
+// SetFuncNamespace registers template functions as prefix_name so modules
+// can contribute helpers with the same name without colliding.
+func (p *Partial) SetFuncNamespace(prefix string, funcMaps ...template.FuncMap) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, funcMap := range funcMaps {
+		namespaced := make(template.FuncMap, len(funcMap))
+		for name, fn := range funcMap {
+			namespaced[prefix+"_"+name] = fn
+		}
+		p.setFuncMapLocked(namespaced)
+	}
+	return p
+}
+
+// SetFuncAliases makes template functions available under additional names,
+// mapping each alias to the name of an existing function. Aliases can point at
+// core helpers such as oobAttr, which eases moving templates written for other
+// libraries. Aliases are inherited by children; core helper names and names
+// starting with an underscore cannot be used as aliases and are reported like
+// those dropped by SetFunc.
+func (p *Partial) SetFuncAliases(aliases map[string]string) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// The alias map is replaced rather than modified, because clones share it
+	// and cached function maps are keyed by its identity.
+	funcAliases := make(map[string]string, len(p.funcAliases)+len(aliases))
+	maps.Copy(funcAliases, p.funcAliases)
+	for alias, name := range aliases {
+		if isProtectedFunctionName(alias) {
+			p.addProtectedFuncLocked(alias)
+			continue
+		}
+		funcAliases[alias] = name
+	}
+	p.funcAliases = funcAliases
+	return p
+}
+
+// SetFuncAllowlist restricts the template functions of this partial and its
+// children to the listed names, for rendering user-provided templates. Any
+// other function registered with SetFunc, SetFuncNamespace, or
+// SetFuncAliases, on this partial or an ancestor, or installed by a render
+// stage, is undefined and fails the template parse. The same applies to the
+// core helpers that expose the request or the render, such as request, url,
+// query, ctx, and runtime. Only the tree helpers content, render, partial,
+// partialExists, include, oob, oobAttr, id, basePath, joinPath, and urlPath
+// stay available unlisted. A child can set its own allowlist, which replaces
+// the inherited one.
+func (p *Partial) SetFuncAllowlist(names ...string) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.funcAllowlist = make(map[string]struct{}, len(names))
+	for _, name := range names {
+		p.funcAllowlist[name] = struct{}{}
+	}
+	return p
+}
+
+// SetFileSystem sets the file system for the partial.
+func (p *Partial) SetFileSystem(fs fs.FS) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.fs = fs
+	p.fsSet = true
+	return p
+}
+
+// SetOverlayFS layers file systems over the partial's file system. Template
+// lookups check the overlays in order and fall back to the base file system,
+// so a theme can override individual templates. Children inherit the overlays.
+// Each call starts a separate template cache scope, so configure overlays once
+// on a long-lived partial and clone it per request.
+func (p *Partial) SetOverlayFS(overlays ...fs.FS) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.overlays = slices.DeleteFunc(slices.Clone(overlays), func(fsys fs.FS) bool {
+		return fsys == nil
+	})
+	p.overlayID = overlayIDs.Add(1)
+	return p
+}
+
+// SetBaseDir resolves template paths relative to dir within the file system.
+// Children inherit the base dir unless they configure their own.
+func (p *Partial) SetBaseDir(dir string) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.baseDir = strings.Trim(strings.ReplaceAll(dir, `\`, `/`), "/")
+	return p
+}
+
+// SetTemplateExtension appends ext, such as ".gohtml", to template names that
+// have no extension, so New("index") loads index.gohtml. Names that already
+// carry an extension are used as they are. Children inherit the extension
+// unless they configure their own.
+func (p *Partial) SetTemplateExtension(ext string) *Partial {
+	if p == nil {
+		return nil
+	}
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.templateExt = ext
+	return p
+}
+
+// SetDelims sets the template action delimiters, for example "[[" and "]]"
+// when templates also contain markup for a client-side framework using "{{".
+// Children inherit the delimiters unless they configure their own.
+func (p *Partial) SetDelims(left, right string) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.delims = templateutil.Delims{Left: left, Right: right}
+	return p
+}
+
+// UseTemplateCache sets the parsed template cache usage flag for the partial.
+// The flag is not inherited by children, and a partial cloned from a caching
+// prototype keeps caching until it calls UseTemplateCache(false).
+func (p *Partial) UseTemplateCache(useCache bool) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.useCache = useCache
+	return p
+}
+
+// With registers a child partial on the partial tree.
+//
+// Registered children are addressable by ID for partial requests. During a
+// full render, go-partial also includes child templates that are referenced by
+// native Go template calls, such as {{ template "row.gohtml" . }}.
+func (p *Partial) With(child *Partial) *Partial {
+	if p == nil || child == nil {
+		return p
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.children[child.id] = child
+	p.children[child.id].parent = p
+
+	return p
+}
+
+// Children returns a snapshot of the partial's direct children, sorted by ID.
+func (p *Partial) Children() []*Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.RLock()
+	children := make([]*Partial, 0, len(p.children))
+	for _, child := range p.children {
+		children = append(children, child)
+	}
+	p.mu.RUnlock()
+
+	slices.SortFunc(children, func(a, b *Partial) int {
+		return strings.Compare(a.id, b.id)
+	})
+	return children
+}
+
+// SetContent registers the primary content child rendered by the content helper.
+func (p *Partial) SetContent(child *Partial) *Partial {
+	if p == nil || child == nil {
+		return p
+	}
 	p.With(child)
 	p.mu.Lock()
 	p.contentID = child.id
@@ -518,6 +1590,42 @@ func (p *Partial) SetContent(child *Partial) *Partial {
 	return p
 }
 
+// WithContent registers children on the primary content child set with
+// SetContent, so a page can be assembled fluently from the shell. It does
+// nothing when no content child is configured.
+func (p *Partial) WithContent(children ...*Partial) *Partial {
+	if p == nil {
+		return nil
+	}
+	p.mu.RLock()
+	content := p.children[p.contentID]
+	p.mu.RUnlock()
+	if content == nil {
+		return p
+	}
+	for _, child := range children {
+		content.With(child)
+	}
+	return p
+}
+
+// WithComponent registers the partial built by c as a child, like With.
+func (p *Partial) WithComponent(c Component) *Partial {
+	if c == nil {
+		return p
+	}
+	return p.With(c.Partial())
+}
+
+// SetComponent registers the partial built by c as the primary content child,
+// like SetContent.
+func (p *Partial) SetComponent(c Component) *Partial {
+	if c == nil {
+		return p
+	}
+	return p.SetContent(c.Partial())
+}
+
 // WithTemplate creates a child partial from a template path and registers it
 // on the partial tree. The child ID is inferred from the file name without its
 // extension: "templates/sidebar.gohtml" becomes "sidebar".
@@ -552,49 +1660,189 @@ func (p *Partial) WithOOB(child *Partial) *Partial {
 	return p
 }
 
-func (p *Partial) getConnectorResponseHeaders() map[string]string {
-	if p == nil {
-		return nil
+func (p *Partial) getConnectorResponseHeaders() map[string]string {
+	if p == nil {
+		return nil
+	}
+
+	conn := p.getConnector()
+	if conn == nil {
+		return nil
+	}
+
+	return conn.ResponseHeaders(p.response)
+}
+
+func applyRenderResponseHeaders(w http.ResponseWriter, response *RenderResponse) {
+	if w == nil || response == nil {
+		return
+	}
+	for key, value := range response.Headers {
+		w.Header().Set(key, value)
+	}
+}
+
+func (p *Partial) isPartialRequest(r *http.Request) bool {
+	if p == nil || r == nil {
+		return false
+	}
+
+	conn := p.getConnector()
+	return conn != nil && conn.RenderPartial(r)
+}
+
+// funcSources identifies the function configuration of one partial. Its maps
+// are replaced rather than modified when the configuration changes, so a
+// cached function map stays valid while every partial on the chain holds the
+// same maps. Holding them also keeps their addresses from being reused.
+type funcSources struct {
+	static    template.FuncMap
+	aliases   map[string]string
+	allowlist map[string]struct{}
+}
+
+func (s funcSources) same(other funcSources) bool {
+	return sameMap(s.static, other.static) && sameMap(s.aliases, other.aliases) && sameMap(s.allowlist, other.allowlist)
+}
+
+func sameMap(a, b any) bool {
+	return reflect.ValueOf(a).UnsafePointer() == reflect.ValueOf(b).UnsafePointer()
+}
+
+func (p *Partial) funcSourcesLocked() funcSources {
+	return funcSources{static: p.staticFuncs, aliases: p.funcAliases, allowlist: p.funcAllowlist}
+}
+
+// funcMapCache holds the static function map of a partial merged with its
+// ancestors, together with its signature. sources lists the function
+// configuration of the partial and each ancestor it was built from. funcs must
+// not be modified.
+type funcMapCache struct {
+	sources   []funcSources
+	funcs     template.FuncMap
+	signature string
+}
+
+// getStaticFuncMap returns the combined function map of the partial.
+func (p *Partial) getStaticFuncMap() template.FuncMap {
+	return maps.Clone(p.cachedFuncMap().funcs)
+}
+
+// cachedFuncMap returns the merged static functions of the partial, rebuilding
+// them only when the functions of the partial or an ancestor changed. Clones
+// share the cache until their functions change.
+func (p *Partial) cachedFuncMap() *funcMapCache {
+	if cached := p.funcCache.Load(); cached != nil && p.funcCacheValid(cached) {
+		return cached
+	}
+
+	p.mu.RLock()
+	parent := p.parent
+	sources := []funcSources{p.funcSourcesLocked()}
+	funcs := maps.Clone(p.staticFuncs)
+	signature := templateFuncSignature(p.staticFuncs)
+	p.mu.RUnlock()
+
+	if parent != nil {
+		inherited := parent.cachedFuncMap()
+		merged := maps.Clone(inherited.funcs)
+		maps.Copy(merged, funcs)
+		funcs = merged
+		signature = templateutil.MergeFunctionSignatures(inherited.signature, signature)
+		sources = append(sources, inherited.sources...)
+	}
+
+	if aliases := p.getFuncAliases(); len(aliases) > 0 {
+		applyFuncAliases(funcs, templateutil.MergeFuncMaps(placeholderRequestFuncMap(), funcs), aliases)
+		signature = templateutil.MergeFunctionSignatures(signature, funcAliasSignature(aliases))
 	}
 
-	conn := p.getConnector()
-	if conn == nil {
-		return nil
+	if allowlist := p.getFuncAllowlist(); allowlist != nil {
+		maps.DeleteFunc(funcs, func(name string, _ any) bool {
+			_, ok := allowlist[name]
+			return !ok
+		})
+		// The allowlist also decides which core helpers a template may use.
+		signature = templateutil.MergeFunctionSignatures(templateutil.FunctionNameSignature(funcs), templateutil.FunctionNameSignatureFromSet(allowlist))
 	}
 
-	return conn.ResponseHeaders(p.response)
+	cached := &funcMapCache{
+		sources:   sources,
+		funcs:     funcs,
+		signature: signature,
+	}
+	p.funcCache.Store(cached)
+	return cached
 }
 
-func applyRenderResponseHeaders(w http.ResponseWriter, response *RenderResponse) {
-	if w == nil || response == nil {
-		return
-	}
-	for key, value := range response.Headers {
-		w.Header().Set(key, value)
+// funcCacheValid reports whether p and its ancestors still hold the function
+// configuration cached was built from.
+func (p *Partial) funcCacheValid(cached *funcMapCache) bool {
+	current := p
+	for _, sources := range cached.sources {
+		if current == nil {
+			return false
+		}
+		current.mu.RLock()
+		own := current.funcSourcesLocked()
+		parent := current.parent
+		current.mu.RUnlock()
+		if !own.same(sources) {
+			return false
+		}
+		current = parent
 	}
+	return current == nil
 }
 
-func (p *Partial) isPartialRequest(r *http.Request) bool {
-	if p == nil || r == nil {
-		return false
+// getFuncAllowlist returns the nearest function allowlist of the partial or
+// its ancestors, or nil when functions are not restricted.
+func (p *Partial) getFuncAllowlist() map[string]struct{} {
+	for current := p; current != nil; {
+		current.mu.RLock()
+		allowlist := current.funcAllowlist
+		parent := current.parent
+		current.mu.RUnlock()
+		if allowlist != nil {
+			return allowlist
+		}
+		current = parent
 	}
-
-	conn := p.getConnector()
-	return conn != nil && conn.RenderPartial(r)
+	return nil
 }
 
-// getStaticFuncMap returns the combined function map of the partial.
-func (p *Partial) getStaticFuncMap() template.FuncMap {
+// getFuncAliases returns the function aliases of the partial merged with
+// those of its ancestors.
+func (p *Partial) getFuncAliases() map[string]string {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	if p.parent != nil {
-		funcs := maps.Clone(p.parent.getStaticFuncMap())
-		maps.Copy(funcs, p.staticFuncs)
-		return funcs
+	if p.parent == nil {
+		return maps.Clone(p.funcAliases)
+	}
+	aliases := p.parent.getFuncAliases()
+	if aliases == nil {
+		return maps.Clone(p.funcAliases)
+	}
+	maps.Copy(aliases, p.funcAliases)
+	return aliases
+}
+
+// applyFuncAliases adds each alias to funcs when its target is found in lookup.
+func applyFuncAliases(funcs, lookup template.FuncMap, aliases map[string]string) {
+	for alias, name := range aliases {
+		if fn, ok := lookup[name]; ok {
+			funcs[alias] = fn
+		}
 	}
+}
 
-	return maps.Clone(p.staticFuncs)
+func funcAliasSignature(aliases map[string]string) string {
+	names := make([]string, 0, len(aliases))
+	for alias, name := range aliases {
+		names = append(names, alias+"="+name)
+	}
+	return templateutil.FunctionNameSignatureFromNames(names)
 }
 
 func (p *Partial) getCustomFuncMap() template.FuncMap {
@@ -623,8 +1871,14 @@ func (p *Partial) contractFuncMapLocked() template.FuncMap {
 }
 
 func (p *Partial) setFuncMapLocked(funcMap template.FuncMap) {
+	// The function map is replaced rather than modified, because clones share
+	// it and cached function maps are keyed by its identity.
+	staticFuncs := make(template.FuncMap, len(p.staticFuncs)+len(funcMap))
+	maps.Copy(staticFuncs, p.staticFuncs)
+	p.staticFuncs = staticFuncs
 	for name, fn := range funcMap {
 		if isProtectedFunctionName(name) {
+			p.addProtectedFuncLocked(name)
 			continue
 		}
 
@@ -639,6 +1893,14 @@ func (p *Partial) setFuncMapLocked(funcMap template.FuncMap) {
 	}
 }
 
+// addProtectedFuncLocked records a user function dropped because its name is
+// reserved, so renders can report it with a func.protected event.
+func (p *Partial) addProtectedFuncLocked(name string) {
+	if !slices.Contains(p.protectedFuncs, name) {
+		p.protectedFuncs = append(p.protectedFuncs, name)
+	}
+}
+
 func (p *Partial) upsertContractLocked(contract contractInformation, match func(contractInformation) bool) {
 	for i, existing := range p.contracts {
 		if match(existing) {
@@ -664,14 +1926,7 @@ func (p *Partial) getDotContract() (any, bool) {
 }
 
 func (p *Partial) getFunctionSignature() string {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-
-	signature := templateFuncSignature(p.staticFuncs)
-	if p.parent != nil {
-		signature = templateutil.MergeFunctionSignatures(p.parent.getFunctionSignature(), signature)
-	}
-	return signature
+	return p.cachedFuncMap().signature
 }
 
 func (p *Partial) getHasCustomFunctions() bool {
@@ -714,6 +1969,12 @@ func (p *Partial) addRequestFuncs(funcs template.FuncMap, state *RenderContext)
 	}
 	// go-doc:sig func() html/template.HTML
 	funcs["content"] = contentFunc(p, state)
+	// go-doc:sig func(id string) html/template.HTML
+	funcs["render"] = renderFunc(p, state)
+	// go-doc:sig func(id string) bool
+	funcs["partialExists"] = partialExistsFunc(p)
+	// go-doc:sig func(name string, data ...any) (html/template.HTML, error)
+	funcs["include"] = includeFunc(p, state)
 	renderCtx := func() *RenderContext {
 		return state
 	}
@@ -736,8 +1997,29 @@ func (p *Partial) addRequestFuncs(funcs template.FuncMap, state *RenderContext)
 		return state.BasePath
 	}
 
+	// go-doc:sig func() string
+	funcs["id"] = p.PartialID
+
 	p.addNavigationFuncs(funcs, state)
 	maps.Copy(funcs, state.Funcs)
+	if aliases := p.getFuncAliases(); len(aliases) > 0 {
+		applyFuncAliases(funcs, funcs, aliases)
+	}
+	if allowlist := p.getFuncAllowlist(); allowlist != nil {
+		maps.DeleteFunc(funcs, func(name string, _ any) bool {
+			return !funcAllowed(allowlist, name)
+		})
+	}
+}
+
+// funcAllowed reports whether a function allowlist admits name. Tree helpers
+// are always admitted.
+func funcAllowed(allowlist map[string]struct{}, name string) bool {
+	if _, ok := allowlist[name]; ok {
+		return true
+	}
+	_, ok := treeFunctionNames[name]
+	return ok
 }
 
 func (p *Partial) addNavigationFuncs(funcs template.FuncMap, state *RenderContext) {
@@ -766,6 +2048,22 @@ func (p *Partial) addNavigationFuncs(funcs template.FuncMap, state *RenderContex
 		return strings.Contains(state.URL.Path, current)
 	}
 
+	// go-doc:sig func() net/url.Values
+	funcs["query"] = func() url.Values {
+		if state.URL == nil {
+			return url.Values{}
+		}
+		return state.URL.Query()
+	}
+
+	// go-doc:sig func(key string) string
+	funcs["queryGet"] = func(key string) string {
+		if state.URL == nil {
+			return ""
+		}
+		return state.URL.Query().Get(key)
+	}
+
 	// go-doc:sig func(parts ...string) string
 	funcs["joinPath"] = func(parts ...string) string {
 		return path.Join(parts...)
@@ -797,21 +2095,47 @@ func (p *Partial) addNavigationFuncs(funcs template.FuncMap, state *RenderContex
 
 func placeholderRequestFuncMap() template.FuncMap {
 	return template.FuncMap{
-		"runtime":     func() *Runtime { return nil },
-		"partial":     func(*Runtime, string, ...any) template.HTML { return "" },
-		"content":     func() template.HTML { return "" },
-		"ctx":         func() *RenderContext { return nil },
-		"request":     func() *http.Request { return nil },
-		"url":         func() *url.URL { return nil },
-		"basePath":    func() string { return "" },
-		"urlIs":       func(string) bool { return false },
-		"urlStarts":   func(string) bool { return false },
-		"urlContains": func(string) bool { return false },
-		"joinPath":    func(...string) string { return "" },
-		"urlPath":     func(string, ...string) template.URL { return "" },
-		"oob":         func() bool { return false },
-		"oobAttr":     func(...string) template.HTMLAttr { return "" },
-	}
+		"runtime":       func() *Runtime { return nil },
+		"partial":       func(*Runtime, string, ...any) template.HTML { return "" },
+		"content":       func() template.HTML { return "" },
+		"render":        func(string) template.HTML { return "" },
+		"partialExists": func(string) bool { return false },
+		"include":       func(string, ...any) (template.HTML, error) { return "", nil },
+		"ctx":           func() *RenderContext { return nil },
+		"request":       func() *http.Request { return nil },
+		"url":           func() *url.URL { return nil },
+		"basePath":      func() string { return "" },
+		"id":            func() string { return "" },
+		"urlIs":         func(string) bool { return false },
+		"urlStarts":     func(string) bool { return false },
+		"urlContains":   func(string) bool { return false },
+		"query":         func() url.Values { return nil },
+		"queryGet":      func(string) string { return "" },
+		"joinPath":      func(...string) string { return "" },
+		"urlPath":       func(string, ...string) template.URL { return "" },
+		"oob":           func() bool { return false },
+		"oobAttr":       func(...string) template.HTMLAttr { return "" },
+	}
+}
+
+// placeholderFuncMap returns parse-time stand-ins for funcs, so a cached
+// template does not keep the request-scoped functions of its first render.
+// Executions replace them with the functions of their own render.
+func placeholderFuncMap(funcs template.FuncMap) template.FuncMap {
+	placeholders := make(template.FuncMap, len(funcs))
+	for name := range funcs {
+		placeholders[name] = func(...any) any { return nil }
+	}
+	return placeholders
+}
+
+func emitProtectedFunc(state *RenderContext, p *Partial, name string) {
+	state.EmitForPartial(p, Event{
+		Kind:    EventFuncProtected,
+		Level:   EventWarn,
+		Message: "user function ignored because its name is reserved",
+		Fields:  map[string]any{"func": name},
+	})
 }
 
 func isProtectedFunctionName(name string) bool {
@@ -838,6 +2162,54 @@ func (p *Partial) getConnector() connector.Connector {
 	return nil
 }
 
+func (p *Partial) getConnectors() []connector.Connector {
+	if p == nil {
+		return nil
+	}
+	p.mu.RLock()
+	connectors := p.connectors
+	parent := p.parent
+	p.mu.RUnlock()
+
+	if connectors != nil {
+		return connectors
+	}
+	if parent != nil {
+		return parent.getConnectors()
+	}
+	return nil
+}
+
+// varyHeaders returns the request headers that decide how p renders a
+// response, collected from its connector and those set with SetConnectors.
+func (p *Partial) varyHeaders() []string {
+	var headers []string
+	for _, conn := range append([]connector.Connector{p.getConnectorOrDefault()}, p.getConnectors()...) {
+		for _, name := range connector.VaryHeaders(conn) {
+			name = http.CanonicalHeaderKey(name)
+			if name != "" && !slices.Contains(headers, name) {
+				headers = append(headers, name)
+			}
+		}
+	}
+	slices.Sort(headers)
+	return headers
+}
+
+// withDetectedConnector returns p, or a clone of p using the connector
+// configured with SetConnectors that recognizes r.
+func (p *Partial) withDetectedConnector(r *http.Request) *Partial {
+	connectors := p.getConnectors()
+	if len(connectors) == 0 {
+		return p
+	}
+	detected := connector.Detect(r, connectors...)
+	if detected == nil || detected == p.getConnector() {
+		return p
+	}
+	return p.clone().SetConnector(detected)
+}
+
 func (p *Partial) getConnectorOrDefault() connector.Connector {
 	if conn := p.getConnector(); conn != nil {
 		return conn
@@ -863,7 +2235,161 @@ func (p *Partial) getEvents() EventSink {
 	return nil
 }
 
+func (p *Partial) getDelims() templateutil.Delims {
+	if p == nil {
+		return templateutil.Delims{}
+	}
+	p.mu.RLock()
+	delims := p.delims
+	parent := p.parent
+	p.mu.RUnlock()
+
+	if delims != (templateutil.Delims{}) {
+		return delims
+	}
+	if parent != nil {
+		return parent.getDelims()
+	}
+	return templateutil.Delims{}
+}
+
+func (p *Partial) getBaseDir() string {
+	if p == nil {
+		return ""
+	}
+	p.mu.RLock()
+	baseDir := p.baseDir
+	parent := p.parent
+	p.mu.RUnlock()
+
+	if baseDir != "" {
+		return baseDir
+	}
+	if parent != nil {
+		return parent.getBaseDir()
+	}
+	return ""
+}
+
+func (p *Partial) getTemplateExtension() string {
+	if p == nil {
+		return ""
+	}
+	p.mu.RLock()
+	ext := p.templateExt
+	parent := p.parent
+	p.mu.RUnlock()
+
+	if ext != "" {
+		return ext
+	}
+	if parent != nil {
+		return parent.getTemplateExtension()
+	}
+	return ""
+}
+
+// resolveTemplatePath maps a configured template name to its path in the file system.
+func (p *Partial) resolveTemplatePath(name string) string {
+	if ext := p.getTemplateExtension(); ext != "" && path.Ext(name) == "" {
+		name += ext
+	}
+	baseDir := p.getBaseDir()
+	if baseDir == "" {
+		return name
+	}
+	return path.Join(baseDir, name)
+}
+
+func (p *Partial) resolvedTemplates() []string {
+	p.mu.RLock()
+	templates := slices.Clone(p.templates)
+	hasTemplatesFor := len(p.templatesFor) > 0
+	p.mu.RUnlock()
+
+	if hasTemplatesFor {
+		name := connector.NameOf(p.getConnectorOrDefault())
+		p.mu.RLock()
+		if selected, ok := p.templatesFor[name]; ok {
+			templates = slices.Clone(selected)
+		}
+		p.mu.RUnlock()
+	}
+
+	resolved := make([]string, 0, len(templates))
+	for _, name := range templates {
+		resolved = append(resolved, p.expandTemplatePattern(p.resolveTemplatePath(name))...)
+	}
+	return resolved
+}
+
+// expandTemplatePattern expands a glob pattern to the files it matches, in
+// lexical order. Names without glob syntax, and patterns that match nothing,
+// are returned unchanged so parsing reports them.
+func (p *Partial) expandTemplatePattern(name string) []string {
+	if !strings.ContainsAny(name, `*?[\`) {
+		return []string{name}
+	}
+	matches, err := fs.Glob(p.getFS(), name)
+	if err != nil || len(matches) == 0 {
+		return []string{name}
+	}
+	return matches
+}
+
+// ResolvedTemplates returns the template files p parses for a render, without
+// rendering: its own templates and those of the children its templates
+// reference, after the base directory, template extension, overlays and glob
+// patterns are applied. Files are looked up in fsys, or in p's file system when
+// fsys is nil, and an error is returned for the first one that does not exist.
+// Templates chosen per render by a TemplateResolver are not included.
+func (p *Partial) ResolvedTemplates(fsys fs.FS) ([]string, error) {
+	if p == nil {
+		return nil, ErrPartialNotInitialized
+	}
+	if fsys != nil {
+		p = p.clone().SetFileSystem(fsys)
+	}
+
+	files := p.templateTree(p.resolvedTemplates())
+	source := p.getFS()
+	for _, name := range files {
+		if _, err := fs.Stat(source, name); err != nil {
+			return nil, fmt.Errorf("error resolving template '%s': %w", name, err)
+		}
+	}
+	return files, nil
+}
+
 func (p *Partial) getFS() fs.FS {
+	base := p.getBaseFS()
+	overlays, _ := p.getOverlays()
+	if len(overlays) == 0 {
+		return base
+	}
+	return &overlayFS{layers: append(slices.Clone(overlays), base)}
+}
+
+func (p *Partial) getOverlays() ([]fs.FS, uint64) {
+	if p == nil {
+		return nil, 0
+	}
+	p.mu.RLock()
+	overlays := p.overlays
+	overlayID := p.overlayID
+	parent := p.parent
+	p.mu.RUnlock()
+
+	if overlayID != 0 {
+		return overlays, overlayID
+	}
+	if parent != nil {
+		return parent.getOverlays()
+	}
+	return nil, 0
+}
+
+func (p *Partial) getBaseFS() fs.FS {
 	if p == nil {
 		return os.DirFS("./")
 	}
@@ -877,7 +2403,7 @@ func (p *Partial) getFS() fs.FS {
 		return fsys
 	}
 	if parent != nil {
-		if parentFS := parent.getFS(); parentFS != nil {
+		if parentFS := parent.getBaseFS(); parentFS != nil {
 			return parentFS
 		}
 	}
@@ -914,13 +2440,42 @@ func (p *Partial) getRenderStages() []RenderStage {
 	return stages
 }
 
-func renderWithTargetResult(ctx context.Context, r *http.Request, p *Partial) renderResult {
-	requestedTarget := p.getConnectorOrDefault().GetTargetValue(r)
-	if requestedTarget == "" || requestedTarget == p.id {
-		result := renderSelfResult(ctx, r, p)
+func renderWithTargetResult(ctx context.Context, r *http.Request, p *Partial, requestedTarget string) renderResult {
+	if requestedTarget == "" || requestedTarget == p.id || p.hasAlias(requestedTarget) {
+		if err := p.checkRequiredHeaders(r); err != nil {
+			return renderResult{Err: err}
+		}
+		result := renderSelfResult(withRequestTarget(ctx, p), r, p)
 		if result.Err != nil {
 			return result
 		}
+		if requestedTarget != "" {
+			p.validateSwapTarget(ctx, r, result.HTML, p.id, requestedTarget)
+		}
+		result.HTML = p.markFragment(p.id, result.HTML)
+		if rendered := result.Partial; rendered != nil && rendered.getOOBOnly() {
+			result.HTML = ""
+		}
+		if streamFrom(ctx).start(result) {
+			result.HTML = ""
+		}
+
+		// A stage such as an action may have replaced the partial; its own OOB
+		// children belong to this response as well.
+		if replaced := result.Partial; replaced != nil && replaced != p {
+			oobOut, oobErr := renderOOBChildren(ctx, r, replaced, true, true)
+			if oobErr != nil {
+				p.emitWithContext(ctx, r, Event{
+					Kind:    EventRenderOOBError,
+					Level:   EventError,
+					Message: "error rendering OOB regions from replaced partial",
+					Error:   oobErr,
+				})
+				result.Err = fmt.Errorf("error rendering OOB regions from replaced partial: %w", oobErr)
+				return result
+			}
+			result.HTML = p.placeOOB(result.HTML, oobOut)
+		}
 
 		// Render OOB regions from the parent tree when necessary.
 		oobOutAll, oobErr := renderAllAncestorOOBChildren(ctx, r, p, true)
@@ -934,7 +2489,7 @@ func renderWithTargetResult(ctx context.Context, r *http.Request, p *Partial) re
 			result.Err = fmt.Errorf("error rendering OOB regions from ancestors: %w", oobErr)
 			return result
 		}
-		result.HTML += oobOutAll
+		result.HTML = p.placeOOB(result.HTML, oobOutAll)
 		return result
 	} else {
 		c := p.recursiveChildLookup(requestedTarget, make(map[string]bool))
@@ -944,6 +2499,10 @@ func renderWithTargetResult(ctx context.Context, r *http.Request, p *Partial) re
 				return result
 			}
 			if ok {
+				result.Empty = isBlankHTML(result.HTML)
+				if streamFrom(ctx).start(result) {
+					result.HTML = ""
+				}
 				oobOutAll, oobErr := renderAllAncestorOOBChildren(ctx, r, p, true)
 				if oobErr != nil {
 					p.emitWithContext(ctx, r, Event{
@@ -955,7 +2514,7 @@ func renderWithTargetResult(ctx context.Context, r *http.Request, p *Partial) re
 					result.Err = fmt.Errorf("error rendering OOB regions from ancestors: %w", oobErr)
 					return result
 				}
-				result.HTML += oobOutAll
+				result.HTML = p.placeOOB(result.HTML, oobOutAll)
 				return result
 			}
 
@@ -965,10 +2524,33 @@ func renderWithTargetResult(ctx context.Context, r *http.Request, p *Partial) re
 				Message: "requested partial not found in parent",
 				Fields:  map[string]any{"target": requestedTarget, "parent": p.id},
 			})
-			return renderResult{Err: fmt.Errorf("requested partial %s not found in parent %s", requestedTarget, p.id)}
+			switch p.getUnknownTargetBehavior() {
+			case UnknownTargetError:
+			case UnknownTargetFullPage:
+				return renderSelfResult(ctx, r, p)
+			default:
+				if notFound := p.getNotFound(); notFound != nil {
+					return renderNotFoundResult(ctx, r, p, notFound)
+				}
+			}
+			return renderResult{Err: &TargetNotFoundError{ID: requestedTarget, Parent: p.id}}
 		}
-		return renderWithTargetResult(ctx, r, c)
+		return renderWithTargetResult(ctx, r, c, c.PartialID())
+	}
+}
+
+func renderNotFoundResult(ctx context.Context, r *http.Request, p *Partial, notFound *Partial) renderResult {
+	child := notFound.clone()
+	child.parent = p
+	result := renderSelfResult(ctx, r, child)
+	if result.Err != nil {
+		return result
+	}
+	if result.Response == nil {
+		result.Response = &RenderResponse{Headers: make(map[string]string)}
 	}
+	result.Response.Status = http.StatusNotFound
+	return result
 }
 
 func renderResolvedTargetResult(ctx context.Context, r *http.Request, p *Partial, target string) (renderResult, bool) {
@@ -1004,6 +2586,11 @@ func (p *Partial) recursiveChildLookup(id string, visited map[string]bool) *Part
 	if c, ok := p.children[id]; ok {
 		return c
 	}
+	for _, child := range p.children {
+		if child.hasAlias(id) {
+			return child
+		}
+	}
 
 	for _, child := range p.children {
 		if c := child.recursiveChildLookup(id, visited); c != nil {
@@ -1034,6 +2621,13 @@ func renderChildPartial(ctx context.Context, r *http.Request, p *Partial, id str
 	// Set the parent of the cloned child to the current partial.
 	childClone.parent = p
 
+	memo, memoKey := childClone.renderMemo(ctx)
+	if memo != nil {
+		if html, ok := memo.load(memoKey); ok {
+			return html, nil
+		}
+	}
+
 	result := renderSelfResult(ctx, r, childClone)
 	if result.Err != nil {
 		childClone.emitWithContext(ctx, r, Event{
@@ -1050,6 +2644,9 @@ func renderChildPartial(ctx context.Context, r *http.Request, p *Partial, id str
 		return fallback, nil
 	}
 
+	if memo != nil {
+		memo.store(memoKey, result.HTML)
+	}
 	return result.HTML, nil
 }
 
@@ -1079,14 +2676,37 @@ func renderErrorResult(ctx context.Context, r *http.Request, p *Partial, renderE
 	})
 }
 
-func renderSelfResult(ctx context.Context, r *http.Request, p *Partial) renderResult {
+func renderSelfResult(ctx context.Context, r *http.Request, p *Partial) (result renderResult) {
+	if p.getRecoverPanics() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				result = renderResult{Err: &PanicError{ID: p.PartialID(), Value: recovered, Stack: debug.Stack()}}
+			}
+		}()
+	}
+
+	// A timeout set on p itself bounds p wherever it renders, as the
+	// requested target or inside a parent's template.
+	p.mu.RLock()
+	timeout := p.renderTimeout
+	p.mu.RUnlock()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = withRenderTimeout(ctx, r, timeout)
+		defer cancel()
+		defer func() {
+			result = renderTimeoutResult(ctx, p, timeout, result)
+		}()
+	}
+
 	state := newRenderContext(ctx, p, r, RenderKindPartial)
 
 	stages := append(p.getRenderStages(), templateRenderStage())
-	result := renderWithChainResult(state, stages, func(state *RenderContext) (template.HTML, error) {
+	result = renderWithChainResult(state, stages, func(state *RenderContext) (template.HTML, error) {
 		return "", errors.New("template RenderStage did not produce output")
 	})
 	result.Headers = p.getResponseHeaders()
+	result.Empty = isBlankHTML(result.HTML)
 	return result
 }
 
@@ -1096,7 +2716,7 @@ func renderTemplate(state *RenderContext) (template.HTML, error) {
 		p = state.Partial
 	}
 	if p == nil {
-		return "", errors.New("partial is not initialized")
+		return "", ErrPartialNotInitialized
 	}
 	if state == nil {
 		return "", errors.New("render context is not configured")
@@ -1105,18 +2725,54 @@ func renderTemplate(state *RenderContext) (template.HTML, error) {
 	if state.Runtime == nil || state.Runtime.partial != p {
 		state.Runtime = newRuntime(p, state)
 	}
-	if len(p.templates) == 0 {
+	dot, hasDot := p.getDotContract()
+	p.mu.RLock()
+	transform := p.dotTransform
+	resolver := p.resolver
+	trustedKeys := p.trustedKeys
+	protectedFuncs := p.protectedFuncs
+	p.mu.RUnlock()
+	for _, name := range protectedFuncs {
+		emitProtectedFunc(state, p, name)
+	}
+	if transform != nil {
+		transformed, err := transform(state, dot)
+		if err != nil {
+			return "", fmt.Errorf("error transforming dot for partial '%s': %w", p.id, err)
+		}
+		dot, hasDot = transformed, true
+	}
+
+	templates := p.resolvedTemplates()
+	if resolver != nil {
+		resolved, err := resolver(state, dot)
+		if err != nil {
+			return "", fmt.Errorf("error resolving templates for partial '%s': %w", p.id, err)
+		}
+		templates = make([]string, len(resolved))
+		for i, name := range resolved {
+			templates[i] = p.resolveTemplatePath(name)
+		}
+	}
+	if len(templates) == 0 {
 		state.EmitForPartial(p, Event{
 			Kind:    EventTemplateMissing,
 			Level:   EventError,
 			Message: "no templates provided for rendering",
 		})
-		return "", errors.New("no templates provided for rendering")
+		return "", ErrNoTemplates
 	}
-
-	dot, hasDot := p.getDotContract()
-	renderTemplates := p.templateTree()
-	cacheKey := p.generateCacheKey(renderTemplates, p.getFunctionSignature())
+	if len(trustedKeys) > 0 {
+		dot = trustDotKeys(dot, trustedKeys)
+	}
+	renderTemplates := p.templateTree(templates)
+	signature := p.getFunctionSignature()
+	if len(state.Funcs) > 0 {
+		// Functions installed per render by stages or RenderWithFuncs decide
+		// which names the template may use, so they are part of the key.
+		signature = templateutil.MergeFunctionSignatures(signature, templateutil.FunctionNameSignature(state.Funcs))
+	}
+	cacheKey := p.generateCacheKey(renderTemplates, signature)
 	var funcs template.FuncMap
 	if p.useCache {
 		funcs = p.getRequestFuncMap(state)
@@ -1144,26 +2800,67 @@ func renderTemplate(state *RenderContext) (template.HTML, error) {
 		}
 	}
 
-	var buf bytes.Buffer
+	state.executing = tmpl
+	buf := &limitedBuffer{limit: p.getMaxOutputBytes()}
 	root := any(nil)
 	if hasDot {
 		root = dot
 	}
-	if err = tmpl.Execute(&buf, root); err != nil {
+	if err = tmpl.Execute(buf, root); err != nil {
 		state.EmitForPartial(p, Event{
 			Kind:    EventTemplateExecuteError,
 			Level:   EventError,
 			Message: "error executing template",
 			Error:   err,
-			Fields:  map[string]any{"template": p.templates[0]},
+			Fields:  map[string]any{"template": templates[0]},
 		})
-		return "", fmt.Errorf("error executing template '%s': %w", p.templates[0], err)
+		return "", &TemplateExecuteError{ID: p.id, Template: templates[0], Err: err}
+	}
+
+	return template.HTML(buf.buf.String()), nil
+}
+
+// trustDotKeys returns a copy of a map dot with the string values of keys
+// converted to template.HTML. Other dot values are returned unchanged.
+func trustDotKeys(dot any, keys []string) any {
+	var values map[string]any
+	switch m := dot.(type) {
+	case map[string]any:
+		values = maps.Clone(m)
+	case map[string]string:
+		values = make(map[string]any, len(m))
+		for k, v := range m {
+			values[k] = v
+		}
+	default:
+		return dot
 	}
+	for _, key := range keys {
+		if s, ok := values[key].(string); ok {
+			values[key] = template.HTML(s)
+		}
+	}
+	return values
+}
+
+// limitedBuffer collects template output and fails writes once the output
+// would exceed limit. A zero limit disables the check.
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int64
+}
 
-	return template.HTML(buf.String()), nil
+func (b *limitedBuffer) Write(data []byte) (int, error) {
+	if b.limit > 0 && int64(b.buf.Len()+len(data)) > b.limit {
+		return 0, fmt.Errorf("%w of %d bytes", ErrOutputTooLarge, b.limit)
+	}
+	return b.buf.Write(data)
 }
 
 func renderOOBChildren(ctx context.Context, r *http.Request, p *Partial, renderOOB bool, isAncestor bool) (template.HTML, error) {
+	if oobDisabled(ctx) {
+		return "", nil
+	}
 	var out template.HTML
 
 	children := make(map[string]*Partial)
@@ -1185,7 +2882,19 @@ func renderOOBChildren(ctx context.Context, r *http.Request, p *Partial, renderO
 		if result.Err != nil {
 			return "", fmt.Errorf("error rendering OOB region '%s': %w", id, result.Err)
 		}
-		out += result.HTML
+		if renderOOB {
+			if marker, ok := childClone.getConnectorOrDefault().(connector.OOBMarker); ok {
+				name, value := marker.OOBAttr()
+				result.HTML = injectOOBAttr(result.HTML, name, value)
+			}
+			childClone.validateSwapTarget(ctx, r, result.HTML, id)
+		}
+		fragment := childClone.markFragment(id, result.HTML)
+		if stream := streamFrom(ctx); stream != nil && stream.started {
+			stream.write(fragment)
+			continue
+		}
+		out += fragment
 	}
 
 	return out, nil
@@ -1207,28 +2916,65 @@ func renderAllAncestorOOBChildren(ctx context.Context, r *http.Request, p *Parti
 
 func (p *Partial) getTemplateForRender(cacheKey string, funcs template.FuncMap, applyFullFuncs bool, funcsAreFull bool, renderTemplates []string) (*template.Template, func(), error) {
 	store := p.getTemplateStore()
-	if entry, cached := store.Load(cacheKey); cached && p.useCache {
-		return p.templateFromCacheEntry(entry, funcs, applyFullFuncs, funcsAreFull)
+	if !p.useCache {
+		tmpl, _, err := p.parseTemplateWithRetry(store, cacheKey, funcs, funcsAreFull, renderTemplates)
+		return tmpl, nil, err
 	}
 
-	mu := store.Mutex(cacheKey)
-	mu.Lock()
-	defer mu.Unlock()
+	// Concurrent first renders share one parse, including its retry and its
+	// failure.
+	entry, err := store.Parse(cacheKey, func() (*templateutil.CachedTemplate, error) {
+		tmpl, requiredFuncs, err := p.parseTemplateWithRetry(store, cacheKey, funcs, funcsAreFull, renderTemplates)
+		if err != nil {
+			return nil, err
+		}
+		return templateutil.NewCachedTemplate(tmpl, requiredFuncs), nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.templateFromCacheEntry(entry, funcs, applyFullFuncs, funcsAreFull)
+}
 
-	// Double-check after acquiring lock
-	if entry, cached := store.Load(cacheKey); cached && p.useCache {
-		return p.templateFromCacheEntry(entry, funcs, applyFullFuncs, funcsAreFull)
+// parseTemplateWithRetry parses renderTemplates and retries once after a file
+// system error, so a file briefly missing during a deploy does not fail the
+// render. A template whose retry already failed, such as one that is missing
+// for good, is not retried again until it parses.
+func (p *Partial) parseTemplateWithRetry(store *templateutil.Store, cacheKey string, funcs template.FuncMap, funcsAreFull bool, renderTemplates []string) (*template.Template, map[string]struct{}, error) {
+	tmpl, requiredFuncs, err := p.parseTemplateForRender(funcs, funcsAreFull, renderTemplates)
+	if !isTransientFSError(err) || store.RetryFailed(cacheKey) {
+		if err == nil {
+			store.SetRetryFailed(cacheKey, false)
+		}
+		return tmpl, requiredFuncs, err
 	}
 
+	time.Sleep(templateParseRetryDelay)
+	tmpl, requiredFuncs, err = p.parseTemplateForRender(funcs, funcsAreFull, renderTemplates)
+	store.SetRetryFailed(cacheKey, isTransientFSError(err))
+	return tmpl, requiredFuncs, err
+}
+
+// parseTemplateForRender parses renderTemplates from the partial's file system.
+// With the template cache enabled it also returns the functions the templates
+// require.
+func (p *Partial) parseTemplateForRender(funcs template.FuncMap, funcsAreFull bool, renderTemplates []string) (*template.Template, map[string]struct{}, error) {
 	functions := funcs
 	if !funcsAreFull {
-		functions = templateutil.MergeFuncMaps(p.getStaticFuncMap(), funcs)
+		functions = templateutil.MergeFuncMaps(p.cachedFuncMap().funcs, funcs)
 	}
 	parseFuncs := functions
 	if p.useCache {
-		parseFuncs = templateutil.MergeFuncMaps(p.getStaticFuncMap(), placeholderRequestFuncMap())
+		requestFuncs := placeholderRequestFuncMap()
+		if allowlist := p.getFuncAllowlist(); allowlist != nil {
+			maps.DeleteFunc(requestFuncs, func(name string, _ any) bool {
+				return !funcAllowed(allowlist, name)
+			})
+		}
+		parseFuncs = templateutil.MergeFuncMaps(placeholderFuncMap(funcs), p.cachedFuncMap().funcs, requestFuncs)
 	}
-	t := template.New(path.Base(p.templates[0])).Funcs(parseFuncs)
+	delims := p.getDelims()
+	t := template.New(path.Base(renderTemplates[0])).Delims(delims.Values()).Funcs(parseFuncs)
 	contracts, err := templateutil.RootContractsFromFS(p.getFS(), renderTemplates)
 	if err != nil {
 		return nil, nil, fmt.Errorf("error scanning template contracts: %w", err)
@@ -1245,23 +2991,27 @@ func (p *Partial) getTemplateForRender(cacheKey string, funcs template.FuncMap,
 	}
 	tmpl, err := t.ParseFS(p.getFS(), renderTemplates...)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error parsing templates: %w", err)
+		return nil, nil, &TemplateParseError{Path: renderTemplates[0], Err: err}
 	}
-	if err := templateutil.AddPathAliases(tmpl, renderTemplates); err != nil {
+	if err := templateutil.AddPathAliases(tmpl, renderTemplates, delims); err != nil {
 		return nil, nil, fmt.Errorf("error adding template path aliases: %w", err)
 	}
 
-	if p.useCache {
-		requiredFuncs, err := templateutil.RequiredFuncsFromFS(p.getFS(), renderTemplates)
-		if err != nil {
-			return nil, nil, fmt.Errorf("error scanning template requirements: %w", err)
-		}
-		entry := templateutil.NewCachedTemplate(tmpl, requiredFuncs)
-		store.Store(cacheKey, entry)
-		return p.templateFromCacheEntry(entry, funcs, applyFullFuncs, funcsAreFull)
+	if !p.useCache {
+		return tmpl, nil, nil
+	}
+	requiredFuncs, err := templateutil.RequiredFuncsFromFS(p.getFS(), renderTemplates, delims)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error scanning template requirements: %w", err)
 	}
+	return tmpl, requiredFuncs, nil
+}
 
-	return tmpl, nil, nil
+// isTransientFSError reports whether err came from reading the file system
+// rather than from the template content, so parsing again may succeed.
+func isTransientFSError(err error) bool {
+	var pathErr *fs.PathError
+	return errors.As(err, &pathErr) || errors.Is(err, fs.ErrNotExist)
 }
 
 func (p *Partial) registerContractsForExecution(tmpl *template.Template, renderTemplates []string) error {
@@ -1281,26 +3031,26 @@ func (p *Partial) registerContractsForExecution(tmpl *template.Template, renderT
 	return registerRootContracts(tmpl, contracts, p.getContracts())
 }
 
-func (p *Partial) templateTree() []string {
+func (p *Partial) templateTree(own []string) []string {
 	seen := make(map[string]struct{})
 	refs := make(map[string]struct{})
-	return p.collectTemplateTree(seen, refs)
+	return p.collectTemplateTree(own, seen, refs)
 }
 
-func (p *Partial) collectTemplateTree(seen map[string]struct{}, refs map[string]struct{}) []string {
+func (p *Partial) collectTemplateTree(own []string, seen map[string]struct{}, refs map[string]struct{}) []string {
 	if p == nil {
 		return nil
 	}
 
 	var templates []string
-	for _, name := range p.templates {
+	for _, name := range own {
 		if _, ok := seen[name]; ok {
 			continue
 		}
 		seen[name] = struct{}{}
 		templates = append(templates, name)
 	}
-	maps.Copy(refs, templateutil.ReferencedTemplatesFromFS(p.getFS(), p.templates))
+	maps.Copy(refs, templateutil.ReferencedTemplatesFromFS(p.getFS(), own, p.getDelims()))
 
 	p.mu.RLock()
 	children := make([]*Partial, 0, len(p.children))
@@ -1317,7 +3067,7 @@ func (p *Partial) collectTemplateTree(seen map[string]struct{}, refs map[string]
 		if !child.matchesTemplateReference(refs) {
 			continue
 		}
-		templates = append(templates, child.collectTemplateTree(seen, refs)...)
+		templates = append(templates, child.collectTemplateTree(child.resolvedTemplates(), seen, refs)...)
 	}
 
 	return templates
@@ -1328,7 +3078,7 @@ func (p *Partial) matchesTemplateReference(refs map[string]struct{}) bool {
 		return false
 	}
 
-	defined := templateutil.DefinedTemplatesFromFS(p.getFS(), p.templates)
+	defined := templateutil.DefinedTemplatesFromFS(p.getFS(), p.resolvedTemplates(), p.getDelims())
 	for name := range defined {
 		if _, ok := refs[name]; ok {
 			return true
@@ -1366,28 +3116,61 @@ func (p *Partial) clone() *Partial {
 
 	clone := &Partial{
 		id:              p.id,
+		aliases:         slices.Clone(p.aliases),
 		parent:          p.parent,
 		contentID:       p.contentID,
 		renderOOB:       p.renderOOB,
 		alwaysSwapOOB:   p.alwaysSwapOOB,
+		memoize:         p.memoize,
+		memoKey:         p.memoKey,
 		fs:              p.fs,
 		fsSet:           p.fsSet,
+		overlays:        p.overlays,
+		overlayID:       p.overlayID,
+		baseDir:         p.baseDir,
+		templateExt:     p.templateExt,
+		delims:          p.delims,
 		connector:       p.connector,
+		connectors:      slices.Clone(p.connectors),
 		useCache:        p.useCache,
 		templates:       slices.Clone(p.templates),
-		staticFuncs:     maps.Clone(p.staticFuncs),
+		templatesFor:    maps.Clone(p.templatesFor),
+		staticFuncs:     p.staticFuncs,
+		funcAliases:     p.funcAliases,
+		funcAllowlist:   p.funcAllowlist,
+		protectedFuncs:  slices.Clone(p.protectedFuncs),
 		basePath:        p.basePath,
 		contracts:       slices.Clone(p.contracts),
+		dotTransform:    p.dotTransform,
+		resolver:        p.resolver,
+		trustedKeys:     slices.Clone(p.trustedKeys),
 		extensions:      maps.Clone(p.extensions),
 		responseHeaders: maps.Clone(p.responseHeaders),
+		requiredHeaders: maps.Clone(p.requiredHeaders),
+		cacheControl:    p.cacheControl,
+		deleteOnEmpty:   p.deleteOnEmpty,
+		oobOnly:         p.oobOnly,
+		lastModified:    p.lastModified,
 		responseStatus:  p.responseStatus,
+		contentType:     p.contentType,
+		maxOutputBytes:  p.maxOutputBytes,
+		recoverPanics:   p.recoverPanics,
+		fragmentMarkers: p.fragmentMarkers,
+		oobPosition:     p.oobPosition,
+		validateSwaps:   p.validateSwaps,
+		renderTimeout:   p.renderTimeout,
 		response:        p.response,
 		events:          p.events,
+		errorHandler:    p.errorHandler,
+		notFound:        p.notFound,
+		unknownTarget:   p.unknownTarget,
+		defaultTarget:   p.defaultTarget,
 		stages:          slices.Clone(p.stages),
 		templateCache:   p.templateCache,
 		children:        make(map[string]*Partial, len(p.children)),
 		oobChildren:     maps.Clone(p.oobChildren),
 	}
+	clone.funcCache.Store(p.funcCache.Load())
 	for id, child := range p.children {
 		childClone := child.clone()
 		childClone.parent = clone
@@ -1406,6 +3189,21 @@ func (p *Partial) generateCacheKey(templates []string, templateFuncSignature str
 		builder.WriteString(";")
 	}
 
+	if _, overlayID := p.getOverlays(); overlayID != 0 {
+		builder.WriteString("overlay:")
+		builder.WriteString(strconv.FormatUint(overlayID, 10))
+		builder.WriteString(";")
+	}
+
+	if delims := p.getDelims(); !delims.IsDefault() {
+		left, right := delims.Values()
+		builder.WriteString("delims:")
+		builder.WriteString(left)
+		builder.WriteString(" ")
+		builder.WriteString(right)
+		builder.WriteString(";")
+	}
+
 	builder.WriteString("funcs:")
 	builder.WriteString(templateFuncSignature)
 
@@ -2,12 +2,18 @@ package partial
 
 import (
 	"context"
+	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
+	"maps"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
 
 	"github.com/donseba/go-partial/connector"
 	"github.com/donseba/go-partial/exp/templatehelpers"
@@ -84,6 +90,106 @@ func TestWithTemplateRegistersChildByFileName(t *testing.T) {
 	}
 }
 
+func TestWithContentAddsChildrenToContent(t *testing.T) {
+	fsys := &inMemoryFS{Files: map[string]string{
+		"shell.gohtml":   `<main>{{ content }}</main>`,
+		"content.gohtml": `{{ render "summary" }}|{{ render "details" }}`,
+		"summary.gohtml": `summary`,
+		"details.gohtml": `details`,
+	}}
+
+	shell := NewID("shell", "shell.gohtml").
+		SetFileSystem(fsys).
+		SetContent(NewID("content", "content.gohtml")).
+		WithContent(
+			NewID("summary", "summary.gohtml"),
+			NewID("details", "details.gohtml"),
+		)
+
+	content := shell.children["content"]
+	if len(content.children) != 2 || content.children["summary"].parent != content {
+		t.Fatalf("content children = %v", content.children)
+	}
+	if _, ok := shell.children["summary"]; ok {
+		t.Fatal("WithContent should not register children on the shell")
+	}
+
+	out, err := Render(context.Background(), shell)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if out != "<main>summary|details</main>" {
+		t.Fatalf("Render() = %q", out)
+	}
+
+	bare := NewID("bare", "shell.gohtml").WithContent(NewID("summary", "summary.gohtml"))
+	if len(bare.children) != 0 {
+		t.Fatalf("WithContent without content registered children %v", bare.children)
+	}
+}
+
+type alertComponent struct {
+	ID      string
+	Message string
+}
+
+func (c alertComponent) Partial() *Partial {
+	return NewID(c.ID, "alert.gohtml").SetDot(c)
+}
+
+func TestResolvedTemplatesExpandsGlobs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"views/page.gohtml":             {Data: []byte(`<main>{{ template "card.gohtml" }}{{ template "badge.gohtml" }}</main>`)},
+		"views/components/card.gohtml":  {Data: []byte(`{{ define "card.gohtml" }}<div>card</div>{{ end }}`)},
+		"views/components/badge.gohtml": {Data: []byte(`{{ define "badge.gohtml" }}<span>badge</span>{{ end }}`)},
+		"views/components/notes.txt":    {Data: []byte(`not a template`)},
+	}
+
+	page := NewID("page", "page.gohtml", "components/*.gohtml").SetBaseDir("views")
+
+	got, err := page.ResolvedTemplates(fsys)
+	if err != nil {
+		t.Fatalf("ResolvedTemplates() error = %v", err)
+	}
+	want := []string{"views/page.gohtml", "views/components/badge.gohtml", "views/components/card.gohtml"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("ResolvedTemplates() = %q, want %q", got, want)
+	}
+
+	out, err := Render(context.Background(), page.SetFileSystem(fsys))
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if out != `<main><div>card</div><span>badge</span></main>` {
+		t.Fatalf("Render() = %q", out)
+	}
+
+	if _, err := NewID("page", "missing.gohtml").ResolvedTemplates(fsys); err == nil {
+		t.Fatal("ResolvedTemplates() error = nil, want missing template error")
+	}
+}
+
+func TestComponentsRenderInLayout(t *testing.T) {
+	fsys := &inMemoryFS{Files: map[string]string{
+		"layout.gohtml": `<main>{{ content }}</main><aside>{{ render "notice" }}</aside>`,
+		"alert.gohtml":  `<p id="{{ .ID }}">{{ .Message }}</p>`,
+	}}
+
+	layout := NewID("layout", "layout.gohtml").
+		SetFileSystem(fsys).
+		SetComponent(alertComponent{ID: "welcome", Message: "Hello"}).
+		WithComponent(alertComponent{ID: "notice", Message: "Saved"})
+
+	out, err := Render(context.Background(), layout)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	want := `<main><p id="welcome">Hello</p></main><aside><p id="notice">Saved</p></aside>`
+	if out != template.HTML(want) {
+		t.Fatalf("Render() = %q, want %q", out, want)
+	}
+}
+
 func TestSetModelRegistersGoDocModelContracts(t *testing.T) {
 	fsys := &inMemoryFS{Files: map[string]string{
 		"templates/page.gohtml": `{{/* @model Page github.com/donseba/go-partial.contractPage */}}<h1>{{ Page.Title }}</h1>`,
@@ -588,6 +694,59 @@ func TestPartialHelperRendersTemplatePath(t *testing.T) {
 	}
 }
 
+func TestSetBaseDirResolvesTemplatesForChildren(t *testing.T) {
+	fsys := &inMemoryFS{
+		Files: map[string]string{
+			"modules/shop/shell.gohtml":   `<main>{{ content }}</main>{{ template "footer.gohtml" }}`,
+			"modules/shop/content.gohtml": `<section>{{ partial runtime "badge.gohtml" "Label" "New" }}</section>`,
+			"modules/shop/footer.gohtml":  `<footer>footer</footer>`,
+			"modules/shop/badge.gohtml":   `<span>{{ .Label }}</span>`,
+		},
+	}
+
+	for _, useCache := range []bool{false, true} {
+		shell := NewID("shell", "shell.gohtml").
+			SetFileSystem(fsys).
+			SetBaseDir("modules/shop").
+			UseTemplateCache(useCache)
+		shell.SetContent(NewID("content", "content.gohtml"))
+		shell.With(NewID("footer", "footer.gohtml"))
+
+		out, err := Render(context.Background(), shell)
+		if err != nil {
+			t.Fatalf("Render(useCache=%v) error = %v", useCache, err)
+		}
+
+		expected := `<main><section><span>New</span></section></main><footer>footer</footer>`
+		if string(out) != expected {
+			t.Fatalf("Render(useCache=%v) = %q, want %q", useCache, out, expected)
+		}
+	}
+}
+
+func TestSetTemplateExtensionLoadsBareNames(t *testing.T) {
+	fsys := &inMemoryFS{
+		Files: map[string]string{
+			"views/index.gohtml":   `<main>{{ content }}</main>`,
+			"views/content.gohtml": `<section>content</section>`,
+		},
+	}
+
+	index := New("index").
+		SetFileSystem(fsys).
+		SetBaseDir("views").
+		SetTemplateExtension("gohtml")
+	index.SetContent(NewID("content", "content.gohtml"))
+
+	out, err := Render(context.Background(), index)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if out != `<main><section>content</section></main>` {
+		t.Fatalf("Render() = %q", out)
+	}
+}
+
 func TestTemplateCacheInheritsParentCustomFunctions(t *testing.T) {
 	fsys := &inMemoryFS{
 		Files: map[string]string{
@@ -1464,6 +1623,32 @@ func TestSetDotKeepsRequestHelpersAvailable(t *testing.T) {
 	}
 }
 
+func TestQueryHelpersReadURLQuery(t *testing.T) {
+	fsys := &inMemoryFS{
+		Files: map[string]string{
+			"list.gohtml": `{{ queryGet "sort" }}|{{ index (query) "tag" }}|{{ queryGet "missing" }}`,
+		},
+	}
+	list := NewID("list", "list.gohtml").SetFileSystem(fsys)
+
+	req := httptest.NewRequest(http.MethodGet, "/items?sort=name&tag=a&tag=b", nil)
+	out, err := RenderWithRequest(context.Background(), req, list)
+	if err != nil {
+		t.Fatalf("RenderWithRequest() error = %v", err)
+	}
+	if want := template.HTML("name|[a b]|"); out != want {
+		t.Fatalf("render = %q, want %q", out, want)
+	}
+
+	out, err = Render(context.Background(), list)
+	if err != nil {
+		t.Fatalf("Render() without request error = %v", err)
+	}
+	if want := template.HTML("|[]|"); out != want {
+		t.Fatalf("render without request = %q, want %q", out, want)
+	}
+}
+
 func TestSetDotReplacesExistingDotContract(t *testing.T) {
 	type page struct {
 		Title string
@@ -1485,6 +1670,52 @@ func TestSetDotReplacesExistingDotContract(t *testing.T) {
 	}
 }
 
+// parseCountingFS counts the template parses of each file. Parsing resolves
+// every template name through Glob, while the reference scans that build the
+// cache key only open files.
+type parseCountingFS struct {
+	fs.FS
+	parses map[string]*atomic.Int32
+}
+
+func (f *parseCountingFS) Glob(pattern string) ([]string, error) {
+	if count, ok := f.parses[pattern]; ok {
+		count.Add(1)
+	}
+	return fs.Glob(f.FS, pattern)
+}
+
+func TestTemplateCacheParsesOnceAcrossRenders(t *testing.T) {
+	fsys := &parseCountingFS{
+		FS: fstest.MapFS{
+			"page.gohtml":    {Data: []byte(`<main>{{ content }}{{ render "sidebar" }}</main>`)},
+			"content.gohtml": {Data: []byte(`<p>{{ upper "content" }}</p>`)},
+			"sidebar.gohtml": {Data: []byte(`<aside>sidebar</aside>`)},
+		},
+		parses: map[string]*atomic.Int32{"page.gohtml": {}},
+	}
+
+	page := NewID("page", "page.gohtml").
+		SetFileSystem(fsys).
+		UseTemplateCache(true).
+		SetFunc(templatehelpers.StringFuncMap())
+	page.SetContent(NewID("content", "content.gohtml"))
+	page.With(NewID("sidebar", "sidebar.gohtml"))
+
+	for i := range 2 {
+		out, err := Render(context.Background(), page)
+		if err != nil {
+			t.Fatalf("render %d: %v", i+1, err)
+		}
+		if want := template.HTML(`<main><p>CONTENT</p><aside>sidebar</aside></main>`); out != want {
+			t.Fatalf("render %d = %q, want %q", i+1, out, want)
+		}
+	}
+	if got := fsys.parses["page.gohtml"].Load(); got != 1 {
+		t.Fatalf("page.gohtml parsed %d times, want 1", got)
+	}
+}
+
 func TestPartialSetFuncUsesContractStoreWithCache(t *testing.T) {
 	fsys := &inMemoryFS{Files: map[string]string{
 		"templates/page.html": `{{ label "Ada" }}`,
@@ -1561,3 +1792,685 @@ func TestSetFunc(t *testing.T) {
 	}
 
 }
+
+func TestSetFuncNamespaceKeepsSameNamedFuncsApart(t *testing.T) {
+	fsys := &inMemoryFS{
+		Files: map[string]string{
+			"page.gohtml": `{{ money_format 12 }} {{ date_format 12 }}`,
+		},
+	}
+
+	page := NewID("page", "page.gohtml").
+		SetFileSystem(fsys).
+		SetFuncNamespace("money", template.FuncMap{
+			"format": func(v int) string { return fmt.Sprintf("$%d.00", v) },
+		}).
+		SetFuncNamespace("date", template.FuncMap{
+			"format": func(v int) string { return fmt.Sprintf("day %d", v) },
+		})
+
+	out, err := Render(context.Background(), page)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if out != "$12.00 day 12" {
+		t.Fatalf("Render() = %q, want %q", out, "$12.00 day 12")
+	}
+}
+
+func TestSetFuncAliasesExposesFuncsUnderOtherNames(t *testing.T) {
+	for _, useCache := range []bool{false, true} {
+		fsys := &inMemoryFS{
+			Files: map[string]string{
+				"page.gohtml":    `{{ price 12 }}|{{ prefix }}|{{ content }}`,
+				"content.gohtml": `{{ price 3 }}<div{{ swapOOB }}>{{ prefix }}</div>`,
+			},
+		}
+
+		page := NewID("page", "page.gohtml").
+			SetFileSystem(fsys).
+			SetBasePath("/app").
+			UseTemplateCache(useCache).
+			SetFunc(template.FuncMap{
+				"format": func(v int) string { return fmt.Sprintf("$%d.00", v) },
+			}).
+			SetFuncAliases(map[string]string{
+				"price":   "format",
+				"prefix":  "basePath",
+				"swapOOB": "oobAttr",
+				"content": "format",
+			})
+		page.SetContent(NewID("content", "content.gohtml").UseTemplateCache(useCache))
+
+		out, err := Render(context.Background(), page)
+		if err != nil {
+			t.Fatalf("Render() useCache=%v error = %v", useCache, err)
+		}
+		want := "$12.00|/app|$3.00<div>/app</div>"
+		if out != template.HTML(want) {
+			t.Fatalf("Render() useCache=%v = %q, want %q", useCache, out, want)
+		}
+	}
+}
+
+func TestProtectedFuncNamesAreDroppedWithEvent(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("row.gohtml", `{{ id }}|{{ queryGet "q" }}|{{ label }}`)
+
+	var dropped []string
+	sink := EventSinkFunc(func(ctx *RenderContext, event Event) {
+		if event.Kind != EventFuncProtected {
+			return
+		}
+		dropped = append(dropped, event.Fields["func"].(string))
+	})
+
+	row := NewID("row", "row.gohtml").
+		SetFileSystem(fsys).
+		SetEvents(sink).
+		SetFunc(template.FuncMap{
+			"id":    func() string { return "user-id" },
+			"label": func() string { return "label" },
+		}).
+		SetFuncAliases(map[string]string{"query": "label"})
+
+	r := httptest.NewRequest(http.MethodGet, "/?q=search", nil)
+	out, err := RenderWithFuncs(context.Background(), r, row, template.FuncMap{
+		"queryGet": func() string { return "user-queryGet" },
+	})
+	if err != nil {
+		t.Fatalf("RenderWithFuncs() error = %v", err)
+	}
+	if got, want := string(out), "row|search|label"; got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+
+	slices.Sort(dropped)
+	if want := []string{"id", "query", "queryGet"}; !slices.Equal(dropped, want) {
+		t.Fatalf("func.protected events = %v, want %v", dropped, want)
+	}
+}
+
+func TestSetFuncAllowlistHidesOtherFuncs(t *testing.T) {
+	for _, useCache := range []bool{false, true} {
+		fsys := &inMemoryFS{
+			Files: map[string]string{
+				"allowed.gohtml": `{{ lower .Name }}|{{ id }}`,
+				"upper.gohtml":   `{{ upper .Name }}`,
+			},
+		}
+
+		root := New().
+			SetFileSystem(fsys).
+			UseTemplateCache(useCache).
+			SetFunc(templatehelpers.StringFuncMap())
+		dot := map[string]string{"Name": "Ada"}
+		allowed := NewID("allowed", "allowed.gohtml").SetDot(dot).UseTemplateCache(useCache).SetFuncAllowlist("lower")
+		denied := NewID("denied", "upper.gohtml").SetDot(dot).UseTemplateCache(useCache).SetFuncAllowlist("lower")
+		trusted := NewID("trusted", "upper.gohtml").SetDot(dot).UseTemplateCache(useCache)
+		root.With(allowed).With(denied).With(trusted)
+
+		out, err := Render(context.Background(), allowed)
+		if err != nil {
+			t.Fatalf("Render(allowed) useCache=%v error = %v", useCache, err)
+		}
+		if out != "ada|allowed" {
+			t.Fatalf("Render(allowed) useCache=%v = %q", useCache, out)
+		}
+
+		if _, err := Render(context.Background(), denied); err == nil || !strings.Contains(err.Error(), `function "upper" not defined`) {
+			t.Fatalf("Render(denied) useCache=%v error = %v, want upper to be undefined", useCache, err)
+		}
+
+		out, err = Render(context.Background(), trusted)
+		if err != nil {
+			t.Fatalf("Render(trusted) useCache=%v error = %v", useCache, err)
+		}
+		if out != "ADA" {
+			t.Fatalf("Render(trusted) useCache=%v = %q", useCache, out)
+		}
+	}
+}
+
+func TestSetFuncAllowlistHidesStageAndAliasFuncs(t *testing.T) {
+	for _, useCache := range []bool{false, true} {
+		fsys := &inMemoryFS{
+			Files: map[string]string{
+				"shout.gohtml":  `{{ shout }}`,
+				"secret.gohtml": `{{ secret }}`,
+				"alias.gohtml":  `{{ if req }}request{{ end }}`,
+			},
+		}
+
+		// Like the exp stages, the stage installs per-render functions that are
+		// registered statically for the template parse.
+		root := New().
+			SetFileSystem(fsys).
+			UseTemplateCache(useCache).
+			SetFunc(template.FuncMap{
+				"shout":  func() string { return "" },
+				"secret": func() string { return "" },
+			}).
+			SetFuncAliases(map[string]string{"req": "request"}).
+			Use(RenderStageHooks{PrepareFunc: func(ctx *RenderContext) (*RenderContext, error) {
+				ctx.SetFunc("shout", func() string { return "HI" })
+				ctx.SetFunc("secret", func() string { return "leaked" })
+				return ctx, nil
+			}})
+		shout := NewID("shout", "shout.gohtml").UseTemplateCache(useCache).SetFuncAllowlist("shout")
+		secret := NewID("secret", "secret.gohtml").UseTemplateCache(useCache).SetFuncAllowlist("shout")
+		alias := NewID("alias", "alias.gohtml").UseTemplateCache(useCache).SetFuncAllowlist("shout")
+		root.With(shout).With(secret).With(alias)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		out, err := RenderWithRequest(context.Background(), req, shout)
+		if err != nil {
+			t.Fatalf("Render(shout) useCache=%v error = %v", useCache, err)
+		}
+		if out != "HI" {
+			t.Fatalf("Render(shout) useCache=%v = %q", useCache, out)
+		}
+
+		if out, err := RenderWithRequest(context.Background(), req, secret); err == nil || !strings.Contains(err.Error(), `function "secret" not defined`) {
+			t.Fatalf("Render(secret) useCache=%v = %q, %v, want secret to be undefined", useCache, out, err)
+		}
+		if out, err := RenderWithRequest(context.Background(), req, alias); err == nil || !strings.Contains(err.Error(), `function "req" not defined`) {
+			t.Fatalf("Render(alias) useCache=%v = %q, %v, want req to be undefined", useCache, out, err)
+		}
+	}
+}
+
+func TestSetFuncAllowlistGatesRequestHelpers(t *testing.T) {
+	for _, useCache := range []bool{false, true} {
+		fsys := &inMemoryFS{
+			Files: map[string]string{
+				"cookie.gohtml": `{{ (request).Header.Get "Cookie" }}`,
+				"ctx.gohtml":    `{{ (ctx).Partial.PartialID }}`,
+				"query.gohtml":  `{{ queryGet "q" }}`,
+				"tree.gohtml":   `{{ id }}:{{ render "child" }}`,
+				"child.gohtml":  `child`,
+			},
+		}
+		newPartial := func(id, file string, names ...string) *Partial {
+			return NewID(id, file).SetFileSystem(fsys).UseTemplateCache(useCache).SetFuncAllowlist(names...)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/?q=go", nil)
+		req.Header.Set("Cookie", "session=secret")
+		for name, p := range map[string]*Partial{
+			"request":  newPartial("cookie", "cookie.gohtml"),
+			"ctx":      newPartial("ctx", "ctx.gohtml"),
+			"queryGet": newPartial("query", "query.gohtml"),
+		} {
+			if out, err := RenderWithRequest(context.Background(), req, p); err == nil || !strings.Contains(err.Error(), `function "`+name+`" not defined`) {
+				t.Fatalf("Render(%s) useCache=%v = %q, %v, want %s to be undefined", name, useCache, out, err, name)
+			}
+		}
+
+		out, err := RenderWithRequest(context.Background(), req, newPartial("query", "query.gohtml", "queryGet"))
+		if err != nil || out != "go" {
+			t.Fatalf("Render(listed queryGet) useCache=%v = %q, %v", useCache, out, err)
+		}
+
+		tree := newPartial("tree", "tree.gohtml").With(NewID("child", "child.gohtml"))
+		out, err = RenderWithRequest(context.Background(), req, tree)
+		if err != nil || out != "tree:child" {
+			t.Fatalf("Render(tree helpers) useCache=%v = %q, %v", useCache, out, err)
+		}
+	}
+}
+
+func TestTemplatesForSelectsTemplatesByConnector(t *testing.T) {
+	fsys := &inMemoryFS{
+		Files: map[string]string{
+			"button.gohtml":       `<button hx-post="/save">Save</button>`,
+			"button_turbo.gohtml": `<button data-turbo-method="post">Save</button>`,
+		},
+	}
+
+	cases := []struct {
+		connector connector.Connector
+		expected  string
+	}{
+		{connector.NewHTMX(nil), `<button hx-post="/save">Save</button>`},
+		{connector.NewTurbo(nil), `<button data-turbo-method="post">Save</button>`},
+	}
+	for _, c := range cases {
+		button := NewID("button", "button.gohtml").
+			SetFileSystem(fsys).
+			SetConnector(c.connector).
+			UseTemplateCache(true).
+			TemplatesFor(connector.NameTurbo, "button_turbo.gohtml")
+
+		out, err := Render(context.Background(), button)
+		if err != nil {
+			t.Fatalf("Render(%s) error = %v", connector.NameOf(c.connector), err)
+		}
+		if string(out) != c.expected {
+			t.Fatalf("Render(%s) = %q, want %q", connector.NameOf(c.connector), out, c.expected)
+		}
+	}
+}
+
+func TestSetDelimsRendersCustomDelimiters(t *testing.T) {
+	fsys := &inMemoryFS{
+		Files: map[string]string{
+			"page.gohtml": `<div x-text="{{ client }}">[[ .Name ]]</div>[[ content ]]`,
+			"body.gohtml": `<p>[[ upper .Name ]]</p>`,
+		},
+	}
+
+	for _, useCache := range []bool{false, true} {
+		page := NewID("page", "page.gohtml").
+			SetFileSystem(fsys).
+			SetDelims("[[", "]]").
+			SetFunc(templatehelpers.StringFuncMap()).
+			UseTemplateCache(useCache).
+			SetDot(map[string]string{"Name": "Ada"})
+		page.SetContent(NewID("body", "body.gohtml").SetDot(map[string]string{"Name": "Ada"}))
+
+		out, err := Render(context.Background(), page)
+		if err != nil {
+			t.Fatalf("Render(useCache=%v) error = %v", useCache, err)
+		}
+		expected := `<div x-text="{{ client }}">Ada</div><p>ADA</p>`
+		if string(out) != expected {
+			t.Fatalf("Render(useCache=%v) = %q, want %q", useCache, out, expected)
+		}
+	}
+}
+
+func TestRenderHelperCapturesPartialByID(t *testing.T) {
+	fsys := &inMemoryFS{
+		Files: map[string]string{
+			"shell.gohtml":   `<main>{{ content }}</main>`,
+			"content.gohtml": `{{ $footer := render "footer" }}<aside>{{ $footer }}</aside><div>{{ $footer }}</div>`,
+			"footer.gohtml":  `<footer>{{ .Year }}</footer>`,
+		},
+	}
+
+	for _, useCache := range []bool{false, true} {
+		shell := NewID("shell", "shell.gohtml").SetFileSystem(fsys).UseTemplateCache(useCache)
+		shell.SetContent(NewID("content", "content.gohtml"))
+		shell.With(NewID("footer", "footer.gohtml").SetDot(map[string]int{"Year": 2024}))
+
+		out, err := Render(context.Background(), shell)
+		if err != nil {
+			t.Fatalf("Render(useCache=%v) error = %v", useCache, err)
+		}
+		expected := `<main><aside><footer>2024</footer></aside><div><footer>2024</footer></div></main>`
+		if string(out) != expected {
+			t.Fatalf("Render(useCache=%v) = %q, want %q", useCache, out, expected)
+		}
+	}
+}
+
+func TestChildrenReturnsSortedSnapshot(t *testing.T) {
+	sidebar := NewID("sidebar", "sidebar.gohtml")
+	footer := NewID("footer", "footer.gohtml")
+	content := NewID("content", "content.gohtml")
+	shell := NewID("shell", "shell.gohtml").With(sidebar).With(footer)
+	shell.SetContent(content)
+
+	children := shell.Children()
+	if len(children) != 3 || children[0] != content || children[1] != footer || children[2] != sidebar {
+		t.Fatalf("Children() = %v, want content, footer, sidebar", children)
+	}
+
+	children[0] = nil
+	if shell.Children()[0] != content {
+		t.Fatal("Children() should return a copy")
+	}
+}
+
+func TestAddDotSetsSingleKey(t *testing.T) {
+	fsys := &inMemoryFS{
+		Files: map[string]string{
+			"page.gohtml": `{{ .Title }}|{{ .User }}`,
+		},
+	}
+
+	base := map[string]any{"Title": "Orders"}
+	page := NewID("page", "page.gohtml").
+		SetFileSystem(fsys).
+		SetDot(base).
+		AddDot("User", "Ada")
+
+	out, err := Render(context.Background(), page)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if out != "Orders|Ada" {
+		t.Fatalf("Render() = %q, want %q", out, "Orders|Ada")
+	}
+	if _, ok := base["User"]; ok {
+		t.Fatal("AddDot modified the map passed to SetDot")
+	}
+
+	empty := NewID("empty", "page.gohtml").SetFileSystem(fsys).AddDot("Title", "New")
+	if out, err := Render(context.Background(), empty); err != nil || out != "New|" {
+		t.Fatalf("Render(empty) = %q, %v", out, err)
+	}
+}
+
+func TestMergeDotKeepsExistingKeysUnlessOverride(t *testing.T) {
+	fsys := &inMemoryFS{
+		Files: map[string]string{
+			"page.gohtml": `{{ .Title }}|{{ .Theme }}|{{ .User }}`,
+		},
+	}
+	defaults := map[string]any{"Title": "Default", "Theme": "light"}
+
+	page := NewID("page", "page.gohtml").
+		SetFileSystem(fsys).
+		SetDot(map[string]any{"Title": "Orders"}).
+		MergeDot(defaults, false).
+		MergeDot(map[string]any{"User": "Ada", "Theme": "dark"}, true)
+
+	out, err := Render(context.Background(), page)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if out != "Orders|dark|Ada" {
+		t.Fatalf("Render() = %q, want %q", out, "Orders|dark|Ada")
+	}
+	if defaults["Theme"] != "light" {
+		t.Fatal("MergeDot modified the merged map")
+	}
+}
+
+func TestSetDotTransformAddsComputedField(t *testing.T) {
+	fsys := &inMemoryFS{
+		Files: map[string]string{
+			"cart.gohtml": `{{ .Count }} items, total {{ .Total }}`,
+		},
+	}
+
+	cart := NewID("cart", "cart.gohtml").
+		SetFileSystem(fsys).
+		SetDot(map[string]any{"Prices": []int{3, 4, 5}}).
+		SetDotTransform(func(ctx *RenderContext, dot any) (any, error) {
+			data := maps.Clone(dot.(map[string]any))
+			prices := data["Prices"].([]int)
+			total := 0
+			for _, price := range prices {
+				total += price
+			}
+			data["Count"] = len(prices)
+			data["Total"] = total
+			return data, nil
+		})
+
+	out, err := Render(context.Background(), cart)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if out != "3 items, total 12" {
+		t.Fatalf("Render() = %q, want %q", out, "3 items, total 12")
+	}
+}
+
+func TestSetTemplateResolverChoosesTemplateFromDot(t *testing.T) {
+	fsys := &inMemoryFS{
+		Files: map[string]string{
+			"blocks/text.gohtml":  `<p>{{ .Body }}</p>`,
+			"blocks/quote.gohtml": `<blockquote>{{ .Body }}</blockquote>`,
+		},
+	}
+
+	block := NewID("block").
+		SetFileSystem(fsys).
+		SetBaseDir("blocks").
+		UseTemplateCache(true).
+		SetTemplateResolver(func(ctx *RenderContext, dot any) ([]string, error) {
+			return []string{dot.(map[string]any)["Type"].(string) + ".gohtml"}, nil
+		})
+
+	for _, tc := range []struct {
+		kind string
+		want template.HTML
+	}{
+		{kind: "text", want: "<p>Hello</p>"},
+		{kind: "quote", want: "<blockquote>Hello</blockquote>"},
+		{kind: "text", want: "<p>Hello</p>"},
+	} {
+		block.SetDot(map[string]any{"Type": tc.kind, "Body": "Hello"})
+		out, err := Render(context.Background(), block)
+		if err != nil {
+			t.Fatalf("Render(%s) error = %v", tc.kind, err)
+		}
+		if out != tc.want {
+			t.Fatalf("Render(%s) = %q, want %q", tc.kind, out, tc.want)
+		}
+	}
+}
+
+func TestSetTrustedKeysRendersTrustedValuesUnescaped(t *testing.T) {
+	fsys := &inMemoryFS{
+		Files: map[string]string{
+			"post.gohtml": `<h1>{{ .Title }}</h1>{{ .Body }}`,
+		},
+	}
+
+	for _, dot := range []any{
+		map[string]any{"Title": "<b>Hi</b>", "Body": "<p>Sanitized</p>"},
+		map[string]string{"Title": "<b>Hi</b>", "Body": "<p>Sanitized</p>"},
+	} {
+		post := NewID("post", "post.gohtml").
+			SetFileSystem(fsys).
+			SetDot(dot).
+			SetTrustedKeys("Body")
+
+		out, err := Render(context.Background(), post)
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		want := template.HTML(`<h1>&lt;b&gt;Hi&lt;/b&gt;</h1><p>Sanitized</p>`)
+		if out != want {
+			t.Fatalf("Render(%T) = %q, want %q", dot, out, want)
+		}
+	}
+}
+
+func TestInheritedFuncsFollowFuncChangesAndReparenting(t *testing.T) {
+	fsys := &inMemoryFS{
+		Files: map[string]string{
+			"shell.gohtml":   `{{ content }}`,
+			"content.gohtml": `{{ greet }}`,
+		},
+	}
+	greet := func(value string) template.FuncMap {
+		return template.FuncMap{"greet": func() string { return value }}
+	}
+
+	content := NewID("content", "content.gohtml")
+	first := NewID("first", "shell.gohtml").SetFileSystem(fsys).SetFunc(greet("hello"))
+	first.SetContent(content)
+	render := func(root *Partial, want template.HTML) {
+		t.Helper()
+		out, err := Render(context.Background(), root)
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		if out != want {
+			t.Fatalf("Render() = %q, want %q", out, want)
+		}
+	}
+
+	render(first, "hello")
+	first.SetFunc(greet("hi"))
+	render(first, "hi")
+
+	second := NewID("second", "shell.gohtml").SetFileSystem(fsys).SetFunc(greet("hey"))
+	second.SetContent(content)
+	render(second, "hey")
+}
+
+func TestFuncMapCacheIsSharedByClonesAndScopedToTheTree(t *testing.T) {
+	root := NewID("root").SetFunc(template.FuncMap{"greet": func() string { return "hello" }})
+	child := NewID("child")
+	root.With(child)
+	unrelated := NewID("unrelated")
+
+	cached := child.cachedFuncMap()
+	unrelated.SetFunc(template.FuncMap{"other": func() string { return "" }})
+	if child.cachedFuncMap() != cached {
+		t.Fatal("SetFunc on an unrelated partial rebuilt the function map")
+	}
+
+	clone := root.clone()
+	cloneChild := clone.recursiveChildLookup("child", make(map[string]bool))
+	if cloneChild == nil || cloneChild.cachedFuncMap() != cached {
+		t.Fatal("a clone rebuilt the function map it shares with the original")
+	}
+
+	cloneChild.SetFunc(template.FuncMap{"local": func() string { return "" }})
+	if _, ok := cloneChild.cachedFuncMap().funcs["local"]; !ok {
+		t.Fatal("SetFunc on the clone did not rebuild its function map")
+	}
+	if _, ok := child.cachedFuncMap().funcs["local"]; ok || child.cachedFuncMap() != cached {
+		t.Fatal("SetFunc on the clone changed the original's function map")
+	}
+
+	root.SetFunc(template.FuncMap{"greet": func() string { return "hi" }})
+	if child.cachedFuncMap() == cached {
+		t.Fatal("SetFunc on the parent did not rebuild the child's function map")
+	}
+}
+
+func TestBreadcrumbsReturnsTrailFromRoot(t *testing.T) {
+	fsys := &inMemoryFS{
+		Files: map[string]string{
+			"shell.gohtml":   `{{ content }}`,
+			"section.gohtml": `{{ template "row.gohtml" }}`,
+			"row.gohtml":     `{{ range $i, $id := ctx.Partial.Breadcrumbs }}{{ if $i }} / {{ end }}{{ $id }}{{ end }}`,
+		},
+	}
+	row := NewID("row", "row.gohtml")
+	section := NewID("section", "section.gohtml").With(row)
+	shell := NewID("shell", "shell.gohtml").SetFileSystem(fsys)
+	shell.SetContent(section)
+
+	if got := row.Breadcrumbs(); !slices.Equal(got, []string{"shell", "section", "row"}) {
+		t.Fatalf("Breadcrumbs() = %v, want [shell section row]", got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(connector.HeaderTarget.String(), "row")
+	out, err := RenderWithRequest(context.Background(), req, shell)
+	if err != nil {
+		t.Fatalf("RenderWithRequest() error = %v", err)
+	}
+	if out != "shell / section / row" {
+		t.Fatalf("output = %q, want %q", out, "shell / section / row")
+	}
+}
+
+func TestIDHelperRendersOwnPartialID(t *testing.T) {
+	fsys := &inMemoryFS{
+		Files: map[string]string{
+			"shell.gohtml":  `<main id="{{ id }}">{{ content }}</main>`,
+			"notice.gohtml": `<aside id="{{ id }}">Notice</aside>`,
+		},
+	}
+	for _, useCache := range []bool{false, true} {
+		shell := NewID("shell", "shell.gohtml").SetFileSystem(fsys).UseTemplateCache(useCache)
+		shell.SetContent(NewID("notice", "notice.gohtml"))
+
+		out, err := Render(context.Background(), shell)
+		if err != nil {
+			t.Fatalf("Render(useCache=%v) error = %v", useCache, err)
+		}
+		if want := `<main id="shell"><aside id="notice">Notice</aside></main>`; string(out) != want {
+			t.Fatalf("Render(useCache=%v) = %q, want %q", useCache, out, want)
+		}
+	}
+}
+
+func TestSetOverlayFSOverridesBaseTemplates(t *testing.T) {
+	base := &inMemoryFS{
+		Files: map[string]string{
+			"shell.gohtml":  `<main>{{ template "footer.gohtml" }}</main>`,
+			"footer.gohtml": `<footer>base</footer>`,
+		},
+	}
+	theme := &inMemoryFS{
+		Files: map[string]string{
+			"footer.gohtml": `<footer>theme</footer>`,
+		},
+	}
+
+	blueprint := NewID("shell", "shell.gohtml").SetFileSystem(base).UseTemplateCache(true)
+	blueprint.With(NewID("footer", "footer.gohtml"))
+
+	out, err := Render(context.Background(), blueprint.Clone())
+	if err != nil {
+		t.Fatalf("Render(base) error = %v", err)
+	}
+	if out != "<main><footer>base</footer></main>" {
+		t.Fatalf("Render(base) = %q", out)
+	}
+
+	out, err = Render(context.Background(), blueprint.Clone().SetOverlayFS(theme))
+	if err != nil {
+		t.Fatalf("Render(theme) error = %v", err)
+	}
+	if out != "<main><footer>theme</footer></main>" {
+		t.Fatalf("Render(theme) = %q", out)
+	}
+}
+
+func TestPartialExistsHelper(t *testing.T) {
+	fsys := &inMemoryFS{
+		Files: map[string]string{
+			"shell.gohtml":   `{{ content }}`,
+			"content.gohtml": `{{ if partialExists "sidebar" }}{{ render "sidebar" }}{{ end }}|{{ if partialExists "missing" }}missing{{ else }}none{{ end }}`,
+			"sidebar.gohtml": `<nav>sidebar</nav>`,
+		},
+	}
+
+	for _, useCache := range []bool{false, true} {
+		shell := NewID("shell", "shell.gohtml").SetFileSystem(fsys).UseTemplateCache(useCache)
+		shell.SetContent(NewID("content", "content.gohtml"))
+		shell.With(NewID("sidebar", "sidebar.gohtml"))
+
+		out, err := Render(context.Background(), shell)
+		if err != nil {
+			t.Fatalf("Render(useCache=%v) error = %v", useCache, err)
+		}
+		if out != "<nav>sidebar</nav>|none" {
+			t.Fatalf("Render(useCache=%v) = %q", useCache, out)
+		}
+	}
+}
+
+func TestIncludeHelperRendersNamedTemplateWithData(t *testing.T) {
+	fsys := &inMemoryFS{
+		Files: map[string]string{
+			"list.gohtml":    `<ul>{{ range .Items }}{{ include "card" . }}{{ end }}</ul>`,
+			"card.gohtml":    `{{ define "card" }}<li>{{ .Name }}</li>{{ end }}`,
+			"missing.gohtml": `{{ include "nope" . }}`,
+		},
+	}
+	type item struct{ Name string }
+
+	for _, useCache := range []bool{false, true} {
+		list := NewID("list", "list.gohtml", "card.gohtml").
+			SetFileSystem(fsys).
+			UseTemplateCache(useCache).
+			SetDot(map[string]any{"Items": []item{{Name: "Tea"}, {Name: "<b>Cake</b>"}}})
+
+		out, err := Render(context.Background(), list)
+		if err != nil {
+			t.Fatalf("Render(useCache=%v) error = %v", useCache, err)
+		}
+		if out != "<ul><li>Tea</li><li>&lt;b&gt;Cake&lt;/b&gt;</li></ul>" {
+			t.Fatalf("Render(useCache=%v) = %q", useCache, out)
+		}
+
+		missing := NewID("missing", "missing.gohtml").SetFileSystem(fsys).UseTemplateCache(useCache)
+		if _, err := Render(context.Background(), missing); err == nil || !strings.Contains(err.Error(), "nope") {
+			t.Fatalf("Render(missing, useCache=%v) error = %v, want missing include error", useCache, err)
+		}
+	}
+}
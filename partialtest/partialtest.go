@@ -0,0 +1,61 @@
+// Package partialtest provides utilities for testing handlers that render
+// partials.
+package partialtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/donseba/go-partial/connector"
+)
+
+// NewRequest returns a request for method and path that conn reads as a
+// partial request for target, with the select and action values sent in
+// conn's headers. Empty values are left out. HTMX requests also carry
+// HX-Request, which the HTMX connector requires.
+func NewRequest(conn connector.Connector, method, path, target, selection, action string) *http.Request {
+	if conn == nil {
+		conn = connector.NewPartial(nil)
+	}
+	r := newRequest(conn, method, path)
+	setHeader(r, conn.GetTargetHeader(), target)
+	setHeader(r, conn.GetSelectHeader(), selection)
+	setHeader(r, conn.GetActionHeader(), action)
+	return r
+}
+
+// NewQueryRequest returns a request for method and path with the target,
+// select, and action values in the URL query, as read by connectors configured
+// with UseURLQuery. Empty values are left out. Like NewRequest, HTMX requests
+// also carry HX-Request.
+func NewQueryRequest(conn connector.Connector, method, path, target, selection, action string) *http.Request {
+	r := newRequest(conn, method, path)
+	query := r.URL.Query()
+	setQuery(query, "target", target)
+	setQuery(query, "select", selection)
+	setQuery(query, "action", action)
+	r.URL.RawQuery = query.Encode()
+	r.RequestURI = r.URL.RequestURI()
+	return r
+}
+
+func newRequest(conn connector.Connector, method, path string) *http.Request {
+	r := httptest.NewRequest(method, path, nil)
+	if connector.NameOf(conn) == connector.NameHTMX {
+		r.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+	}
+	return r
+}
+
+func setHeader(r *http.Request, name, value string) {
+	if name != "" && value != "" {
+		r.Header.Set(name, value)
+	}
+}
+
+func setQuery(query url.Values, name, value string) {
+	if value != "" {
+		query.Set(name, value)
+	}
+}
@@ -0,0 +1,77 @@
+package partialtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"testing/fstest"
+
+	partial "github.com/donseba/go-partial"
+	"github.com/donseba/go-partial/connector"
+)
+
+func TestNewRequestUsesConnectorHeaders(t *testing.T) {
+	connectors := []connector.Connector{
+		connector.NewPartial(nil),
+		connector.NewHTMX(nil),
+		connector.NewTurbo(nil),
+		connector.NewUnpoly(nil),
+	}
+	for _, conn := range connectors {
+		t.Run(connector.NameOf(conn), func(t *testing.T) {
+			r := NewRequest(conn, http.MethodPost, "/items", "list", "grid", "save")
+			if !conn.RenderPartial(r) {
+				t.Fatal("RenderPartial() = false, want true")
+			}
+			if got := conn.GetTargetValue(r); got != "list" {
+				t.Fatalf("target = %q, want list", got)
+			}
+			if got := conn.GetSelectValue(r); got != "grid" {
+				t.Fatalf("select = %q, want grid", got)
+			}
+			if got := conn.GetActionValue(r); got != "save" {
+				t.Fatalf("action = %q, want save", got)
+			}
+			if r.Method != http.MethodPost || r.URL.Path != "/items" {
+				t.Fatalf("request = %s %s", r.Method, r.URL.Path)
+			}
+		})
+	}
+}
+
+func TestNewQueryRequestUsesURLQuery(t *testing.T) {
+	conn := connector.NewPartial(&connector.Config{UseURLQuery: true})
+	r := NewQueryRequest(conn, http.MethodGet, "/items?page=2", "list", "", "refresh")
+
+	if got := conn.GetTargetValue(r); got != "list" {
+		t.Fatalf("target = %q, want list", got)
+	}
+	if got := conn.GetActionValue(r); got != "refresh" {
+		t.Fatalf("action = %q, want refresh", got)
+	}
+	if r.URL.Query().Has("select") {
+		t.Fatalf("empty select was added to %q", r.URL.RawQuery)
+	}
+	if got := r.URL.Query().Get("page"); got != "2" {
+		t.Fatalf("existing query lost: %q", r.URL.RawQuery)
+	}
+	if r.Header.Get(conn.GetTargetHeader()) != "" {
+		t.Fatal("NewQueryRequest should not set target headers")
+	}
+}
+
+func ExampleNewRequest() {
+	fsys := fstest.MapFS{
+		"page.gohtml": &fstest.MapFile{Data: []byte(`<main>{{ content }}</main>`)},
+		"list.gohtml": &fstest.MapFile{Data: []byte(`<ul id="list"></ul>`)},
+	}
+	conn := connector.NewHTMX(nil)
+	page := partial.NewID("page", "page.gohtml").SetFileSystem(fsys).SetConnector(conn)
+	page.SetContent(partial.NewID("list", "list.gohtml"))
+
+	r := NewRequest(conn, http.MethodGet, "/", "list", "", "")
+	out, _ := partial.RenderWithRequest(context.Background(), r, page)
+	fmt.Println(out)
+	// Output: <ul id="list"></ul>
+}
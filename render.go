@@ -2,12 +2,192 @@ package partial
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
+	"io"
+	"maps"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/donseba/go-partial/connector"
 )
 
+const defaultContentType = "text/html; charset=utf-8"
+
+type withoutOOBContextKey struct{}
+
+// WithoutOOB returns a context whose renders skip out-of-band regions, for
+// requests where only the requested target should be returned. Full-page
+// renders never append out-of-band regions.
+func WithoutOOB(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, withoutOOBContextKey{}, true)
+}
+
+func oobDisabled(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	disabled, _ := ctx.Value(withoutOOBContextKey{}).(bool)
+	return disabled
+}
+
+// withRenderTimeout bounds ctx by timeout. The returned context is ctx itself
+// when timeout is not positive.
+func withRenderTimeout(ctx context.Context, r *http.Request, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	if ctx == nil {
+		if r != nil {
+			ctx = r.Context()
+		} else {
+			ctx = defaultRenderContext()
+		}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// renderTimeoutResult replaces result with a timeout error when the render
+// timeout of p expired during the render.
+func renderTimeoutResult(ctx context.Context, p *Partial, timeout time.Duration, result renderResult) renderResult {
+	if timeout <= 0 || ctx == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return result
+	}
+	return renderResult{Err: fmt.Errorf("rendering partial %s exceeded timeout of %s: %w", p.PartialID(), timeout, context.DeadlineExceeded)}
+}
+
+type requestTargetContextKey struct{}
+
+// withRequestTarget returns ctx marking p as the partial the request resolved
+// to.
+func withRequestTarget(ctx context.Context, p *Partial) context.Context {
+	if ctx == nil {
+		return nil
+	}
+	return context.WithValue(ctx, requestTargetContextKey{}, p)
+}
+
+// IsRequestTarget reports whether ctx renders the partial the request resolved
+// to: the partial whose ID or alias the request names as its target, the
+// default target when it names none, or the root of a full-page render.
+// Children rendered inside that partial are not the request target.
+func (ctx *RenderContext) IsRequestTarget() bool {
+	if ctx == nil || ctx.Context == nil || ctx.Partial == nil {
+		return false
+	}
+	target, _ := ctx.Context.Value(requestTargetContextKey{}).(*Partial)
+	return target == ctx.Partial
+}
+
+type renderMemoContextKey struct{}
+
+// renderMemoStore holds the output of memoized partials and the results of
+// Runtime.Once for one render pass.
+type renderMemoStore struct {
+	mu      sync.Mutex
+	entries map[string]template.HTML
+	results map[any]*onceResult
+}
+
+// onceResult is the shared result of one Runtime.Once key.
+type onceResult struct {
+	once  sync.Once
+	value any
+	err   error
+}
+
+// withRenderMemo returns ctx carrying a memo store for a new render pass.
+// Renders nested in the pass keep the store of their parent.
+func withRenderMemo(ctx context.Context) context.Context {
+	if ctx == nil {
+		return nil
+	}
+	if _, ok := ctx.Value(renderMemoContextKey{}).(*renderMemoStore); ok {
+		return ctx
+	}
+	return withNewRenderMemo(ctx)
+}
+
+// withNewRenderMemo returns ctx carrying an empty memo store, replacing any
+// store of an enclosing render pass.
+func withNewRenderMemo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, renderMemoContextKey{}, &renderMemoStore{})
+}
+
+// renderMemo returns the memo store of the render pass and the key for p when
+// p is memoized.
+func (p *Partial) renderMemo(ctx context.Context) (*renderMemoStore, string) {
+	p.mu.RLock()
+	memoize, memoKey := p.memoize, p.memoKey
+	p.mu.RUnlock()
+	if !memoize || ctx == nil {
+		return nil, ""
+	}
+	memo := renderMemoFrom(ctx)
+	if memo == nil {
+		return nil, ""
+	}
+
+	if memoKey == nil {
+		return memo, p.id
+	}
+	dot, _ := p.getDotContract()
+	return memo, p.id + "|" + memoKey(dot)
+}
+
+// renderMemoFrom returns the memo store of the render pass carried by ctx.
+func renderMemoFrom(ctx context.Context) *renderMemoStore {
+	if ctx == nil {
+		return nil
+	}
+	memo, _ := ctx.Value(renderMemoContextKey{}).(*renderMemoStore)
+	return memo
+}
+
+func (m *renderMemoStore) once(key any, fn func() (any, error)) (any, error) {
+	m.mu.Lock()
+	if m.results == nil {
+		m.results = make(map[any]*onceResult)
+	}
+	result, ok := m.results[key]
+	if !ok {
+		result = &onceResult{}
+		m.results[key] = result
+	}
+	m.mu.Unlock()
+
+	result.once.Do(func() {
+		result.value, result.err = fn()
+	})
+	return result.value, result.err
+}
+
+func (m *renderMemoStore) load(key string) (template.HTML, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	html, ok := m.entries[key]
+	return html, ok
+}
+
+func (m *renderMemoStore) store(key string, html template.HTML) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.entries == nil {
+		m.entries = make(map[string]template.HTML)
+	}
+	m.entries[key] = html
+}
+
 // Render renders a partial without an http.Request.
 //
 // Use Render for tests, offline rendering, and jobs that do not have request
@@ -15,13 +195,60 @@ import (
 // caller needs the HTML string instead of a written response.
 func Render(ctx context.Context, p *Partial) (template.HTML, error) {
 	if p == nil {
-		return "", errors.New("partial is not initialized")
+		return "", ErrPartialNotInitialized
 	}
 
-	result := renderSelfResult(ctx, nil, p)
+	timeout := p.getRenderTimeout()
+	ctx, cancel := withRenderTimeout(ctx, nil, timeout)
+	defer cancel()
+
+	result := renderTimeoutResult(ctx, p, timeout, renderSelfResult(ctx, nil, p))
 	return result.HTML, result.Err
 }
 
+// RenderDot renders p without an http.Request, executing its templates with
+// dot exactly as given. p's configured dot is ignored and p is not modified,
+// which keeps unit tests of a single template deterministic.
+func RenderDot(ctx context.Context, p *Partial, dot any) (template.HTML, error) {
+	if p == nil {
+		return "", ErrPartialNotInitialized
+	}
+
+	result := renderSelfResult(ctx, nil, p.clone().SetDot(dot))
+	return result.HTML, result.Err
+}
+
+// TemplateFunc returns a template function that renders p for ctx and r, so
+// go-partial output can be embedded in another html/template set:
+//
+//	outer.Funcs(template.FuncMap{"sidebar": partial.TemplateFunc(ctx, r, sidebar)})
+//
+// Each call renders p itself; the request's target does not select a child.
+func TemplateFunc(ctx context.Context, r *http.Request, p *Partial) func() (template.HTML, error) {
+	return func() (template.HTML, error) {
+		if p == nil {
+			return "", ErrPartialNotInitialized
+		}
+		result := renderSelfResult(ctx, r, p)
+		return result.HTML, result.Err
+	}
+}
+
+// FastRender executes the pre-parsed template t, or its associated template
+// name when name is not empty, with data and writes the output to w. It skips
+// the partial tree, render stages, function maps, and template cache, so
+// helpers such as content, render, and ctx are not available. Use it only on
+// profiled hot paths where those features are not needed.
+func FastRender(t *template.Template, name string, data any, w io.Writer) error {
+	if t == nil {
+		return ErrNoTemplates
+	}
+	if name == "" {
+		return t.Execute(w, data)
+	}
+	return t.ExecuteTemplate(w, name, data)
+}
+
 // RenderWithRequest renders a partial using request-aware connector behavior.
 //
 // When the connector identifies the request as a partial request, this renders
@@ -32,33 +259,316 @@ func RenderWithRequest(ctx context.Context, r *http.Request, p *Partial) (templa
 	return result.HTML, result.Err
 }
 
+// RenderWithData renders p like RenderWithRequest, using data as the dot for
+// this call only. When p's configured dot is a map[string]any, data is merged
+// over it as defaults. p itself is not modified, so one partial can be reused
+// across calls without state carrying over.
+func RenderWithData(ctx context.Context, r *http.Request, p *Partial, data map[string]any) (template.HTML, error) {
+	if p == nil {
+		return "", ErrPartialNotInitialized
+	}
+
+	dot := make(map[string]any, len(data))
+	if value, ok := p.getDotContract(); ok {
+		if defaults, ok := value.(map[string]any); ok {
+			maps.Copy(dot, defaults)
+		}
+	}
+	maps.Copy(dot, data)
+
+	result := renderWithRequestResult(ctx, r, p.clone().SetDot(dot))
+	return result.HTML, result.Err
+}
+
+// RenderWithFuncs renders p like RenderWithRequest, with funcs added to its
+// template functions for this call only. Core helper names in funcs are
+// dropped and reported, as with SetFunc. p itself is not modified, so later renders do not
+// see the call-scoped functions.
+func RenderWithFuncs(ctx context.Context, r *http.Request, p *Partial, funcs template.FuncMap) (template.HTML, error) {
+	if p == nil {
+		return "", ErrPartialNotInitialized
+	}
+
+	result := renderWithRequestResult(ctx, r, p.clone().Use(callFuncsStage(funcs)))
+	return result.HTML, result.Err
+}
+
+// callFuncsStage installs funcs as render-scoped functions of every partial in
+// the render, so they never reach a partial's static functions.
+func callFuncsStage(funcs template.FuncMap) RenderStage {
+	funcs = maps.Clone(funcs)
+	var protected []string
+	maps.DeleteFunc(funcs, func(name string, _ any) bool {
+		if isProtectedFunctionName(name) {
+			protected = append(protected, name)
+			return true
+		}
+		return false
+	})
+	slices.Sort(protected)
+	// The stage runs for every partial in the render, but the dropped names
+	// are reported once per call.
+	var reported sync.Once
+	return RenderStageHooks{
+		PrepareFunc: func(ctx *RenderContext) (*RenderContext, error) {
+			if ctx == nil {
+				return ctx, nil
+			}
+			reported.Do(func() {
+				for _, name := range protected {
+					emitProtectedFunc(ctx, ctx.Partial, name)
+				}
+			})
+			for name, fn := range funcs {
+				ctx.SetFunc(name, fn)
+			}
+			return ctx, nil
+		},
+	}
+}
+
+// StreamJSON renders the partial for each ID received from ids and writes it
+// to w as newline-delimited JSON, one {"id": ..., "html": ...} object per
+// line, flushing after each line so clients can hydrate rows as they arrive.
+// Out-of-band regions are not included. Streaming stops when ids is closed,
+// when ctx is done, or at the first render or write error.
+func StreamJSON(ctx context.Context, w http.ResponseWriter, r *http.Request, p *Partial, ids <-chan string) error {
+	if w == nil {
+		return errors.New("response writer is not configured")
+	}
+	if p == nil {
+		return ErrPartialNotInitialized
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	controller := http.NewResponseController(w)
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case id, ok := <-ids:
+			if !ok {
+				return nil
+			}
+			// Each fragment is its own render pass, so memoized output and
+			// Runtime.Once results never outlive the fragment they belong to.
+			html, err := renderRegion(withNewRenderMemo(ctx), r, p, id)
+			if err != nil {
+				return err
+			}
+			if err := encoder.Encode(streamFragment{ID: id, HTML: html}); err != nil {
+				return fmt.Errorf("error writing fragment '%s': %w", id, err)
+			}
+			if err := controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return fmt.Errorf("error flushing fragment '%s': %w", id, err)
+			}
+		}
+	}
+}
+
+type streamFragment struct {
+	ID   string        `json:"id"`
+	HTML template.HTML `json:"html"`
+}
+
+// RenderChangedRegions renders each partial ID that is a key of prevHashes,
+// like RenderRegions, and returns only the regions whose RegionHash differs
+// from the hash the client sent for it. An empty previous hash always returns
+// the region. Polling clients can send the hashes of what they display and
+// receive only what changed.
+func RenderChangedRegions(ctx context.Context, r *http.Request, p *Partial, prevHashes map[string]string) (map[string]template.HTML, error) {
+	regions, err := RenderRegions(ctx, r, p, slices.Sorted(maps.Keys(prevHashes))...)
+	if err != nil {
+		return nil, err
+	}
+	maps.DeleteFunc(regions, func(id string, html template.HTML) bool {
+		return prevHashes[id] != "" && prevHashes[id] == RegionHash(html)
+	})
+	return regions, nil
+}
+
+// RegionHash returns the hex-encoded SHA-256 hash of a rendered region, the
+// value RenderChangedRegions compares with the hashes a client sends.
+func RegionHash(html template.HTML) string {
+	sum := sha256.Sum256([]byte(html))
+	return hex.EncodeToString(sum[:])
+}
+
+// renderRegion renders the partial with id from p's tree on its own, without
+// out-of-band regions.
+func renderRegion(ctx context.Context, r *http.Request, p *Partial, id string) (template.HTML, error) {
+	region := p
+	if id != p.id {
+		region = p.recursiveChildLookup(id, make(map[string]bool))
+	}
+	if region == nil {
+		return "", &TargetNotFoundError{ID: id, Parent: p.id}
+	}
+	if err := region.checkRequiredHeaders(r); err != nil {
+		return "", err
+	}
+	result := renderSelfResult(ctx, r, region)
+	if result.Err != nil {
+		return "", fmt.Errorf("error rendering region '%s': %w", id, result.Err)
+	}
+	return result.HTML, nil
+}
+
+// RenderWithHeaders renders p like RenderWithRequest and also returns the
+// response headers Write would send, without writing anything. Callers such
+// as middleware can inspect or change the headers before writing the
+// response themselves.
+func RenderWithHeaders(ctx context.Context, r *http.Request, p *Partial) (http.Header, template.HTML, error) {
+	p = p.withDetectedConnector(r)
+	result := renderWithRequestResult(ctx, r, p)
+	if result.Err != nil {
+		return nil, "", result.Err
+	}
+	return collectResponseHeaders(p, result), result.HTML, nil
+}
+
+// RenderRegions renders each partial ID in p's tree on its own and returns the
+// HTML keyed by ID. Out-of-band regions are not appended to any entry, so
+// clients that patch several regions receive each one exactly once.
+func RenderRegions(ctx context.Context, r *http.Request, p *Partial, ids ...string) (map[string]template.HTML, error) {
+	if p == nil {
+		return nil, ErrPartialNotInitialized
+	}
+
+	ctx = withRenderMemo(ctx)
+	regions := make(map[string]template.HTML, len(ids))
+	for _, id := range ids {
+		if _, ok := regions[id]; ok {
+			continue
+		}
+		html, err := renderRegion(ctx, r, p, id)
+		if err != nil {
+			return nil, err
+		}
+		regions[id] = html
+	}
+	return regions, nil
+}
+
 func renderWithRequestResult(ctx context.Context, r *http.Request, p *Partial) renderResult {
 	if p == nil {
-		return renderResult{Err: errors.New("partial is not initialized")}
+		return renderResult{Err: ErrPartialNotInitialized}
 	}
+	p = p.withDetectedConnector(r)
+	timeout := p.getRenderTimeout()
+	ctx, cancel := withRenderTimeout(ctx, r, timeout)
+	defer cancel()
+
+	return renderTimeoutResult(ctx, p, timeout, renderRequestResult(withRenderMemo(ctx), r, p))
+}
 
+func renderRequestResult(ctx context.Context, r *http.Request, p *Partial) renderResult {
+	target := p.requestTargetID(r)
 	if p.getConnectorOrDefault().RenderPartial(r) {
-		return renderWithTargetResult(ctx, r, p)
+		return renderWithTargetResult(ctx, r, p, target)
+	}
+	if target != "" {
+		// A plain request gets the default target as its whole response,
+		// without the out-of-band regions of a partial response.
+		return renderWithTargetResult(WithoutOOB(ctx), r, p, target)
 	}
 
-	return renderSelfResult(ctx, r, p)
+	if err := p.checkRequiredHeaders(r); err != nil {
+		return renderResult{Err: err}
+	}
+	return renderSelfResult(withRequestTarget(ctx, p), r, p)
+}
+
+// requestTargetID returns the partial ID r asks p to render: the connector's
+// target value on partial requests, or else the default target. It is empty
+// when p itself is rendered, which history restore requests always get.
+func (p *Partial) requestTargetID(r *http.Request) string {
+	p.mu.RLock()
+	defaultTarget := p.defaultTarget
+	p.mu.RUnlock()
+
+	conn := p.getConnectorOrDefault()
+	if conn.RenderPartial(r) {
+		if target := conn.GetTargetValue(r); target != "" {
+			return target
+		}
+	}
+	if isHistoryRestoreRequest(r) {
+		return ""
+	}
+	return defaultTarget
+}
+
+// isHistoryRestoreRequest reports whether r asks for a full page to restore
+// browser history, as htmx does on a cache miss.
+func isHistoryRestoreRequest(r *http.Request) bool {
+	return r != nil && r.Header.Get(connector.HTMXHeaderHistoryRestoreRequest.String()) == "true"
+}
+
+// requestLastModified returns the last-modified time of the partial r
+// resolves to in p's tree. Targets that only a render stage can resolve report
+// no time, so they are never answered with 304 before rendering.
+func (p *Partial) requestLastModified(r *http.Request) (time.Time, bool) {
+	if r == nil {
+		return time.Time{}, false
+	}
+	target := p
+	if id := p.requestTargetID(r); id != "" && id != p.id && !p.hasAlias(id) {
+		target = p.recursiveChildLookup(id, make(map[string]bool))
+	}
+	return target.lastModifiedAt(r)
 }
 
 // Write renders a partial and writes the HTTP response.
 //
 // Write owns the response side of rendering: configured response headers,
 // connector response headers, render-stage response metadata, error fragments,
-// and out-of-band regions are applied here.
+// and out-of-band regions are applied here. When a handler is configured with
+// Partial.OnError, render failures are passed to it and Write returns nil. A
+// render that fails with ErrNoContent is answered with status 204, and a
+// partial configured with SetLastModified may answer 304 without rendering.
 func Write(ctx context.Context, w http.ResponseWriter, r *http.Request, p *Partial) error {
 	if w == nil {
 		return errors.New("response writer is not configured")
 	}
 	if p == nil {
-		_, err := fmt.Fprint(w, "partial is not initialized")
+		_, err := fmt.Fprint(w, ErrPartialNotInitialized.Error())
 		return err
 	}
 
+	p = p.withDetectedConnector(r)
+	modified, hasModified := p.requestLastModified(r)
+	if hasModified && writeNotModified(w, r, modified) {
+		return nil
+	}
+
 	result := renderWithRequestResult(ctx, r, p)
+	return writeRenderResult(ctx, w, r, p, result, modified, hasModified)
+}
+
+// writeNotModified answers a conditional request with 304 Not Modified when
+// the client's copy is not older than modified, and reports whether it did.
+func writeNotModified(w http.ResponseWriter, r *http.Request, modified time.Time) bool {
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.After(since) {
+		return false
+	}
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// writeRenderResult writes a complete render result, or the failure response
+// for its error, to w.
+func writeRenderResult(ctx context.Context, w http.ResponseWriter, r *http.Request, p *Partial, result renderResult, modified time.Time, hasModified bool) error {
+	if errors.Is(result.Err, ErrNoContent) {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
 	if result.Err != nil {
 		p.emitWithContext(ctx, r, Event{
 			Kind:    EventRenderError,
@@ -66,20 +576,19 @@ func Write(ctx context.Context, w http.ResponseWriter, r *http.Request, p *Parti
 			Message: "error rendering partial",
 			Error:   result.Err,
 		})
+		if handler := p.getErrorHandler(); handler != nil {
+			handler(w, r, result.Err)
+			return nil
+		}
 		return writeRenderFailure(ctx, w, r, p, result.Err)
 	}
 
-	headers := result.Headers
-	if headers == nil {
-		headers = p.getResponseHeaders()
+	for k, v := range collectResponseHeaders(p, result) {
+		w.Header()[k] = v
 	}
-	for k, v := range headers {
-		w.Header().Set(k, v)
+	if hasModified {
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
 	}
-	for k, v := range p.getConnectorResponseHeaders() {
-		w.Header().Set(k, v)
-	}
-	applyRenderResponseHeaders(w, result.Response)
 	if result.Response != nil && result.Response.Status > 0 {
 		w.WriteHeader(result.Response.Status)
 	}
@@ -98,6 +607,55 @@ func Write(ctx context.Context, w http.ResponseWriter, r *http.Request, p *Parti
 	return nil
 }
 
+// collectResponseHeaders returns the headers Write sends for a successful
+// render, in order of precedence: content type, configured response headers,
+// the rendered partial's Cache-Control and the Vary that goes with it,
+// connector response instructions including the delete swap of
+// SetDeleteOnEmpty and the no-op swap of SetOOBOnly, and render-stage
+// response headers.
+func collectResponseHeaders(p *Partial, result renderResult) http.Header {
+	header := make(http.Header)
+	rendered := p
+	if result.Partial != nil {
+		rendered = result.Partial
+	}
+	header.Set("Content-Type", rendered.getContentType())
+
+	headers := result.Headers
+	if headers == nil {
+		headers = p.getResponseHeaders()
+	}
+	for k, v := range headers {
+		header.Set(k, v)
+	}
+	if cacheControl := rendered.getCacheControl(); cacheControl != "" {
+		header.Set("Cache-Control", cacheControl)
+		header.Set("Vary", strings.Join(p.varyHeaders(), ", "))
+	}
+	for k, v := range p.getConnectorResponseHeaders() {
+		header.Set(k, v)
+	}
+	// The rendered target or a partial returned by a stage may decide on
+	// response instructions such as a retarget while rendering.
+	if rendered != p {
+		for k, v := range rendered.getConnectorResponseHeaders() {
+			header.Set(k, v)
+		}
+	}
+	for k, v := range rendered.emptyResponseHeaders(result.Empty) {
+		header.Set(k, v)
+	}
+	for k, v := range rendered.oobOnlyResponseHeaders() {
+		header.Set(k, v)
+	}
+	if result.Response != nil {
+		for k, v := range result.Response.Headers {
+			header.Set(k, v)
+		}
+	}
+	return header
+}
+
 func writeRenderFailure(ctx context.Context, w http.ResponseWriter, r *http.Request, p *Partial, renderErr error) error {
 	isPartialRequest := p.isPartialRequest(r)
 	result := renderErrorResult(ctx, r, p, renderErr, isPartialRequest)
@@ -108,8 +666,8 @@ func writeRenderFailure(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		return fmt.Errorf("error rendering failure response: %w; original render error: %v", result.Err, renderErr)
 	}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	status := http.StatusInternalServerError
+	w.Header().Set("Content-Type", defaultContentType)
+	status := ErrorStatus(renderErr)
 	if isPartialRequest {
 		oobOut, oobErr := renderAllAncestorOOBChildren(ctx, r, p, true)
 		if oobErr != nil {
@@ -121,8 +679,10 @@ func writeRenderFailure(ctx context.Context, w http.ResponseWriter, r *http.Requ
 			})
 			return fmt.Errorf("error rendering OOB regions for failure response: %w; original render error: %v", oobErr, renderErr)
 		}
-		result.HTML += oobOut
-		status = http.StatusOK
+		result.HTML = p.placeOOB(result.HTML, oobOut)
+		if status == http.StatusInternalServerError {
+			status = http.StatusOK
+		}
 	}
 	applyRenderResponseHeaders(w, result.Response)
 	if result.Response != nil && result.Response.Status > 0 {
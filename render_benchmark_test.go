@@ -1,8 +1,10 @@
 package partial
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"html/template"
 	"net/http"
 	"testing"
 
@@ -40,6 +42,35 @@ func BenchmarkRenderWithRequestSimpleWithCache(b *testing.B) {
 	benchmarkRenderWithRequestSimple(b, true)
 }
 
+func BenchmarkFastRenderSimple(b *testing.B) {
+	tmpl := template.Must(template.ParseFS(benchmarkFS(), "templates/simple.gohtml"))
+	data := map[string]any{
+		"Title": "Benchmark",
+		"Body":  "A small direct render.",
+	}
+	var buf bytes.Buffer
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for b.Loop() {
+		buf.Reset()
+		if err := FastRender(tmpl, "", data, &buf); err != nil {
+			b.Fatal(err)
+		}
+		if buf.Len() == 0 {
+			b.Fatal("empty render output")
+		}
+	}
+}
+
+func BenchmarkRenderDeepTreeNoCache(b *testing.B) {
+	benchmarkRenderDeepTree(b, false)
+}
+
+func BenchmarkRenderDeepTreeWithCache(b *testing.B) {
+	benchmarkRenderDeepTree(b, true)
+}
+
 func benchmarkRenderWithRequestSimple(b *testing.B, useCache bool) {
 	partial := NewID("content", "templates/simple.gohtml").
 		SetFileSystem(benchmarkFS()).
@@ -122,6 +153,49 @@ func benchmarkRenderPerRequestShell(b *testing.B, useCache bool) {
 	}
 }
 
+// benchmarkRenderDeepTree renders a target nested eight levels below a root
+// that carries the template helper functions, so function maps are inherited
+// through the whole chain on every render.
+func benchmarkRenderDeepTree(b *testing.B, useCache bool) {
+	fsys := &inMemoryFS{Files: map[string]string{
+		"templates/level.gohtml": `<div>{{ content }}</div>`,
+		"templates/leaf.gohtml":  `<p>{{ upper .Name }}</p>`,
+	}}
+	root := NewID("level-0", "templates/level.gohtml").
+		SetFileSystem(fsys).
+		UseTemplateCache(useCache).
+		SetFunc(templatehelpers.FuncMap())
+	parent := root
+	for i := 1; i < 8; i++ {
+		level := NewID(fmt.Sprintf("level-%d", i), "templates/level.gohtml").UseTemplateCache(useCache)
+		parent.SetContent(level)
+		parent = level
+	}
+	parent.SetContent(NewID("leaf", "templates/leaf.gohtml").
+		UseTemplateCache(useCache).
+		SetDot(map[string]any{"Name": "leaf"}))
+
+	request := benchmarkRequest()
+	request.Header.Set("X-Target", "leaf")
+	ctx := context.Background()
+
+	if _, err := RenderWithRequest(ctx, request, root); err != nil {
+		b.Fatalf("prime render: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for b.Loop() {
+		out, err := RenderWithRequest(ctx, request, root)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(out) == 0 {
+			b.Fatal("empty render output")
+		}
+	}
+}
+
 func benchmarkContentPartial() *Partial {
 	row := NewID("row", "templates/row.gohtml")
 	return NewID("content", "templates/content.gohtml").
@@ -1,13 +1,20 @@
 package partial
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"html/template"
+	"maps"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/donseba/go-partial/connector"
 )
@@ -231,6 +238,337 @@ func TestWriteAppliesFluentConnectorResponse(t *testing.T) {
 	}
 }
 
+func TestWriteAppliesRetargetFromRenderedTarget(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("list.gohtml", `<ul id="list">{{ content }}</ul>`)
+	fsys.AddFile("more.gohtml", `<li>Never gonna give you up</li>`)
+
+	list := NewID("list", "list.gohtml").
+		SetFileSystem(fsys).
+		SetConnector(connector.NewHTMX(nil))
+	more := NewID("more", "more.gohtml")
+	more.Response().
+		Retarget("#list").
+		Reswap("innerHTML")
+	list.SetContent(more)
+
+	req := httptest.NewRequest(http.MethodGet, "/items?page=2", nil)
+	req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+	req.Header.Set(connector.HTMXHeaderTarget.String(), "more")
+	rec := httptest.NewRecorder()
+
+	if err := Write(context.Background(), rec, req, list); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got := rec.Header().Get(connector.HTMXHeaderRetarget.String()); got != "#list" {
+		t.Fatalf("HX-Retarget = %q, want %q", got, "#list")
+	}
+	if got := rec.Header().Get(connector.HTMXHeaderReswap.String()); got != "innerHTML" {
+		t.Fatalf("HX-Reswap = %q, want %q", got, "innerHTML")
+	}
+	if body := rec.Body.String(); body != `<li>Never gonna give you up</li>` {
+		t.Fatalf("body = %q", body)
+	}
+}
+
+func TestRenderWithHeadersReturnsHeadersAndBodyWithoutWriting(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("notice.gohtml", `<div id="notice">Saved</div>`)
+
+	p := NewID("notice", "notice.gohtml").
+		SetFileSystem(fsys).
+		SetConnector(connector.NewHTMX(nil)).
+		SetResponseHeaders(map[string]string{"Cache-Control": "no-store"})
+	p.Response().TriggerWith(connector.NewTrigger().AddEvent("saved"))
+
+	req := httptest.NewRequest(http.MethodGet, "/notice", nil)
+	headers, body, err := RenderWithHeaders(context.Background(), req, p)
+	if err != nil {
+		t.Fatalf("RenderWithHeaders() error = %v", err)
+	}
+	if body != `<div id="notice">Saved</div>` {
+		t.Fatalf("body = %q", body)
+	}
+
+	expected := map[string]string{
+		"Content-Type":                       "text/html; charset=utf-8",
+		"Cache-Control":                      "no-store",
+		connector.HTMXHeaderTrigger.String(): `{"saved":null}`,
+	}
+	for key, want := range expected {
+		if got := headers.Get(key); got != want {
+			t.Fatalf("header %s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestSetCacheControlAppliesToRenderedTarget(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ render "nav" }}{{ content }}</main>`)
+	fsys.AddFile("nav.gohtml", `<nav id="nav">Home</nav>`)
+	fsys.AddFile("content.gohtml", `<div id="content">content</div>`)
+
+	shell := NewID("shell", "shell.gohtml").SetFileSystem(fsys)
+	shell.With(NewID("nav", "nav.gohtml").SetCacheControl("public, max-age=60"))
+	shell.SetContent(NewID("content", "content.gohtml"))
+
+	for target, want := range map[string]string{"nav": "public, max-age=60", "content": "", "": ""} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if target != "" {
+			req.Header.Set(connector.HeaderTarget.String(), target)
+		}
+		rec := httptest.NewRecorder()
+		if err := Write(context.Background(), rec, req, shell); err != nil {
+			t.Fatalf("Write(%q) error = %v", target, err)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != want {
+			t.Fatalf("Write(%q) Cache-Control = %q, want %q", target, got, want)
+		}
+	}
+}
+
+func TestSetCacheControlVariesOnConnectorHeaders(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("nav.gohtml", `<nav id="nav">Home</nav>`)
+
+	shell := NewID("shell", "shell.gohtml").
+		SetFileSystem(fsys).
+		SetConnectors(connector.NewHTMX(nil), connector.NewTurbo(nil)).
+		SetCacheControl("public, max-age=60")
+	shell.SetContent(NewID("nav", "nav.gohtml").SetCacheControl("public, max-age=60"))
+
+	// The full page must vary on every connector's headers. A fragment only
+	// needs the headers that set it apart, which include those of its own
+	// connector.
+	for target, want := range map[string]string{
+		"":    "Hx-Boosted, Hx-History-Restore-Request, Hx-Request, Hx-Target, Turbo-Action, Turbo-Frame, Turbo-Select, X-Action, X-Select, X-Target",
+		"nav": "Hx-Boosted, Hx-History-Restore-Request, Hx-Request, Hx-Target, Turbo-Action, Turbo-Frame, Turbo-Select, X-Action, X-Select",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if target != "" {
+			req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+			req.Header.Set(connector.HTMXHeaderTarget.String(), target)
+		}
+		rec := httptest.NewRecorder()
+		if err := Write(context.Background(), rec, req, shell); err != nil {
+			t.Fatalf("Write(%q) error = %v", target, err)
+		}
+		if got := rec.Header().Get("Vary"); got != want {
+			t.Fatalf("Write(%q) Vary = %q, want %q", target, got, want)
+		}
+	}
+
+	shell.SetCacheControl("")
+	rec := httptest.NewRecorder()
+	if err := Write(context.Background(), rec, httptest.NewRequest(http.MethodGet, "/", nil), shell); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got := rec.Header().Get("Vary"); got != "" {
+		t.Fatalf("Vary without Cache-Control = %q, want none", got)
+	}
+}
+
+func TestSetLastModifiedAnswersNotModified(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("article.gohtml", `<article>{{ .Title }}</article>`)
+
+	updated := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	article := NewID("article", "article.gohtml").
+		SetFileSystem(fsys).
+		SetDot(map[string]any{"Title": "News", "UpdatedAt": updated}).
+		SetLastModified(func(r *http.Request, dot any) time.Time {
+			return dot.(map[string]any)["UpdatedAt"].(time.Time)
+		})
+
+	tests := []struct {
+		name   string
+		since  time.Time
+		status int
+		body   string
+	}{
+		{name: "no validator", status: http.StatusOK, body: "<article>News</article>"},
+		{name: "client is newer", since: updated.Add(time.Hour), status: http.StatusNotModified},
+		{name: "client is current", since: updated, status: http.StatusNotModified},
+		{name: "client is stale", since: updated.Add(-time.Hour), status: http.StatusOK, body: "<article>News</article>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/article", nil)
+			if !tt.since.IsZero() {
+				req.Header.Set("If-Modified-Since", tt.since.Format(http.TimeFormat))
+			}
+			rec := httptest.NewRecorder()
+			if err := Write(context.Background(), rec, req, article); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if rec.Code != tt.status || rec.Body.String() != tt.body {
+				t.Fatalf("response = %d %q, want %d %q", rec.Code, rec.Body.String(), tt.status, tt.body)
+			}
+			if got := rec.Header().Get("Last-Modified"); got != updated.Format(http.TimeFormat) {
+				t.Fatalf("Last-Modified = %q", got)
+			}
+		})
+	}
+}
+
+func TestSetLastModifiedUsesTheRequestedTarget(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("feed.gohtml", `<ul id="feed"></ul>`)
+
+	pageUpdated := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	feedUpdated := pageUpdated.Add(time.Hour)
+	shell := NewID("shell", "shell.gohtml").
+		SetFileSystem(fsys).
+		SetConnector(connector.NewHTMX(nil)).
+		SetLastModified(func(r *http.Request, dot any) time.Time { return pageUpdated })
+	shell.SetContent(NewID("feed", "feed.gohtml").
+		SetLastModified(func(r *http.Request, dot any) time.Time { return feedUpdated }))
+
+	since := pageUpdated.Add(time.Minute).Format(http.TimeFormat)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-Modified-Since", since)
+	rec := httptest.NewRecorder()
+	if err := Write(context.Background(), rec, req, shell); err != nil {
+		t.Fatalf("Write() page error = %v", err)
+	}
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("page status = %d, want %d", rec.Code, http.StatusNotModified)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-Modified-Since", since)
+	req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+	req.Header.Set(connector.HTMXHeaderTarget.String(), "feed")
+	rec = httptest.NewRecorder()
+	if err := Write(context.Background(), rec, req, shell); err != nil {
+		t.Fatalf("Write() target error = %v", err)
+	}
+	if rec.Code != http.StatusOK || rec.Body.String() != `<ul id="feed"></ul>` {
+		t.Fatalf("target response = %d %q, want the fresh fragment", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Last-Modified"); got != feedUpdated.Format(http.TimeFormat) {
+		t.Fatalf("target Last-Modified = %q, want %q", got, feedUpdated.Format(http.TimeFormat))
+	}
+}
+
+func TestSetDeleteOnEmptyRequestsDeleteSwap(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("list.gohtml", `<ul>{{ template "item.gohtml" . }}</ul>`)
+	fsys.AddFile("item.gohtml", `{{ if .Visible }}<li id="item">Item</li>{{ end }}`)
+
+	for _, visible := range []bool{false, true} {
+		list := NewID("list", "list.gohtml").
+			SetFileSystem(fsys).
+			SetConnector(connector.NewHTMX(nil))
+		list.With(NewID("item", "item.gohtml").
+			SetDot(map[string]bool{"Visible": visible}).
+			SetDeleteOnEmpty(true))
+
+		req := httptest.NewRequest(http.MethodPost, "/items/1/archive", nil)
+		req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+		req.Header.Set(connector.HTMXHeaderTarget.String(), "item")
+		rec := httptest.NewRecorder()
+		if err := Write(context.Background(), rec, req, list); err != nil {
+			t.Fatalf("Write(visible=%v) error = %v", visible, err)
+		}
+
+		want := ""
+		if !visible {
+			want = "delete"
+		}
+		if got := rec.Header().Get(connector.HTMXHeaderReswap.String()); got != want {
+			t.Fatalf("Write(visible=%v) HX-Reswap = %q, want %q", visible, got, want)
+		}
+	}
+}
+
+func TestSetDeleteOnEmptyIgnoresOOBRegions(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("list.gohtml", `<ul>{{ render "item" }}</ul>`)
+	fsys.AddFile("item.gohtml", ``)
+	fsys.AddFile("count.gohtml", `<span id="count">0 items</span>`)
+
+	list := NewID("list", "list.gohtml").
+		SetFileSystem(fsys).
+		SetConnector(connector.NewHTMX(nil)).
+		SetFragmentMarkers(true)
+	list.With(NewID("item", "item.gohtml").SetDeleteOnEmpty(true))
+	list.WithOOB(NewID("count", "count.gohtml"))
+
+	req := httptest.NewRequest(http.MethodPost, "/items/1/archive", nil)
+	req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+	req.Header.Set(connector.HTMXHeaderTarget.String(), "item")
+	rec := httptest.NewRecorder()
+	if err := Write(context.Background(), rec, req, list); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if !strings.Contains(rec.Body.String(), `<span hx-swap-oob="true" id="count">0 items</span>`) {
+		t.Fatalf("body = %q, want the OOB count", rec.Body.String())
+	}
+	if got := rec.Header().Get(connector.HTMXHeaderReswap.String()); got != "delete" {
+		t.Fatalf("HX-Reswap = %q, want %q", got, "delete")
+	}
+}
+
+func TestNewGroupRendersMembersByGroupID(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("wizard.gohtml", `<form>{{ render "step" }}</form>`)
+	fsys.AddFile("fields.gohtml", `<fieldset id="fields">{{ .Step }}</fieldset>`)
+	fsys.AddFile("actions.gohtml", `<nav id="actions">next</nav>`)
+
+	wizard := NewID("wizard", "wizard.gohtml").
+		SetFileSystem(fsys).
+		SetConnector(connector.NewHTMX(nil))
+	wizard.With(NewGroup("step",
+		NewID("fields", "fields.gohtml").SetDot(map[string]int{"Step": 2}),
+		NewID("actions", "actions.gohtml"),
+	))
+
+	req := httptest.NewRequest(http.MethodGet, "/wizard", nil)
+	req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+	req.Header.Set(connector.HTMXHeaderTarget.String(), "step")
+	out, err := RenderWithRequest(context.Background(), req, wizard)
+	if err != nil {
+		t.Fatalf("RenderWithRequest() error = %v", err)
+	}
+	if got, want := string(out), `<fieldset id="fields">2</fieldset><nav id="actions">next</nav>`; got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+
+	out, err = Render(context.Background(), wizard)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if got, want := string(out), `<form><fieldset id="fields">2</fieldset><nav id="actions">next</nav></form>`; got != want {
+		t.Fatalf("page output = %q, want %q", got, want)
+	}
+}
+
+func TestNewGroupRejectsMembersThatCannotBeAddressed(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("member.gohtml", `member`)
+
+	tests := []struct {
+		name    string
+		members []*Partial
+	}{
+		{name: "empty ID", members: []*Partial{NewID("", "member.gohtml")}},
+		{name: "duplicate ID", members: []*Partial{NewID("a", "member.gohtml"), NewID("a", "member.gohtml")}},
+		{name: "group ID", members: []*Partial{NewID("step", "member.gohtml")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := NewGroup("step", tt.members...).SetFileSystem(fsys)
+			if _, err := Render(context.Background(), group); !errors.Is(err, ErrInvalidGroup) {
+				t.Fatalf("Render() error = %v, want ErrInvalidGroup", err)
+			}
+		})
+	}
+}
+
 func TestWriteAppliesStructConnectorResponse(t *testing.T) {
 	fsys := &inMemoryFS{}
 	fsys.AddFile("notice.gohtml", `<div id="notice">Saved</div>`)
@@ -334,6 +672,107 @@ func TestPackageRenderWithRequestRendersTargetAndOOB(t *testing.T) {
 	}
 }
 
+func TestSetFragmentMarkersSurroundEachFragment(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("page.gohtml", `<main>{{ template "content.gohtml" . }}</main>`)
+	fsys.AddFile("content.gohtml", `<section id="content">Content</section>`)
+	fsys.AddFile("notice.gohtml", `<aside id="notice">Notice</aside>`)
+
+	page := NewID("page", "page.gohtml").
+		SetFileSystem(fsys).
+		SetFragmentMarkers(true)
+	page.With(NewID("content", "content.gohtml"))
+	page.WithOOB(NewID("notice", "notice.gohtml").SetAlwaysSwapOOB(true))
+
+	req := httptest.NewRequest(http.MethodGet, "/page", nil)
+	req.Header.Set(connector.HeaderTarget.String(), "content")
+
+	out, err := RenderWithRequest(context.Background(), req, page)
+	if err != nil {
+		t.Fatalf("RenderWithRequest() error = %v", err)
+	}
+	want := `<!--partial:content--><section id="content">Content</section><!--/partial:content-->` +
+		`<!--partial:notice--><aside id="notice">Notice</aside><!--/partial:notice-->`
+	if string(out) != want {
+		t.Fatalf("RenderWithRequest() = %q, want %q", out, want)
+	}
+}
+
+func TestSetValidateSwapTargetsWarnsOnMissingID(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("page.gohtml", `<main>{{ template "content.gohtml" . }}</main>`)
+	fsys.AddFile("content.gohtml", `<section class="content">Content</section>`)
+	fsys.AddFile("notice.gohtml", `<aside id='notice'>Notice</aside>`)
+
+	var invalid []Event
+	page := NewID("page", "page.gohtml").
+		SetFileSystem(fsys).
+		SetConnector(connector.NewHTMX(nil)).
+		SetValidateSwapTargets(true).
+		SetEvents(EventSinkFunc(func(ctx *RenderContext, event Event) {
+			if event.Kind == EventSwapTargetInvalid {
+				invalid = append(invalid, event)
+			}
+		}))
+	page.With(NewID("content", "content.gohtml"))
+	page.WithOOB(NewID("notice", "notice.gohtml").SetAlwaysSwapOOB(true))
+
+	req := httptest.NewRequest(http.MethodGet, "/page", nil)
+	req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+	req.Header.Set(connector.HTMXHeaderTarget.String(), "content")
+
+	out, err := RenderWithRequest(context.Background(), req, page)
+	if err != nil {
+		t.Fatalf("RenderWithRequest() error = %v", err)
+	}
+	if !strings.Contains(string(out), `<section class="content">Content</section>`) {
+		t.Fatalf("expected target to render despite the warning, got %q", out)
+	}
+	if len(invalid) != 1 || invalid[0].Fields["target"] != "content" || invalid[0].Level != EventWarn {
+		t.Fatalf("swap target events = %+v, want one warning for content", invalid)
+	}
+}
+
+func TestSetConnectorsDetectsConnectorPerRequest(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("page.gohtml", `<main>{{ template "content.gohtml" . }}</main>`)
+	fsys.AddFile("content.gohtml", `<section id="content">Content</section>`)
+	fsys.AddFile("notice.gohtml", `<aside id="notice">Notice</aside>`)
+
+	page := NewID("page", "page.gohtml").
+		SetFileSystem(fsys).
+		SetConnectors(connector.NewHTMX(nil), connector.NewTurbo(nil))
+	page.With(NewID("content", "content.gohtml"))
+	page.WithOOB(NewID("notice", "notice.gohtml").SetAlwaysSwapOOB(true))
+
+	htmxReq := httptest.NewRequest(http.MethodGet, "/page", nil)
+	htmxReq.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+	htmxReq.Header.Set(connector.HTMXHeaderTarget.String(), "content")
+
+	turboReq := httptest.NewRequest(http.MethodGet, "/page", nil)
+	turboReq.Header.Set(connector.TurboHeaderTarget.String(), "content")
+
+	for name, tc := range map[string]struct {
+		req  *http.Request
+		want string
+	}{
+		"htmx":  {req: htmxReq, want: `<section id="content">Content</section><aside hx-swap-oob="true" id="notice">Notice</aside>`},
+		"turbo": {req: turboReq, want: `<section id="content">Content</section><aside id="notice">Notice</aside>`},
+		"plain": {req: httptest.NewRequest(http.MethodGet, "/page", nil), want: `<main><section id="content">Content</section></main>`},
+	} {
+		rec := httptest.NewRecorder()
+		if err := Write(context.Background(), rec, tc.req, page); err != nil {
+			t.Fatalf("%s: Write() error = %v", name, err)
+		}
+		if body := rec.Body.String(); body != tc.want {
+			t.Fatalf("%s: body = %q, want %q", name, body, tc.want)
+		}
+	}
+	if page.getConnector() != nil {
+		t.Fatalf("detection changed the configured tree")
+	}
+}
+
 func TestPackageWriteAppliesResponseBehavior(t *testing.T) {
 	fsys := &inMemoryFS{}
 	fsys.AddFile("page.gohtml", `ok`)
@@ -374,6 +813,53 @@ func TestPackageWriteAppliesResponseBehavior(t *testing.T) {
 	}
 }
 
+func TestWriteSetsContentType(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("content.gohtml", `content`)
+
+	tests := []struct {
+		name    string
+		target  string
+		shell   func(*Partial)
+		content func(*Partial)
+		want    string
+	}{
+		{name: "default", want: "text/html; charset=utf-8"},
+		{name: "inherited by target", target: "content", shell: func(p *Partial) { p.SetContentType("text/plain; charset=utf-8") }, want: "text/plain; charset=utf-8"},
+		{name: "target overrides", target: "content", shell: func(p *Partial) { p.SetContentType("text/plain") }, content: func(p *Partial) { p.SetContentType("application/atom+xml") }, want: "application/atom+xml"},
+		{name: "response header wins", shell: func(p *Partial) {
+			p.SetContentType("text/plain").SetResponseHeaders(map[string]string{"Content-Type": "application/xhtml+xml"})
+		}, want: "application/xhtml+xml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shell := NewID("shell", "shell.gohtml").SetFileSystem(fsys)
+			content := NewID("content", "content.gohtml")
+			shell.SetContent(content)
+			if tt.shell != nil {
+				tt.shell(shell)
+			}
+			if tt.content != nil {
+				tt.content(content)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.target != "" {
+				req.Header.Set(connector.HeaderTarget.String(), tt.target)
+			}
+			rec := httptest.NewRecorder()
+			if err := Write(context.Background(), rec, req, shell); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.want {
+				t.Fatalf("Content-Type = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestTargetResolverRendersDynamicRowTarget(t *testing.T) {
 	type row struct {
 		ID   int
@@ -425,6 +911,1032 @@ func TestTargetResolverRendersDynamicRowTarget(t *testing.T) {
 	}
 }
 
+func TestWriteCallsErrorHandlerOnRenderError(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("broken.gohtml", `{{ if .Missing }}missing`)
+
+	var handled error
+	root := New().SetFileSystem(fsys).OnError(func(w http.ResponseWriter, r *http.Request, err error) {
+		handled = err
+		http.Error(w, "custom failure", http.StatusInternalServerError)
+	})
+	content := NewID("content", "broken.gohtml").Use(testErrorStage(true))
+	root.SetContent(content)
+
+	req := httptest.NewRequest(http.MethodGet, "/broken", nil)
+	rec := httptest.NewRecorder()
+
+	if err := Write(context.Background(), rec, req, content); err != nil {
+		t.Fatalf("expected handled error, got %v", err)
+	}
+	if handled == nil || !strings.Contains(handled.Error(), "unexpected EOF") {
+		t.Fatalf("expected handler to receive render error, got %v", handled)
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != "custom failure" {
+		t.Fatalf("expected handler response, got %q", body)
+	}
+}
+
+func TestWriteRendersNotFoundPartialForUnknownTarget(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("content.gohtml", `<div id="content">content</div>`)
+	fsys.AddFile("missing.gohtml", `<div id="missing">Nothing here</div>`)
+
+	shell := NewID("shell", "shell.gohtml").
+		SetFileSystem(fsys).
+		SetNotFound(NewID("missing", "missing.gohtml"))
+	shell.SetContent(NewID("content", "content.gohtml"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(connector.HeaderTarget.String(), "nonexistent")
+	rec := httptest.NewRecorder()
+
+	if err := Write(context.Background(), rec, req, shell); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if body := rec.Body.String(); body != `<div id="missing">Nothing here</div>` {
+		t.Fatalf("body = %q", body)
+	}
+}
+
+func TestRequestedTargetResolvesAlias(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("content.gohtml", `<div id="cart">{{ render "summary" }}</div>`)
+	fsys.AddFile("summary.gohtml", `<p>2 items</p>`)
+
+	shell := NewID("shell", "shell.gohtml").SetFileSystem(fsys)
+	content := NewID("cart", "content.gohtml").AddAlias("basket", "shopping-cart")
+	content.With(NewID("cart-summary", "summary.gohtml").AddAlias("summary"))
+	shell.SetContent(content)
+
+	for _, target := range []string{"cart", "basket", "shopping-cart"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(connector.HeaderTarget.String(), target)
+		out, err := RenderWithRequest(context.Background(), req, shell)
+		if err != nil {
+			t.Fatalf("RenderWithRequest(%s) error = %v", target, err)
+		}
+		if out != `<div id="cart"><p>2 items</p></div>` {
+			t.Fatalf("RenderWithRequest(%s) = %q", target, out)
+		}
+	}
+}
+
+func TestSetRecoverPanicsTurnsPanicsIntoErrors(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("content.gohtml", `content`)
+
+	shell := NewID("shell", "shell.gohtml").
+		SetFileSystem(fsys).
+		SetRecoverPanics(true).
+		Use(RenderStageHooks{PrepareFunc: func(ctx *RenderContext) (*RenderContext, error) {
+			if ctx.Kind == RenderKindPartial && ctx.Partial.PartialID() == "content" {
+				panic("action failed")
+			}
+			return ctx, nil
+		}})
+	shell.SetContent(NewID("content", "content.gohtml"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(connector.HeaderTarget.String(), "content")
+	_, err := RenderWithRequest(context.Background(), req, shell)
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("RenderWithRequest() error = %v, want PanicError", err)
+	}
+	if panicErr.ID != "content" || panicErr.Value != "action failed" || len(panicErr.Stack) == 0 {
+		t.Fatalf("PanicError = %+v", panicErr)
+	}
+
+	out, err := Render(context.Background(), shell)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if !strings.Contains(string(out), "panic rendering partial content: action failed") {
+		t.Fatalf("Render() = %q, want the child error in place", out)
+	}
+
+	// text/template already recovers panics in template funcs, so use a dot
+	// transform, which only SetRecoverPanics guards.
+	transformed := func(recoverPanics bool) *Partial {
+		return NewID("transformed", "content.gohtml").
+			SetFileSystem(fsys).
+			SetRecoverPanics(recoverPanics).
+			SetDotTransform(func(*RenderContext, any) (any, error) { panic("boom") })
+	}
+	_, err = Render(context.Background(), transformed(true))
+	if !errors.As(err, &panicErr) || panicErr.ID != "transformed" || panicErr.Value != "boom" {
+		t.Fatalf("Render() error = %v, want PanicError from the dot transform", err)
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != "boom" {
+			t.Fatalf("recovered %v, want the dot transform panic without SetRecoverPanics", recovered)
+		}
+	}()
+	_, _ = Render(context.Background(), transformed(false))
+	t.Fatal("Render() without SetRecoverPanics returned instead of panicking")
+}
+
+func TestSetRenderTimeoutStopsSlowAction(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("content.gohtml", `content`)
+
+	var handled error
+	shell := NewID("shell", "shell.gohtml").
+		SetFileSystem(fsys).
+		SetRenderTimeout(20 * time.Millisecond).
+		OnError(func(w http.ResponseWriter, r *http.Request, err error) {
+			handled = err
+			http.Error(w, "timed out", http.StatusGatewayTimeout)
+		})
+	content := NewID("content", "content.gohtml").Use(RenderStageHooks{PrepareFunc: func(ctx *RenderContext) (*RenderContext, error) {
+		if ctx.Kind != RenderKindPartial {
+			return ctx, nil
+		}
+		select {
+		case <-ctx.Context.Done():
+			return ctx, ctx.Context.Err()
+		case <-time.After(time.Second):
+			return ctx, nil
+		}
+	}})
+	shell.SetContent(content)
+
+	if _, err := Render(context.Background(), shell); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Render() error = %v, want deadline exceeded", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	if err := Write(context.Background(), rec, req, shell); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if !errors.Is(handled, context.DeadlineExceeded) {
+		t.Fatalf("OnError received %v, want deadline exceeded", handled)
+	}
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusGatewayTimeout)
+	}
+}
+
+func TestSetRenderTimeoutSkipsStagesAfterDeadline(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("report.gohtml", `report`)
+
+	later := 0
+	report := NewID("report", "report.gohtml").
+		SetFileSystem(fsys).
+		SetRenderTimeout(10*time.Millisecond).
+		Use(
+			RenderStageHooks{PrepareFunc: func(ctx *RenderContext) (*RenderContext, error) {
+				time.Sleep(30 * time.Millisecond)
+				return ctx, nil
+			}},
+			RenderStageHooks{PrepareFunc: func(ctx *RenderContext) (*RenderContext, error) {
+				later++
+				return ctx, nil
+			}},
+		)
+
+	if _, err := Render(context.Background(), report); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Render() error = %v, want deadline exceeded", err)
+	}
+	if later != 0 {
+		t.Fatalf("stage after the deadline ran %d times, want 0", later)
+	}
+}
+
+func TestSetRenderTimeoutAppliesToTargetedChild(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("report.gohtml", `{{ slow }}`)
+
+	shell := NewID("shell", "shell.gohtml").
+		SetFileSystem(fsys).
+		SetConnector(connector.NewHTMX(nil)).
+		SetFunc(template.FuncMap{"slow": func() string {
+			time.Sleep(60 * time.Millisecond)
+			return "done"
+		}})
+	shell.SetContent(NewID("report", "report.gohtml").SetRenderTimeout(20 * time.Millisecond))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+	req.Header.Set(connector.HTMXHeaderTarget.String(), "report")
+	_, err := RenderWithRequest(context.Background(), req, shell)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "rendering partial report exceeded timeout of 20ms") {
+		t.Fatalf("RenderWithRequest() error = %v, want the report timeout", err)
+	}
+}
+
+func TestRuntimeOnceSharesLoaderAcrossPartials(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<header>{{ user }}</header>{{ content }}`)
+	fsys.AddFile("content.gohtml", `<main>{{ user }}</main>`)
+
+	loads := 0
+	userStage := RenderStageHooks{PrepareFunc: func(ctx *RenderContext) (*RenderContext, error) {
+		ctx.SetFunc("user", func() (any, error) {
+			return ctx.Runtime.Once("user", func() (any, error) {
+				loads++
+				return "Ada", nil
+			})
+		})
+		return ctx, nil
+	}}
+	shell := NewID("shell", "shell.gohtml").
+		SetFileSystem(fsys).
+		SetFunc(template.FuncMap{"user": func() (any, error) { return nil, nil }}).
+		Use(userStage)
+	shell.SetContent(NewID("content", "content.gohtml"))
+
+	for i := 1; i <= 2; i++ {
+		out, err := RenderWithRequest(context.Background(), httptest.NewRequest(http.MethodGet, "/", nil), shell)
+		if err != nil {
+			t.Fatalf("RenderWithRequest() error = %v", err)
+		}
+		if out != "<header>Ada</header><main>Ada</main>" {
+			t.Fatalf("RenderWithRequest() = %q", out)
+		}
+		if loads != i {
+			t.Fatalf("after render %d the loader ran %d times, want once per render", i, loads)
+		}
+	}
+}
+
+func TestSetMemoizeRendersRepeatedChildOnce(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<header>{{ render "nav" }}</header>{{ content }}<footer>{{ render "nav" }}</footer>`)
+	fsys.AddFile("content.gohtml", `<main>{{ render "nav" }}</main>`)
+	fsys.AddFile("nav.gohtml", `<nav>{{ .Active }}</nav>`)
+
+	for _, memoize := range []bool{true, false} {
+		calls := 0
+		counter := RenderStageHooks{PrepareFunc: func(ctx *RenderContext) (*RenderContext, error) {
+			if ctx.Partial.PartialID() == "nav" {
+				calls++
+			}
+			return ctx, nil
+		}}
+		shell := NewID("shell", "shell.gohtml").
+			SetFileSystem(fsys).
+			Use(counter).
+			With(NewID("nav", "nav.gohtml").SetDot(map[string]any{"Active": "home"}).SetMemoize(memoize))
+		shell.SetContent(NewID("content", "content.gohtml"))
+
+		for range 2 {
+			out, err := RenderWithRequest(context.Background(), httptest.NewRequest(http.MethodGet, "/", nil), shell)
+			if err != nil {
+				t.Fatalf("RenderWithRequest() memoize=%v error = %v", memoize, err)
+			}
+			want := `<header><nav>home</nav></header><main><nav>home</nav></main><footer><nav>home</nav></footer>`
+			if out != template.HTML(want) {
+				t.Fatalf("RenderWithRequest() memoize=%v = %q, want %q", memoize, out, want)
+			}
+		}
+
+		want := 6
+		if memoize {
+			want = 2
+		}
+		if calls != want {
+			t.Fatalf("nav rendered %d times with memoize=%v, want %d", calls, memoize, want)
+		}
+	}
+}
+
+func TestSetMemoizeKeyKeysOutputByCallerKey(t *testing.T) {
+	ctx := withRenderMemo(context.Background())
+	byName := func(dot any) string { return dot.(map[string]string)["Name"] }
+
+	tests := []struct {
+		name    string
+		partial *Partial
+		want    string
+	}{
+		{name: "partial ID", partial: NewID("badge").SetDot(map[string]string{"Name": "Tea"}).SetMemoize(true), want: "badge"},
+		{name: "caller key", partial: NewID("badge").SetDot(map[string]string{"Name": "Tea"}).SetMemoizeKey(byName), want: "badge|Tea"},
+		{name: "other dot", partial: NewID("badge").SetDot(map[string]string{"Name": "Cake"}).SetMemoizeKey(byName), want: "badge|Cake"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memo, key := tt.partial.renderMemo(ctx)
+			if memo == nil {
+				t.Fatal("renderMemo() returned no store")
+			}
+			if key != tt.want {
+				t.Fatalf("renderMemo() key = %q, want %q", key, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireHeaderGuardsPartialOnlyRender(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("rows.gohtml", `<tr></tr>`)
+
+	newShell := func() (*Partial, *Partial) {
+		rows := NewID("rows", "rows.gohtml").RequireHeader("HX-Request", "true")
+		shell := NewID("shell", "shell.gohtml").
+			SetFileSystem(fsys).
+			SetConnector(connector.NewHTMX(nil))
+		shell.SetContent(rows)
+		return shell, rows
+	}
+
+	shell, rows := newShell()
+	req := httptest.NewRequest(http.MethodGet, "/rows", nil)
+	_, err := RenderWithRequest(context.Background(), req, rows)
+	var headerErr *MissingHeaderError
+	if !errors.As(err, &headerErr) {
+		t.Fatalf("RenderWithRequest() error = %v, want MissingHeaderError", err)
+	}
+	if headerErr.ID != "rows" || headerErr.Name != "HX-Request" || headerErr.Value != "true" {
+		t.Fatalf("MissingHeaderError = %+v", headerErr)
+	}
+
+	out, err := RenderWithRequest(context.Background(), req, shell)
+	if err != nil {
+		t.Fatalf("full page RenderWithRequest() error = %v", err)
+	}
+	if out != "<main><tr></tr></main>" {
+		t.Fatalf("full page output = %q", out)
+	}
+
+	shell, _ = newShell()
+	htmxReq := httptest.NewRequest(http.MethodGet, "/", nil)
+	htmxReq.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+	htmxReq.Header.Set(connector.HTMXHeaderTarget.String(), "rows")
+	out, err = RenderWithRequest(context.Background(), htmxReq, shell)
+	if err != nil {
+		t.Fatalf("target RenderWithRequest() error = %v", err)
+	}
+	if out != "<tr></tr>" {
+		t.Fatalf("target output = %q", out)
+	}
+}
+
+func TestRequireHeaderReportsBadRequest(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("rows.gohtml", `<tr></tr>`)
+
+	rows := NewID("rows", "rows.gohtml").
+		SetFileSystem(fsys).
+		RequireHeader("HX-Request", "true").
+		Use(RenderStageHooks{RenderFunc: func(ctx *RenderContext, next RenderNext) (template.HTML, error) {
+			if ctx.Kind != renderKindError {
+				return next(ctx)
+			}
+			return "refused", nil
+		}})
+
+	rec := httptest.NewRecorder()
+	err := Write(context.Background(), rec, httptest.NewRequest(http.MethodGet, "/rows", nil), rows)
+	if got := ErrorStatus(err); got != http.StatusBadRequest {
+		t.Fatalf("ErrorStatus(%v) = %d, want %d", err, got, http.StatusBadRequest)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if got := ErrorStatus(ErrNoTemplates); got != http.StatusInternalServerError {
+		t.Fatalf("ErrorStatus(ErrNoTemplates) = %d, want %d", got, http.StatusInternalServerError)
+	}
+}
+
+func TestRequireHeaderGuardsRegions(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("table.gohtml", `<table>{{ content }}</table>`)
+	fsys.AddFile("rows.gohtml", `<tr></tr>`)
+
+	table := NewID("table", "table.gohtml").SetFileSystem(fsys)
+	table.SetContent(NewID("rows", "rows.gohtml").RequireHeader("HX-Request", "true"))
+
+	req := httptest.NewRequest(http.MethodGet, "/rows", nil)
+	var headerErr *MissingHeaderError
+	if _, err := RenderRegions(context.Background(), req, table, "rows"); !errors.As(err, &headerErr) {
+		t.Fatalf("RenderRegions() error = %v, want MissingHeaderError", err)
+	}
+
+	ids := make(chan string, 1)
+	ids <- "rows"
+	close(ids)
+	if err := StreamJSON(context.Background(), httptest.NewRecorder(), req, table, ids); !errors.As(err, &headerErr) {
+		t.Fatalf("StreamJSON() error = %v, want MissingHeaderError", err)
+	}
+
+	req.Header.Set("HX-Request", "true")
+	regions, err := RenderRegions(context.Background(), req, table, "rows")
+	if err != nil {
+		t.Fatalf("RenderRegions() with header error = %v", err)
+	}
+	if regions["rows"] != "<tr></tr>" {
+		t.Fatalf("regions = %q", regions)
+	}
+}
+
+func TestUnknownTargetBehavior(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("content.gohtml", `<div id="content">content</div>`)
+	fsys.AddFile("missing.gohtml", `<div id="missing">Nothing here</div>`)
+
+	tests := []struct {
+		name       string
+		behavior   UnknownTargetBehavior
+		notFound   bool
+		wantStatus int
+		wantBody   string
+		wantErr    bool
+	}{
+		{name: "default without not-found partial", wantErr: true},
+		{name: "default with not-found partial", notFound: true, wantStatus: http.StatusNotFound, wantBody: `<div id="missing">Nothing here</div>`},
+		{name: "error", behavior: UnknownTargetError, notFound: true, wantErr: true},
+		{name: "full page", behavior: UnknownTargetFullPage, notFound: true, wantStatus: http.StatusOK, wantBody: `<main><div id="content">content</div></main>`},
+		{name: "not found", behavior: UnknownTargetNotFound, notFound: true, wantStatus: http.StatusNotFound, wantBody: `<div id="missing">Nothing here</div>`},
+		{name: "not found without not-found partial", behavior: UnknownTargetNotFound, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shell := NewID("shell", "shell.gohtml").
+				SetFileSystem(fsys).
+				SetUnknownTargetBehavior(tt.behavior)
+			if tt.notFound {
+				shell.SetNotFound(NewID("missing", "missing.gohtml"))
+			}
+			shell.SetContent(NewID("content", "content.gohtml"))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(connector.HeaderTarget.String(), "stale")
+			rec := httptest.NewRecorder()
+
+			err := Write(context.Background(), rec, req, shell)
+			if tt.wantErr {
+				var targetErr *TargetNotFoundError
+				if !errors.As(err, &targetErr) || targetErr.ID != "stale" {
+					t.Fatalf("Write() error = %v, want TargetNotFoundError for stale", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if body := rec.Body.String(); body != tt.wantBody {
+				t.Fatalf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRenderDotUsesSuppliedDot(t *testing.T) {
+	type invoice struct {
+		Number string
+		Lines  []string
+	}
+	fsys := &inMemoryFS{}
+	fsys.AddFile("invoice.gohtml", `#{{ .Number }}:{{ range .Lines }} {{ . }}{{ end }}`)
+
+	p := NewID("invoice", "invoice.gohtml").
+		SetFileSystem(fsys).
+		SetDot(invoice{Number: "configured"})
+
+	out, err := RenderDot(context.Background(), p, invoice{Number: "42", Lines: []string{"tea", "cake"}})
+	if err != nil {
+		t.Fatalf("RenderDot() error = %v", err)
+	}
+	if out != "#42: tea cake" {
+		t.Fatalf("RenderDot() = %q", out)
+	}
+
+	if dot, _ := p.getDotContract(); dot.(invoice).Number != "configured" {
+		t.Fatalf("partial dot was modified: %#v", dot)
+	}
+}
+
+func TestFastRenderExecutesPreparsedTemplate(t *testing.T) {
+	tmpl := template.Must(template.New("page").Parse(`{{ define "row" }}<tr>{{ . }}</tr>{{ end }}<p>{{ . }}</p>`))
+
+	var buf bytes.Buffer
+	if err := FastRender(tmpl, "", "<a>", &buf); err != nil {
+		t.Fatalf("FastRender() error = %v", err)
+	}
+	if err := FastRender(tmpl, "row", 1, &buf); err != nil {
+		t.Fatalf("FastRender(row) error = %v", err)
+	}
+	if buf.String() != "<p>&lt;a&gt;</p><tr>1</tr>" {
+		t.Fatalf("output = %q", buf.String())
+	}
+	if err := FastRender(nil, "", nil, &buf); !errors.Is(err, ErrNoTemplates) {
+		t.Fatalf("FastRender(nil) error = %v, want ErrNoTemplates", err)
+	}
+}
+
+func TestTemplateFuncEmbedsPartialInOuterTemplate(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("sidebar.gohtml", `<nav>{{ .User }} at {{ basePath }}</nav>`)
+
+	sidebar := NewID("sidebar", "sidebar.gohtml").
+		SetFileSystem(fsys).
+		SetBasePath("/app").
+		SetDot(map[string]any{"User": "<ada>"})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	outer := template.Must(template.New("layout").Funcs(template.FuncMap{
+		"sidebar": TemplateFunc(context.Background(), req, sidebar),
+	}).Parse(`<body>{{ sidebar }}<main>{{ . }}</main></body>`))
+
+	var buf bytes.Buffer
+	if err := outer.Execute(&buf, "page"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	want := `<body><nav>&lt;ada&gt; at /app</nav><main>page</main></body>`
+	if buf.String() != want {
+		t.Fatalf("output = %q, want %q", buf.String(), want)
+	}
+
+	broken := template.Must(template.New("layout").Funcs(template.FuncMap{
+		"sidebar": TemplateFunc(context.Background(), req, nil),
+	}).Parse(`{{ sidebar }}`))
+	if err := broken.Execute(&buf, nil); !errors.Is(err, ErrPartialNotInitialized) {
+		t.Fatalf("Execute() error = %v, want ErrPartialNotInitialized", err)
+	}
+}
+
+func TestRenderWithDataDoesNotCarryStateBetweenCalls(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("greeting.gohtml", `{{ .Greeting }} {{ .Name }}{{ with .Badge }} [{{ . }}]{{ end }}`)
+
+	for _, useCache := range []bool{false, true} {
+		greeting := NewID("greeting", "greeting.gohtml").
+			SetFileSystem(fsys).
+			SetDot(map[string]any{"Greeting": "Hello"}).
+			UseTemplateCache(useCache)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		first, err := RenderWithData(context.Background(), req, greeting, map[string]any{"Name": "Ada", "Badge": "admin"})
+		if err != nil {
+			t.Fatalf("RenderWithData() error = %v", err)
+		}
+		if first != "Hello Ada [admin]" {
+			t.Fatalf("first output = %q", first)
+		}
+
+		second, err := RenderWithData(context.Background(), req, greeting, map[string]any{"Name": "Grace"})
+		if err != nil {
+			t.Fatalf("RenderWithData() error = %v", err)
+		}
+		if second != "Hello Grace" {
+			t.Fatalf("second output = %q, want data from the first call dropped", second)
+		}
+
+		if dot, _ := greeting.getDotContract(); len(dot.(map[string]any)) != 1 {
+			t.Fatalf("partial dot was modified: %#v", dot)
+		}
+	}
+}
+
+func TestRenderWithFuncsScopesFuncsToOneCall(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("job.gohtml", `{{ id }}: {{ stamp }}`)
+
+	for _, useCache := range []bool{false, true} {
+		job := NewID("job", "job.gohtml").
+			SetFileSystem(fsys).
+			UseTemplateCache(useCache)
+
+		out, err := RenderWithFuncs(context.Background(), nil, job, template.FuncMap{
+			"stamp": func() string { return "nightly" },
+			"id":    func() string { return "overridden" },
+		})
+		if err != nil {
+			t.Fatalf("RenderWithFuncs() error = %v", err)
+		}
+		if got, want := string(out), "job: nightly"; got != want {
+			t.Fatalf("output = %q, want %q", got, want)
+		}
+
+		if _, err := Render(context.Background(), job); err == nil || !strings.Contains(err.Error(), `"stamp" not defined`) {
+			t.Fatalf("Render() error = %v, want stamp to be undefined", err)
+		}
+	}
+}
+
+func TestRenderWithFuncsDoesNotLeakIntoTemplateCache(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("job.gohtml", `{{ label }}`)
+
+	job := NewID("job", "job.gohtml").
+		SetFileSystem(fsys).
+		UseTemplateCache(true).
+		SetFunc(template.FuncMap{"label": func() string { return "static" }})
+	cached := job.cachedFuncMap()
+
+	for _, want := range []string{"first", "second"} {
+		out, err := RenderWithFuncs(context.Background(), nil, job, template.FuncMap{
+			"label": func() string { return want },
+		})
+		if err != nil {
+			t.Fatalf("RenderWithFuncs(%s) error = %v", want, err)
+		}
+		if string(out) != want {
+			t.Fatalf("RenderWithFuncs(%s) = %q", want, out)
+		}
+	}
+	if job.cachedFuncMap() != cached {
+		t.Fatal("RenderWithFuncs rebuilt the partial's function map")
+	}
+
+	out, err := Render(context.Background(), job)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if string(out) != "static" {
+		t.Fatalf("Render() = %q, want the static function", out)
+	}
+}
+
+func TestSetMaxOutputBytesAbortsOversizedRender(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("rows.gohtml", `{{ range . }}<tr><td>row</td></tr>{{ end }}`)
+
+	for _, useCache := range []bool{false, true} {
+		shell := NewID("shell", "shell.gohtml").
+			SetFileSystem(fsys).
+			UseTemplateCache(useCache).
+			SetMaxOutputBytes(256)
+		rows := NewID("rows", "rows.gohtml")
+		shell.SetContent(rows)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(connector.HeaderTarget.String(), "rows")
+
+		rows.SetDot(make([]struct{}, 5))
+		if _, err := RenderWithRequest(context.Background(), req, shell); err != nil {
+			t.Fatalf("RenderWithRequest(5 rows) error = %v", err)
+		}
+
+		rows.SetDot(make([]struct{}, 10000))
+		_, err := RenderWithRequest(context.Background(), req, shell)
+		if !errors.Is(err, ErrOutputTooLarge) {
+			t.Fatalf("RenderWithRequest(10000 rows) error = %v, want ErrOutputTooLarge", err)
+		}
+	}
+}
+
+func TestOOBRegionsOnlyForPartialRequests(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>{{ render "footer" }}`)
+	fsys.AddFile("content.gohtml", `<div id="content">content</div>`)
+	fsys.AddFile("footer.gohtml", `<footer id="footer"{{ oobAttr }}>footer</footer>`)
+
+	shell := NewID("shell", "shell.gohtml").SetFileSystem(fsys)
+	shell.SetContent(NewID("content", "content.gohtml"))
+	shell.WithOOB(NewID("footer", "footer.gohtml"))
+
+	page, err := RenderWithRequest(context.Background(), httptest.NewRequest(http.MethodGet, "/", nil), shell)
+	if err != nil {
+		t.Fatalf("RenderWithRequest(full page) error = %v", err)
+	}
+	if n := strings.Count(string(page), `id="footer"`); n != 1 {
+		t.Fatalf("full page contains footer %d times: %q", n, page)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(connector.HeaderTarget.String(), "content")
+	swap, err := RenderWithRequest(context.Background(), req, shell)
+	if err != nil {
+		t.Fatalf("RenderWithRequest(partial) error = %v", err)
+	}
+	if !strings.Contains(string(swap), `hx-swap-oob="true"`) {
+		t.Fatalf("partial response = %q, want OOB footer", swap)
+	}
+
+	swap, err = RenderWithRequest(WithoutOOB(context.Background()), req, shell)
+	if err != nil {
+		t.Fatalf("RenderWithRequest(WithoutOOB) error = %v", err)
+	}
+	if swap != `<div id="content">content</div>` {
+		t.Fatalf("partial response without OOB = %q", swap)
+	}
+}
+
+func TestOOBChildrenInheritRootDataAndHelpers(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("content.gohtml", `<div id="content">content</div>`)
+	fsys.AddFile("footer.gohtml", `<footer id="footer"{{ oobAttr }}>{{ appName }} for {{ user }}</footer>`)
+
+	for _, useCache := range []bool{false, true} {
+		shell := NewID("shell", "shell.gohtml").
+			SetFileSystem(fsys).
+			UseTemplateCache(useCache).
+			SetFunc(template.FuncMap{
+				"appName": func() string { return "Shop" },
+				"user":    func() string { return "" },
+			}).
+			Use(RenderStageHooks{
+				PrepareFunc: func(ctx *RenderContext) (*RenderContext, error) {
+					user := ctx.Request.Header.Get("X-User")
+					ctx.SetFunc("user", func() string { return user })
+					return ctx, nil
+				},
+			})
+		shell.SetContent(NewID("content", "content.gohtml"))
+		shell.WithOOB(NewID("footer", "footer.gohtml").UseTemplateCache(useCache))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(connector.HeaderTarget.String(), "content")
+		req.Header.Set("X-User", "Ada")
+		out, err := RenderWithRequest(context.Background(), req, shell)
+		if err != nil {
+			t.Fatalf("RenderWithRequest(useCache=%v) error = %v", useCache, err)
+		}
+		want := template.HTML(`<div id="content">content</div><footer id="footer" hx-swap-oob="true">Shop for Ada</footer>`)
+		if out != want {
+			t.Fatalf("RenderWithRequest(useCache=%v) = %q, want %q", useCache, out, want)
+		}
+	}
+}
+
+func TestRenderRegionsReturnsEachRegionOnce(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("content.gohtml", `<div id="content">content</div>`)
+	fsys.AddFile("footer.gohtml", `<footer id="footer"{{ oobAttr }}>footer</footer>`)
+
+	shell := NewID("shell", "shell.gohtml").SetFileSystem(fsys)
+	shell.SetContent(NewID("content", "content.gohtml"))
+	shell.WithOOB(NewID("footer", "footer.gohtml"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	regions, err := RenderRegions(context.Background(), req, shell, "content", "footer")
+	if err != nil {
+		t.Fatalf("RenderRegions() error = %v", err)
+	}
+
+	expected := map[string]template.HTML{
+		"content": `<div id="content">content</div>`,
+		"footer":  `<footer id="footer">footer</footer>`,
+	}
+	if !maps.Equal(regions, expected) {
+		t.Fatalf("regions = %#v, want %#v", regions, expected)
+	}
+
+	if _, err := RenderRegions(context.Background(), req, shell, "missing"); err == nil {
+		t.Fatal("RenderRegions() error = nil, want missing region error")
+	}
+}
+
+func TestRenderChangedRegionsOmitsUnchangedRegions(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("dashboard.gohtml", `{{ render "clock" }}{{ render "status" }}`)
+	fsys.AddFile("clock.gohtml", `<time id="clock">{{ . }}</time>`)
+	fsys.AddFile("status.gohtml", `<p id="status">ok</p>`)
+
+	dashboard := NewID("dashboard", "dashboard.gohtml").SetFileSystem(fsys)
+	dashboard.With(NewID("clock", "clock.gohtml").SetDot("10:01"))
+	dashboard.With(NewID("status", "status.gohtml"))
+
+	prevHashes := map[string]string{
+		"clock":  RegionHash(`<time id="clock">10:00</time>`),
+		"status": RegionHash(`<p id="status">ok</p>`),
+	}
+	regions, err := RenderChangedRegions(context.Background(), nil, dashboard, prevHashes)
+	if err != nil {
+		t.Fatalf("RenderChangedRegions() error = %v", err)
+	}
+
+	expected := map[string]template.HTML{
+		"clock": `<time id="clock">10:01</time>`,
+	}
+	if !maps.Equal(regions, expected) {
+		t.Fatalf("regions = %#v, want %#v", regions, expected)
+	}
+}
+
+func TestHTMXSelectorTargetResolvesPartialID(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("content.gohtml", `<div id="content">content</div>`)
+
+	shell := NewID("shell", "shell.gohtml").
+		SetFileSystem(fsys).
+		SetConnector(connector.NewHTMX(nil))
+	shell.SetContent(NewID("content", "content.gohtml"))
+
+	for _, target := range []string{"content", "#content"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+		req.Header.Set(connector.HTMXHeaderTarget.String(), target)
+		out, err := RenderWithRequest(context.Background(), req, shell)
+		if err != nil {
+			t.Fatalf("RenderWithRequest(%s) error = %v", target, err)
+		}
+		if out != `<div id="content">content</div>` {
+			t.Fatalf("RenderWithRequest(%s) = %q", target, out)
+		}
+	}
+}
+
+func TestStreamJSONScopesMemoizedOutputToOneFragment(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("panel.gohtml", `<div>{{ render "clock" }}</div>`)
+	fsys.AddFile("clock.gohtml", `{{ tick }}`)
+
+	ticks := 0
+	panel := NewID("panel", "panel.gohtml").
+		SetFileSystem(fsys).
+		SetFunc(template.FuncMap{"tick": func() int { ticks++; return ticks }}).
+		With(NewID("clock", "clock.gohtml").SetMemoize(true))
+
+	ids := make(chan string, 2)
+	ids <- "panel"
+	ids <- "panel"
+	close(ids)
+
+	rec := httptest.NewRecorder()
+	if err := StreamJSON(context.Background(), rec, httptest.NewRequest(http.MethodGet, "/", nil), panel, ids); err != nil {
+		t.Fatalf("StreamJSON() error = %v", err)
+	}
+	want := `{"id":"panel","html":"\u003cdiv\u003e1\u003c/div\u003e"}` + "\n" +
+		`{"id":"panel","html":"\u003cdiv\u003e2\u003c/div\u003e"}` + "\n"
+	if got := rec.Body.String(); got != want {
+		t.Fatalf("body = %q, want %q", got, want)
+	}
+}
+
+func TestStreamJSONWritesOneFragmentPerLine(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("table.gohtml", `<table>{{ content }}</table>`)
+	fsys.AddFile("row.gohtml", `<tr><td>{{ .Name }}</td></tr>`)
+
+	table := NewID("table", "table.gohtml").SetFileSystem(fsys)
+	table.With(NewID("row-1", "row.gohtml").SetDot(map[string]string{"Name": "Tea"}))
+	table.With(NewID("row-2", "row.gohtml").SetDot(map[string]string{"Name": "Cake"}))
+
+	ids := make(chan string, 2)
+	ids <- "row-1"
+	ids <- "row-2"
+	close(ids)
+
+	req := httptest.NewRequest(http.MethodGet, "/rows", nil)
+	rec := httptest.NewRecorder()
+	if err := StreamJSON(context.Background(), rec, req, table, ids); err != nil {
+		t.Fatalf("StreamJSON() error = %v", err)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Fatalf("Content-Type = %q", got)
+	}
+	if !rec.Flushed {
+		t.Fatal("StreamJSON() did not flush")
+	}
+
+	type fragment struct {
+		ID   string `json:"id"`
+		HTML string `json:"html"`
+	}
+	var got []fragment
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var f fragment
+		if err := json.Unmarshal(scanner.Bytes(), &f); err != nil {
+			t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+		}
+		got = append(got, f)
+	}
+	want := []fragment{
+		{ID: "row-1", HTML: "<tr><td>Tea</td></tr>"},
+		{ID: "row-2", HTML: "<tr><td>Cake</td></tr>"},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("fragments = %#v, want %#v", got, want)
+	}
+}
+
+func TestHTMXHistoryRestoreRendersFullPage(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("content.gohtml", `<div id="content">content</div>`)
+
+	shell := NewID("shell", "shell.gohtml").
+		SetFileSystem(fsys).
+		SetConnector(connector.NewHTMX(nil))
+	shell.SetContent(NewID("content", "content.gohtml"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+	req.Header.Set(connector.HTMXHeaderTarget.String(), "content")
+	req.Header.Set(connector.HTMXHeaderHistoryRestoreRequest.String(), "true")
+	rec := httptest.NewRecorder()
+
+	if err := Write(context.Background(), rec, req, shell); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if body := rec.Body.String(); body != `<main><div id="content">content</div></main>` {
+		t.Fatalf("body = %q, want full page", body)
+	}
+}
+
+func TestSetDefaultTargetRendersTargetWithoutHeader(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("content.gohtml", `<div id="content">content</div>`)
+	fsys.AddFile("sidebar.gohtml", `<nav id="sidebar">sidebar</nav>`)
+
+	shell := NewID("shell", "shell.gohtml").
+		SetFileSystem(fsys).
+		SetDefaultTarget("content")
+	shell.SetContent(NewID("content", "content.gohtml"))
+	shell.With(NewID("sidebar", "sidebar.gohtml"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	out, err := RenderWithRequest(context.Background(), req, shell)
+	if err != nil {
+		t.Fatalf("RenderWithRequest() error = %v", err)
+	}
+	if out != `<div id="content">content</div>` {
+		t.Fatalf("output = %q, want default target", out)
+	}
+
+	req.Header.Set(connector.HeaderTarget.String(), "sidebar")
+	out, err = RenderWithRequest(context.Background(), req, shell)
+	if err != nil {
+		t.Fatalf("RenderWithRequest() error = %v", err)
+	}
+	if out != `<nav id="sidebar">sidebar</nav>` {
+		t.Fatalf("output = %q, want requested target", out)
+	}
+}
+
+func TestSetDefaultTargetServesPlainAndHistoryRequests(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("list.gohtml", `<ul id="list"></ul>`)
+	fsys.AddFile("toast.gohtml", `<div id="toast">t</div>`)
+
+	shell := NewID("shell", "shell.gohtml").
+		SetFileSystem(fsys).
+		SetConnector(connector.NewHTMX(nil)).
+		SetDefaultTarget("list")
+	shell.SetContent(NewID("list", "list.gohtml"))
+	shell.WithOOB(NewID("toast", "toast.gohtml"))
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{
+			name: "plain request",
+			want: `<ul id="list"></ul>`,
+		},
+		{
+			name:    "partial request",
+			headers: map[string]string{connector.HTMXHeaderRequest.String(): "true"},
+			want:    `<ul id="list"></ul><div hx-swap-oob="true" id="toast">t</div>`,
+		},
+		{
+			name: "history restore",
+			headers: map[string]string{
+				connector.HTMXHeaderRequest.String():               "true",
+				connector.HTMXHeaderHistoryRestoreRequest.String(): "true",
+			},
+			want: `<main><ul id="list"></ul></main>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			out, err := RenderWithRequest(context.Background(), req, shell)
+			if err != nil {
+				t.Fatalf("RenderWithRequest() error = %v", err)
+			}
+			if string(out) != tt.want {
+				t.Fatalf("output = %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
 func testErrorStage(detailed bool) RenderStage {
 	return RenderStageHooks{
 		RenderFunc: func(ctx *RenderContext, next RenderNext) (template.HTML, error) {
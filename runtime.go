@@ -82,6 +82,18 @@ func (r *Runtime) SetValue(key any, value any) {
 	r.state.Values.Set(key, value)
 }
 
+// Once runs fn the first time key is requested during the active render pass
+// and returns its result, including an error, to every later call with the
+// same key, so partials in one tree can share an expensive load. Keys follow
+// the rules of context keys. Outside a render pass fn runs on every call.
+func (r *Runtime) Once(key any, fn func() (any, error)) (any, error) {
+	memo := renderMemoFrom(r.Context())
+	if memo == nil {
+		return fn()
+	}
+	return memo.once(key, fn)
+}
+
 // Connector returns the connector for the active partial.
 func (r *Runtime) Connector() connector.Connector {
 	if r == nil || r.partial == nil {
@@ -151,7 +163,7 @@ func (r *Runtime) preparePartial(p *Partial) (*Partial, error) {
 		return nil, fmt.Errorf("go-partial runtime partial render stage is not configured")
 	}
 	if p == nil {
-		return nil, fmt.Errorf("partial is not initialized")
+		return nil, ErrPartialNotInitialized
 	}
 	child := p.clone()
 	child.parent = r.partial
@@ -2,6 +2,7 @@ package partial
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
@@ -29,6 +30,12 @@ type (
 		Response *RenderResponse
 		Headers  map[string]string
 		Err      error
+		// Partial is the partial that produced HTML, which differs from the
+		// rendered partial when a stage replaced it.
+		Partial *Partial
+		// Empty reports whether the rendered partial's own HTML, before OOB
+		// regions and fragment markers are added, is blank.
+		Empty bool
 	}
 
 	// RenderStage observes or changes a render lifecycle.
@@ -44,6 +51,11 @@ type (
 		Finalize(*RenderContext, template.HTML, error) (template.HTML, error)
 	}
 
+	// Decorator wraps the render of a partial, middleware style, with
+	// cross-cutting behavior such as timing, authorization, or caching. It
+	// returns the RenderNext to call in place of next.
+	Decorator func(next RenderNext) RenderNext
+
 	// RenderStageHooks adapts individual lifecycle functions to RenderStage.
 	RenderStageHooks struct {
 		PrepareFunc  func(*RenderContext) (*RenderContext, error)
@@ -86,6 +98,19 @@ func (h RenderStageHooks) Finalize(ctx *RenderContext, out template.HTML, render
 	return h.FinalizeFunc(ctx, out, renderErr)
 }
 
+// decoratorStage adapts a Decorator to a RenderStage that wraps partial
+// renders only, not target resolution or error renders.
+func decoratorStage(decorator Decorator) RenderStage {
+	return RenderStageHooks{
+		RenderFunc: func(ctx *RenderContext, next RenderNext) (template.HTML, error) {
+			if ctx == nil || ctx.Kind != RenderKindPartial {
+				return next(ctx)
+			}
+			return decorator(next)(ctx)
+		},
+	}
+}
+
 func templateRenderStage() RenderStage {
 	return RenderStageHooks{
 		RenderFunc: func(ctx *RenderContext, next RenderNext) (template.HTML, error) {
@@ -140,6 +165,7 @@ func newRenderContext(ctx context.Context, p *Partial, r *http.Request, kind Ren
 			ctx = defaultRenderContext()
 		}
 	}
+	ctx = withRenderMemo(ctx)
 
 	var currentURL *url.URL
 	if r != nil {
@@ -188,6 +214,9 @@ func renderWithChainResult(state *RenderContext, stages []RenderStage, terminal
 
 	var err error
 	for _, stage := range active {
+		if err := deadlineErr(state); err != nil {
+			return renderResult{Response: state.Response, Err: err}
+		}
 		response := state.Response
 		state, err = stage.Prepare(state)
 		if err != nil {
@@ -204,6 +233,10 @@ func renderWithChainResult(state *RenderContext, stages []RenderStage, terminal
 		}
 	}
 
+	if err := deadlineErr(state); err != nil {
+		return renderResult{Response: state.Response, Err: err}
+	}
+
 	next := terminal
 	for i := len(active) - 1; i >= 0; i-- {
 		stage := active[i]
@@ -238,5 +271,44 @@ func renderWithChainResult(state *RenderContext, stages []RenderStage, terminal
 		})
 	}
 
-	return renderResult{HTML: out, Response: state.Response, Err: renderErr}
+	return renderResult{HTML: out, Response: state.Response, Err: renderErr, Partial: state.Partial}
+}
+
+// deadlineErr returns the error of the render context once its deadline has
+// passed, so a render stops before the next stage instead of running to the
+// end. A stage or template that is already running is not interrupted.
+func deadlineErr(state *RenderContext) error {
+	if state.Context == nil {
+		return nil
+	}
+	if err := state.Context.Err(); errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return nil
+}
+
+// groupStage replaces the template render of the group partial with the
+// concatenated renders of its members. Members inherit the stage, so it only
+// applies when the group itself is rendered. A non-nil invalid is the problem
+// NewGroup found with the members; it fails the render of the group.
+func groupStage(id string, members []string, invalid error) RenderStage {
+	return RenderStageHooks{
+		RenderFunc: func(ctx *RenderContext, next RenderNext) (template.HTML, error) {
+			if ctx == nil || ctx.Kind != RenderKindPartial || ctx.Partial == nil || ctx.Partial.id != id {
+				return next(ctx)
+			}
+			if invalid != nil {
+				return "", invalid
+			}
+			var out template.HTML
+			for _, member := range members {
+				html, err := renderChildPartial(ctx.Context, ctx.Request, ctx.Partial, member)
+				if err != nil {
+					return "", err
+				}
+				out += html
+			}
+			return out, nil
+		},
+	}
 }
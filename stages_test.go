@@ -120,6 +120,32 @@ func TestRootStageAppliesToContentPartials(t *testing.T) {
 	}
 }
 
+func TestDecorateWrapsPartialRender(t *testing.T) {
+	fsys := fstest.MapFS{
+		"card.gohtml": &fstest.MapFile{Data: []byte(`<div>card</div>`)},
+	}
+	marker := func(label string) Decorator {
+		return func(next RenderNext) RenderNext {
+			return func(ctx *RenderContext) (template.HTML, error) {
+				out, err := next(ctx)
+				return template.HTML("<!--"+label+"-->") + out, err
+			}
+		}
+	}
+
+	card := NewID("card", "card.gohtml").
+		SetFileSystem(fsys).
+		Decorate(marker("outer"), marker("inner"))
+
+	out, err := Render(context.Background(), card)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if got, want := string(out), "<!--outer--><!--inner--><div>card</div>"; got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+}
+
 func TestStageCanHandleErrorKind(t *testing.T) {
 	fsys := fstest.MapFS{
 		"broken.gohtml": &fstest.MapFile{Data: []byte(`{{ if .Missing }}missing`)},
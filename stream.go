@@ -0,0 +1,122 @@
+package partial
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+)
+
+type streamContextKey struct{}
+
+// responseStream writes the fragments of a partial response to the client as
+// they are rendered instead of concatenating them.
+type responseStream struct {
+	w            http.ResponseWriter
+	root         *Partial
+	lastModified string
+	started      bool
+	err          error
+}
+
+// Stream renders p like Write, but for partial requests it writes and flushes
+// the requested target as soon as it is rendered, then writes and flushes each
+// out-of-band region as it is produced, so the full response is never held in
+// memory at once. The target always comes first, regardless of
+// SetOOBPosition. Full-page renders and failures before the first write are
+// answered exactly as Write answers them.
+//
+// Once the target is written the status and headers are sent, so a later
+// failure cannot change the response. Stream then stops writing and returns
+// the error, which the caller should log; the client receives a truncated
+// response.
+func Stream(ctx context.Context, w http.ResponseWriter, r *http.Request, p *Partial) error {
+	if w == nil {
+		return errors.New("response writer is not configured")
+	}
+	if p == nil {
+		_, err := fmt.Fprint(w, ErrPartialNotInitialized.Error())
+		return err
+	}
+	if ctx == nil {
+		if r != nil {
+			ctx = r.Context()
+		} else {
+			ctx = defaultRenderContext()
+		}
+	}
+
+	p = p.withDetectedConnector(r)
+	modified, hasModified := p.requestLastModified(r)
+	if hasModified && writeNotModified(w, r, modified) {
+		return nil
+	}
+
+	stream := &responseStream{w: w, root: p}
+	if hasModified {
+		stream.lastModified = modified.Format(http.TimeFormat)
+	}
+	result := renderWithRequestResult(context.WithValue(ctx, streamContextKey{}, stream), r, p)
+	if !stream.started {
+		return writeRenderResult(ctx, w, r, p, result, modified, hasModified)
+	}
+
+	if result.Err == nil && result.HTML != "" {
+		stream.write(result.HTML)
+	}
+	if err := errors.Join(result.Err, stream.err); err != nil {
+		p.emitWithContext(ctx, r, Event{
+			Kind:    EventRenderError,
+			Level:   EventError,
+			Message: "error streaming partial after the response started",
+			Error:   err,
+		})
+		return fmt.Errorf("error streaming partial after the response started: %w", err)
+	}
+	return nil
+}
+
+func streamFrom(ctx context.Context) *responseStream {
+	if ctx == nil {
+		return nil
+	}
+	stream, _ := ctx.Value(streamContextKey{}).(*responseStream)
+	return stream
+}
+
+// start sends the headers for the rendered target and writes its HTML. It
+// reports whether the response was streamed, in which case result.HTML must
+// not be written again.
+func (s *responseStream) start(result renderResult) bool {
+	if s == nil || s.started {
+		return false
+	}
+	s.started = true
+	for k, v := range collectResponseHeaders(s.root, result) {
+		s.w.Header()[k] = v
+	}
+	if s.lastModified != "" {
+		s.w.Header().Set("Last-Modified", s.lastModified)
+	}
+	if result.Response != nil && result.Response.Status > 0 {
+		s.w.WriteHeader(result.Response.Status)
+	}
+	s.write(result.HTML)
+	return true
+}
+
+// write writes html and flushes it to the client. After a write error it
+// discards further output.
+func (s *responseStream) write(html template.HTML) {
+	if s.err != nil {
+		return
+	}
+	if _, err := s.w.Write([]byte(html)); err != nil {
+		s.err = err
+		return
+	}
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,89 @@
+package partial
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/donseba/go-partial/connector"
+)
+
+// flushRecorder records the body written between flushes.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed []string
+	pending strings.Builder
+}
+
+func (f *flushRecorder) Write(b []byte) (int, error) {
+	f.pending.Write(b)
+	return f.ResponseRecorder.Write(b)
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushed = append(f.flushed, f.pending.String())
+	f.pending.Reset()
+	f.ResponseRecorder.Flush()
+}
+
+func TestStreamFlushesTargetThenEachOOBRegion(t *testing.T) {
+	fsys := &inMemoryFS{}
+	fsys.AddFile("shell.gohtml", `<main>{{ content }}</main>`)
+	fsys.AddFile("feed.gohtml", `<ul id="feed"><li>post</li></ul>`)
+	fsys.AddFile("counter.gohtml", `<span id="counter">3</span>`)
+	fsys.AddFile("toast.gohtml", `<div id="toast">loaded</div>`)
+	fsys.AddFile("broken.gohtml", `<div id="broken">{{ call .Fail }}</div>`)
+
+	newShell := func(broken bool) *Partial {
+		shell := NewID("shell", "shell.gohtml").
+			SetFileSystem(fsys).
+			SetConnector(connector.NewHTMX(nil)).
+			SetResponseHeaders(map[string]string{"X-Feed": "page-2"})
+		shell.SetContent(NewID("feed", "feed.gohtml"))
+		shell.WithOOB(NewID("counter", "counter.gohtml"))
+		shell.WithOOB(NewID("toast", "toast.gohtml"))
+		if broken {
+			shell.WithOOB(NewID("broken", "broken.gohtml").SetDot(map[string]any{
+				"Fail": func() (string, error) { return "", ErrNoTemplates },
+			}))
+		}
+		return shell
+	}
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/feed?page=2", nil)
+		req.Header.Set(connector.HTMXHeaderRequest.String(), "true")
+		req.Header.Set(connector.HTMXHeaderTarget.String(), "feed")
+		return req
+	}
+
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	if err := Stream(context.Background(), rec, newRequest(), newShell(false)); err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	if got := rec.Header().Get("X-Feed"); got != "page-2" {
+		t.Fatalf("X-Feed = %q, want page-2", got)
+	}
+	if len(rec.flushed) != 3 || rec.flushed[0] != `<ul id="feed"><li>post</li></ul>` {
+		t.Fatalf("flushed = %q, want the target first and one flush per OOB region", rec.flushed)
+	}
+	oob := slices.Sorted(slices.Values(rec.flushed[1:]))
+	want := []string{
+		`<div hx-swap-oob="true" id="toast">loaded</div>`,
+		`<span hx-swap-oob="true" id="counter">3</span>`,
+	}
+	if !slices.Equal(oob, want) {
+		t.Fatalf("OOB flushes = %q, want %q", oob, want)
+	}
+
+	rec = &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	err := Stream(context.Background(), rec, newRequest(), newShell(true))
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("Stream() error = %v, want the OOB error after the response started", err)
+	}
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Body.String(), `<ul id="feed">`) {
+		t.Fatalf("status = %d, body = %q, want the streamed target", rec.Code, rec.Body.String())
+	}
+}
@@ -1,12 +1,55 @@
 package partial
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"html/template"
 	"io/fs"
+	"reflect"
 	"strings"
 )
 
+// WrapFuncErrors returns a copy of funcs in which every function whose last
+// result is an error reports failures as a *FuncError carrying the function
+// name. Render errors then wrap it in a *TemplateExecuteError with the partial
+// ID and template, so a failing call such as parseDate can be located:
+//
+//	root.SetFunc(partial.WrapFuncErrors(templatehelpers.FuncMap()))
+func WrapFuncErrors(funcs template.FuncMap) template.FuncMap {
+	wrapped := make(template.FuncMap, len(funcs))
+	for name, fn := range funcs {
+		wrapped[name] = wrapFuncError(name, fn)
+	}
+	return wrapped
+}
+
+var errorType = reflect.TypeFor[error]()
+
+func wrapFuncError(name string, fn any) any {
+	value := reflect.ValueOf(fn)
+	typ := value.Type()
+	if typ.Kind() != reflect.Func || typ.NumOut() == 0 || typ.Out(typ.NumOut()-1) != errorType {
+		return fn
+	}
+	return reflect.MakeFunc(typ, func(args []reflect.Value) []reflect.Value {
+		var out []reflect.Value
+		if typ.IsVariadic() {
+			out = value.CallSlice(args)
+		} else {
+			out = value.Call(args)
+		}
+		last := len(out) - 1
+		err, _ := out[last].Interface().(error)
+		var funcErr *FuncError
+		if err != nil && !errors.As(err, &funcErr) {
+			wrapped := error(&FuncError{Name: name, Err: err})
+			out[last] = reflect.ValueOf(&wrapped).Elem()
+		}
+		return out
+	}).Interface()
+}
+
 func partialFunc(p *Partial, state *RenderContext) func(id string, args ...any) template.HTML {
 	return func(id string, args ...any) template.HTML {
 		if templatePath, ok := partialTemplatePath(p, id); ok {
@@ -55,7 +98,7 @@ func partialTemplatePath(p *Partial, name string) (string, bool) {
 		return "", false
 	}
 
-	info, err := fs.Stat(p.getFS(), templatePath)
+	info, err := fs.Stat(p.getFS(), p.resolveTemplatePath(templatePath))
 	if err != nil || info.IsDir() {
 		return "", false
 	}
@@ -108,6 +151,81 @@ func contentFunc(p *Partial, state *RenderContext) func() template.HTML {
 	}
 }
 
+func renderFunc(p *Partial, state *RenderContext) func(id string) template.HTML {
+	return func(id string) template.HTML {
+		target := lookupFromRoot(p, id)
+		if target == nil {
+			state.EmitForPartial(p, Event{
+				Kind:    EventTemplateMissing,
+				Level:   EventWarn,
+				Message: "render helper partial not found",
+				Fields:  map[string]any{"id": id},
+			})
+			return template.HTML(template.HTMLEscapeString(fmt.Sprintf("partial '%s' not found", id)))
+		}
+
+		target.mu.RLock()
+		parent := target.parent
+		target.mu.RUnlock()
+		if parent == nil {
+			parent = p
+		}
+
+		html, err := renderChildPartial(state.Context, state.Request, parent, target.PartialID())
+		if err != nil {
+			state.EmitForPartial(p, Event{
+				Kind:    EventRenderError,
+				Level:   EventError,
+				Message: "error rendering partial by id",
+				Error:   err,
+				Fields:  map[string]any{"id": id},
+			})
+			return template.HTML(template.HTMLEscapeString(fmt.Sprintf("error rendering partial '%s': %v", id, err)))
+		}
+		return html
+	}
+}
+
+func includeFunc(p *Partial, state *RenderContext) func(name string, data ...any) (template.HTML, error) {
+	return func(name string, data ...any) (template.HTML, error) {
+		tmpl := state.executing
+		if tmpl == nil || tmpl.Lookup(name) == nil {
+			state.EmitForPartial(p, Event{
+				Kind:    EventTemplateMissing,
+				Level:   EventWarn,
+				Message: "include helper template not found",
+				Fields:  map[string]any{"name": name},
+			})
+			return "", fmt.Errorf("include: template '%s' not found", name)
+		}
+
+		var dot any
+		if len(data) > 0 {
+			dot = data[0]
+		}
+		var buf bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&buf, name, dot); err != nil {
+			return "", err
+		}
+		return template.HTML(buf.String()), nil
+	}
+}
+
+func partialExistsFunc(p *Partial) func(id string) bool {
+	return func(id string) bool {
+		return lookupFromRoot(p, id) != nil
+	}
+}
+
+// lookupFromRoot finds a partial by ID anywhere in the tree that contains p.
+func lookupFromRoot(p *Partial, id string) *Partial {
+	root := p
+	for root.parent != nil {
+		root = root.parent
+	}
+	return root.recursiveChildLookup(id, make(map[string]bool))
+}
+
 func partialDotMapArg(state *RenderContext, p *Partial, id string, args ...any) (map[string]any, bool) {
 	if len(args)%2 != 0 {
 		state.EmitForPartial(p, Event{